@@ -0,0 +1,20 @@
+// Copyright The KCL Authors. All rights reserved.
+
+// Command kcl-worker serves the native KCL service over its standard input
+// and output, it is the worker process of kcl-lang.io/kcl-go/pkg/service/pool.
+//
+//	go install kcl-lang.io/kcl-go/cmd/kcl-worker@latest
+package main
+
+import (
+	"log"
+
+	"kcl-lang.io/kcl-go/pkg/native"
+	"kcl-lang.io/kcl-go/pkg/service/pool"
+)
+
+func main() {
+	if err := pool.Serve(native.NewNativeServiceClient()); err != nil {
+		log.Fatal(err)
+	}
+}
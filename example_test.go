@@ -8,6 +8,8 @@ package kcl_test
 import (
 	"fmt"
 	"log"
+	"os"
+	"time"
 
 	kcl "kcl-lang.io/kcl-go"
 	"kcl-lang.io/kcl-go/pkg/native"
@@ -134,6 +136,56 @@ x = {
 	// person: {Name:kcl Age:101}
 }
 
+func ExampleRunAs() {
+	const k_code = `
+name = "kcl"
+replicas = 3
+timeout = "1m30s"
+`
+
+	type Config struct {
+		Name     string        `json:"name"`
+		Replicas int64         `json:"replicas"`
+		Timeout  time.Duration `json:"timeout"`
+	}
+
+	config, err := kcl.RunAs[Config]("testdata/main.k", kcl.WithCode(k_code))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%+v\n", config)
+
+	// Output:
+	// {Name:kcl Replicas:3 Timeout:1m30s}
+}
+
+func ExampleKCLResult_Query() {
+	const k_code = `
+metadata.labels = {"app.kubernetes.io/name" = "web"}
+spec.containers = [
+    {name = "app", image = "app:v1", ports = [80]}
+    {name = "sidecar", image = "proxy:v2"}
+]
+`
+
+	result := kcl.MustRun("testdata/main.k", kcl.WithCode(k_code)).First()
+
+	name, _ := result.QueryString(`metadata.labels["app.kubernetes.io/name"]`)
+	fmt.Println(name)
+	image, _ := result.QueryString("spec.containers[0].image")
+	fmt.Println(image)
+	names, _ := result.QueryAll("spec.containers[*].name")
+	fmt.Println(names)
+	exposed, _ := result.QueryAll("spec.containers[?(@.ports)].name")
+	fmt.Println(exposed)
+
+	// Output:
+	// web
+	// app:v1
+	// [app sidecar]
+	// [app]
+}
+
 func Example() {
 	const k_code = `
 name = "kcl"
@@ -336,3 +388,46 @@ func ExampleUpdateDependencies_execProgram() {
 	// Output:
 	// a: Hello World!
 }
+
+func ExampleKCLResult_Encode() {
+	const k_code = `
+name = "app"
+db = {port = 5432, hosts = ["a", "b"]}
+`
+
+	result := kcl.MustRun("testdata/main.k", kcl.WithCode(k_code)).First()
+	if err := result.Encode(os.Stdout, kcl.EncodeDotenv); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// NAME=app
+	// DB_PORT=5432
+	// DB_HOSTS_0=a
+	// DB_HOSTS_1=b
+}
+
+func ExampleDiffResults() {
+	const k_code = `
+env = option("env")
+apiVersion = "apps/v1"
+kind = "Deployment"
+metadata.name = "web"
+spec.replicas = 3 if env == "prod" else 1
+`
+
+	staging := kcl.MustRun("testdata/main.k", kcl.WithCode(k_code), kcl.WithOptions("env=staging"))
+	prod := kcl.MustRun("testdata/main.k", kcl.WithCode(k_code), kcl.WithOptions("env=prod"))
+
+	changes := kcl.DiffResults(staging, prod, kcl.DiffOptions{})
+	if err := kcl.WriteDiff(os.Stdout, changes); err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// @@ apps/v1/Deployment//web @@
+	// - env: "staging"
+	// + env: "prod"
+	// - spec.replicas: 1
+	// + spec.replicas: 3
+}
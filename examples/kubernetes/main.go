@@ -2,13 +2,37 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"os"
 
 	kcl "kcl-lang.io/kcl-go"
+	"kcl-lang.io/kcl-go/pkg/k8s"
 )
 
 func main() {
-	yaml := kcl.MustRun("kubernetes.k", kcl.WithCode(code)).GetRawYamlResult()
-	fmt.Println(yaml)
+	result := kcl.MustRun("kubernetes.k", kcl.WithCode(code))
+	fmt.Println(result.GetRawYamlResult())
+
+	objs, err := k8s.Objects(result)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := k8s.Validate(objs); err != nil {
+		log.Fatal(err)
+	}
+	k8s.Sort(objs)
+	k8s.SetLabels(objs, map[string]string{"app.kubernetes.io/managed-by": "kcl"})
+	dir, err := os.MkdirTemp("", "manifests-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	files, err := k8s.WriteFiles(dir, objs, k8s.WriteOptions{OrderPrefix: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range files {
+		fmt.Println("wrote", file)
+	}
 }
 
 const code = `
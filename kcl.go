@@ -31,7 +31,10 @@ KCL Go SDK
 package kcl
 
 import (
+	"context"
 	"io"
+	"io/fs"
+	"time"
 
 	"kcl-lang.io/kcl-go/pkg/kcl"
 	"kcl-lang.io/kcl-go/pkg/loader"
@@ -43,10 +46,35 @@ import (
 	"kcl-lang.io/kcl-go/pkg/tools/override"
 	"kcl-lang.io/kcl-go/pkg/tools/testing"
 	"kcl-lang.io/kcl-go/pkg/tools/validate"
+	"kcl-lang.io/lib/go/api"
 )
 
 type (
 	Option             = kcl.Option
+	CancelError        = kcl.CancelError
+	Error              = kcl.Error
+	ErrorKind          = kcl.ErrorKind
+	Diagnostic         = kcl.Diagnostic
+	Pos                = kcl.Pos
+	PreExecHook        = kcl.PreExecHook
+	PreExecHookFunc    = kcl.PreExecHookFunc
+	PostResultHook     = kcl.PostResultHook
+	PostResultHookFunc = kcl.PostResultHookFunc
+	Cache              = kcl.Cache
+	CacheStats         = kcl.CacheStats
+	MemoryCache        = kcl.MemoryCache
+	DirCache           = kcl.DirCache
+	Artifact           = kcl.Artifact
+	OptionsError       = kcl.OptionsError
+	QueryError         = kcl.QueryError
+	OptionProblem      = kcl.OptionProblem
+	OptionProblemKind  = kcl.OptionProblemKind
+	BatchJob           = kcl.BatchJob
+	BatchOptions       = kcl.BatchOptions
+	BatchResult        = kcl.BatchResult
+	BatchProgress      = kcl.BatchProgress
+	BatchSummary       = kcl.BatchSummary
+	BatchReport        = kcl.BatchReport
 	ListDepsOptions    = list.DepOptions
 	ListDepFilesOption = list.Option
 	ValidateOptions    = validate.ValidateOptions
@@ -55,6 +83,16 @@ type (
 	TestResult         = testing.TestResult
 	KCLResult          = kcl.KCLResult
 	KCLResultList      = kcl.KCLResultList
+	DecodeOptions      = kcl.DecodeOptions
+	DecodeError        = kcl.DecodeError
+	EncodeFormat       = kcl.EncodeFormat
+	EncodeOptions      = kcl.EncodeOptions
+	Change             = kcl.Change
+	ChangeKind         = kcl.ChangeKind
+	DiffOptions        = kcl.DiffOptions
+	RedactOptions      = kcl.RedactOptions
+	NumberMode         = kcl.NumberMode
+	ServiceClient      = api.ServiceClient
 
 	KclType                  = kcl.KclType
 	VersionResult            = kcl.VersionResult
@@ -71,6 +109,57 @@ type (
 	FormatPathOptions        = format.FormatPathOptions
 )
 
+// Error kinds of a Diagnostic.
+const (
+	ErrorKindUnknown            = kcl.ErrorKindUnknown
+	ErrorKindSyntaxError        = kcl.ErrorKindSyntaxError
+	ErrorKindCompileError       = kcl.ErrorKindCompileError
+	ErrorKindTypeError          = kcl.ErrorKindTypeError
+	ErrorKindSchemaCheckFailure = kcl.ErrorKindSchemaCheckFailure
+	ErrorKindAssertionError     = kcl.ErrorKindAssertionError
+	ErrorKindRuntimePanic       = kcl.ErrorKindRuntimePanic
+)
+
+// ErrQueryNoMatch is returned by KCLResult.Query when the query matches no value.
+var ErrQueryNoMatch = kcl.ErrQueryNoMatch
+
+// Kinds of an OptionProblem reported by WithStrictOptions.
+const (
+	OptionUnknown      = kcl.OptionUnknown
+	OptionMissing      = kcl.OptionMissing
+	OptionTypeMismatch = kcl.OptionTypeMismatch
+)
+
+// Output formats of KCLResult.Encode.
+const (
+	EncodeJSON       = kcl.EncodeJSON
+	EncodeYAML       = kcl.EncodeYAML
+	EncodeJSONLines  = kcl.EncodeJSONLines
+	EncodeTOML       = kcl.EncodeTOML
+	EncodeDotenv     = kcl.EncodeDotenv
+	EncodeProperties = kcl.EncodeProperties
+	EncodeTFVarsJSON = kcl.EncodeTFVarsJSON
+	EncodeTFVars     = kcl.EncodeTFVars
+	EncodeXML        = kcl.EncodeXML
+)
+
+// Kinds of a Change reported by DiffResults.
+const (
+	ChangeAdded    = kcl.ChangeAdded
+	ChangeRemoved  = kcl.ChangeRemoved
+	ChangeModified = kcl.ChangeModified
+)
+
+// Number modes of WithNumberMode.
+const (
+	NumberModeDefault    = kcl.NumberModeDefault
+	NumberModeJSONNumber = kcl.NumberModeJSONNumber
+	NumberModeExact      = kcl.NumberModeExact
+)
+
+// RedactedValue replaces the values and text redacted by WithRedaction.
+const RedactedValue = kcl.RedactedValue
+
 // MustRun is like Run but panics if return any error.
 func MustRun(path string, opts ...Option) *KCLResultList {
 	return kcl.MustRun(path, opts...)
@@ -81,11 +170,85 @@ func Run(path string, opts ...Option) (*KCLResultList, error) {
 	return kcl.Run(path, opts...)
 }
 
+// RunContext is like Run but returns an error wrapping ctx.Err() when ctx is
+// cancelled or its deadline passes before the KCL program finishes.
+func RunContext(ctx context.Context, path string, opts ...Option) (*KCLResultList, error) {
+	return kcl.RunContext(ctx, path, opts...)
+}
+
+// RunAs evaluates the KCL program with path and opts, then decodes the first result into a value of type T.
+func RunAs[T any](path string, opts ...Option) (T, error) {
+	return kcl.RunAs[T](path, opts...)
+}
+
 // RunFiles evaluates the KCL program with multi file path and opts, then returns the object list.
 func RunFiles(paths []string, opts ...Option) (*KCLResultList, error) {
 	return kcl.RunFiles(paths, opts...)
 }
 
+// RunFilesContext is like RunFiles but returns an error wrapping ctx.Err() when
+// ctx is cancelled or its deadline passes before the KCL program finishes.
+func RunFilesContext(ctx context.Context, paths []string, opts ...Option) (*KCLResultList, error) {
+	return kcl.RunFilesContext(ctx, paths, opts...)
+}
+
+// RunBatch evaluates jobs concurrently and returns their results, errors and
+// durations in input order.
+func RunBatch(ctx context.Context, jobs []BatchJob, opts BatchOptions) *BatchReport {
+	return kcl.RunBatch(ctx, jobs, opts)
+}
+
+// RunSettings runs the program of the settings files and writes the result
+// to the output path of the settings, if any.
+func RunSettings(opts ...Option) (*KCLResultList, error) {
+	return kcl.RunSettings(opts...)
+}
+
+// RunSettingsContext is like RunSettings but returns a *CancelError when ctx
+// is cancelled or its deadline passes before the KCL program finishes.
+func RunSettingsContext(ctx context.Context, opts ...Option) (*KCLResultList, error) {
+	return kcl.RunSettingsContext(ctx, opts...)
+}
+
+// DiffResults compares the documents of two results, paired by the identity
+// key of DiffOptions, and returns the added, removed and modified values.
+func DiffResults(a, b *KCLResultList, opts DiffOptions) []Change {
+	return kcl.DiffResults(a, b, opts)
+}
+
+// DefaultDiffKeyPaths returns the key paths identifying Kubernetes objects,
+// see DiffOptions.
+func DefaultDiffKeyPaths() []string {
+	return kcl.DefaultDiffKeyPaths()
+}
+
+// WriteDiff writes the changes as unified-diff-style text.
+func WriteDiff(w io.Writer, changes []Change) error {
+	return kcl.WriteDiff(w, changes)
+}
+
+// WriteDiffJSON writes the changes as a JSON array.
+func WriteDiffJSON(w io.Writer, changes []Change) error {
+	return kcl.WriteDiffJSON(w, changes)
+}
+
+// Build compiles the KCL program with path and opts into an Artifact, which
+// can be evaluated many times with different options by Artifact.Run.
+func Build(path string, opts ...Option) (*Artifact, error) {
+	return kcl.Build(path, opts...)
+}
+
+// BuildContext is like Build but returns an error wrapping ctx.Err() when ctx
+// is cancelled or its deadline passes before the program is compiled.
+func BuildContext(ctx context.Context, path string, opts ...Option) (*Artifact, error) {
+	return kcl.BuildContext(ctx, path, opts...)
+}
+
+// LoadArtifact returns the Artifact saved at path by Artifact.Save.
+func LoadArtifact(path string, opts ...Option) (*Artifact, error) {
+	return kcl.LoadArtifact(path, opts...)
+}
+
 // NewOption returns a new Option.
 func NewOption() *Option {
 	return kcl.NewOption()
@@ -108,18 +271,75 @@ func WithKFilenames(filenames ...string) Option { return kcl.WithKFilenames(file
 // WithOptions returns a Option which hold a key=value pair list for option function.
 func WithOptions(key_value_list ...string) Option { return kcl.WithOptions(key_value_list...) }
 
+// WithOptionValue returns a Option which hold a top level argument with the JSON encoding of v.
+func WithOptionValue(name string, v any) Option { return kcl.WithOptionValue(name, v) }
+
+// WithOptionsFile returns a Option which hold the top level arguments from a JSON, YAML or TOML file.
+func WithOptionsFile(filename string) Option { return kcl.WithOptionsFile(filename) }
+
+// WithStrictOptions returns a Option which checks the top level arguments against the option() calls of the program.
+func WithStrictOptions(strict bool) Option { return kcl.WithStrictOptions(strict) }
+
+// WithOptionsFromEnv returns a Option which hold the top level arguments from environment variables with prefix.
+func WithOptionsFromEnv(prefix string) Option { return kcl.WithOptionsFromEnv(prefix) }
+
 // WithOverrides returns a Option which hold a override list.
 func WithOverrides(override_list ...string) Option { return kcl.WithOverrides(override_list...) }
 
 // WithSelectors returns a Option which hold a path selector list.
 func WithSelectors(selectors ...string) Option { return kcl.WithSelectors(selectors...) }
 
-// WithSettings returns a Option which hold a settings file.
-func WithSettings(filename string) Option { return kcl.WithSettings(filename) }
+// WithSettings returns a Option which hold settings files. Later files
+// override the scalars of earlier ones and append to their lists.
+func WithSettings(filenames ...string) Option { return kcl.WithSettings(filenames...) }
+
+// WithSettingsProfile returns a Option which selects a `profiles` section of
+// the settings files.
+func WithSettingsProfile(name string) Option { return kcl.WithSettingsProfile(name) }
+
+// WithService returns a Option which runs the call with svc instead of the
+// default service, e.g. a remote client or a test double.
+func WithService(svc ServiceClient) Option { return kcl.WithService(svc) }
+
+// SetDefaultService sets the service used by the calls without WithService,
+// a nil svc restores the native service.
+func SetDefaultService(svc ServiceClient) { kcl.SetDefaultService(svc) }
+
+// WithNumberMode returns a Option which sets how the numbers of the result
+// are decoded, e.g. as json.Number to keep large integers and precise floats.
+func WithNumberMode(mode NumberMode) Option { return kcl.WithNumberMode(mode) }
+
+// WithRedaction returns a Option which redacts the output values, option
+// values and patterns marked by opts from the result, the log and the errors.
+func WithRedaction(opts RedactOptions) Option { return kcl.WithRedaction(opts) }
 
 // WithWorkDir returns a Option which hold a work dir.
 func WithWorkDir(workDir string) Option { return kcl.WithWorkDir(workDir) }
 
+// WithHooks returns a Option which hold a per-call PreExecHook/PostResultHook list.
+func WithHooks(hooks ...any) Option { return kcl.WithHooks(hooks...) }
+
+// WithPreExecHooks returns a Option which hold per-call hooks run before the KCL program is executed.
+func WithPreExecHooks(hooks ...PreExecHook) Option { return kcl.WithPreExecHooks(hooks...) }
+
+// WithPostResultHooks returns a Option which hold per-call hooks run on the result of the KCL program.
+func WithPostResultHooks(hooks ...PostResultHook) Option { return kcl.WithPostResultHooks(hooks...) }
+
+// WithFS returns a Option which runs the KCL program with the entry package root inside fsys, e.g. an embed.FS.
+func WithFS(fsys fs.FS, root string) Option { return kcl.WithFS(fsys, root) }
+
+// WithCache returns a Option which hold a result cache, unchanged programs skip the native call.
+func WithCache(cache Cache) Option { return kcl.WithCache(cache) }
+
+// NewMemoryCache returns an in-memory LRU result cache holding at most capacity results.
+func NewMemoryCache(capacity int) *MemoryCache { return kcl.NewMemoryCache(capacity) }
+
+// NewDirCache returns an on-disk result cache in dir.
+func NewDirCache(dir string) (*DirCache, error) { return kcl.NewDirCache(dir) }
+
+// WithTimeout returns a Option which hold a per-run timeout.
+func WithTimeout(timeout time.Duration) Option { return kcl.WithTimeout(timeout) }
+
 // WithDisableNone returns a Option which hold a disable none switch.
 func WithDisableNone(disableNone bool) Option { return kcl.WithDisableNone(disableNone) }
 
@@ -154,8 +374,8 @@ func WithLogger(l io.Writer) Option {
 }
 
 // FormatCode returns the formatted code.
-func FormatCode(code any) ([]byte, error) {
-	return format.FormatCode(code)
+func FormatCode(code any, opts ...Option) ([]byte, error) {
+	return format.FormatCode(code, opts...)
 }
 
 // FormatPath formats files from the given path
@@ -166,8 +386,8 @@ func FormatCode(code any) ([]byte, error) {
 // if path is `path/to/dir/...`, all KCL files in the specified dir will be formatted recursively
 //
 // the returned changedPaths are the changed file paths (relative path)
-func FormatPath(path string) (changedPaths []string, err error) {
-	return format.FormatPath(path)
+func FormatPath(path string, opts ...Option) (changedPaths []string, err error) {
+	return format.FormatPath(path, opts...)
 }
 
 // FormatPathWithOptions formats files from the given path with some options.
@@ -181,8 +401,9 @@ func FormatPath(path string) (changedPaths []string, err error) {
 func FormatPathWithOptions(
 	path string,
 	opts FormatPathOptions,
+	options ...Option,
 ) ([]string, error) {
-	return format.FormatPathWithOptions(path, opts)
+	return format.FormatPathWithOptions(path, opts, options...)
 }
 
 // ListDepFiles return the depend files from the given path
@@ -201,8 +422,8 @@ func ListDownStreamFiles(workDir string, opt *ListDepsOptions) ([]string, error)
 }
 
 // LintPath lint files from the given path
-func LintPath(paths []string) (results []string, err error) {
-	return lint.LintPath(paths)
+func LintPath(paths []string, opts ...Option) (results []string, err error) {
+	return lint.LintPath(paths, opts...)
 }
 
 // OverrideFile rewrites a file with override spec
@@ -210,19 +431,24 @@ func LintPath(paths []string) (results []string, err error) {
 // specs: []string. List of specs that need to be overridden.
 // importPaths. List of import statements that need to be added.
 // See https://www.kcl-lang.io/docs/user_docs/guides/automation for more override spec guide.
-func OverrideFile(file string, specs, importPaths []string) (bool, error) {
-	return override.OverrideFile(file, specs, importPaths)
+func OverrideFile(file string, specs, importPaths []string, opts ...Option) (bool, error) {
+	return override.OverrideFile(file, specs, importPaths, opts...)
 }
 
 // ValidateCode validate data string match code string
-func ValidateCode(data, code string, opts *ValidateOptions) (ok bool, err error) {
-	return validate.ValidateCode(data, code, opts)
+func ValidateCode(data, code string, opts *ValidateOptions, options ...Option) (ok bool, err error) {
+	return validate.ValidateCode(data, code, opts, options...)
+}
+
+// ValidateCodeContext is like ValidateCode but honors the cancellation and deadline of ctx.
+func ValidateCodeContext(ctx context.Context, data, code string, opts *ValidateOptions, options ...Option) (ok bool, err error) {
+	return validate.ValidateCodeContext(ctx, data, code, opts, options...)
 }
 
 // Validate validates the given data file against the specified
 // schema file with the provided options.
-func Validate(dataFile, schemaFile string, opts *ValidateOptions) (ok bool, err error) {
-	return validate.Validate(dataFile, schemaFile, opts)
+func Validate(dataFile, schemaFile string, opts *ValidateOptions, options ...Option) (ok bool, err error) {
+	return validate.Validate(dataFile, schemaFile, opts, options...)
 }
 
 // Test calls the test tool to run uni tests in packages.
@@ -230,6 +456,11 @@ func Test(testOpts *TestOptions, opts ...Option) (TestResult, error) {
 	return testing.Test(testOpts, opts...)
 }
 
+// TestContext is like Test but honors the cancellation and deadline of ctx.
+func TestContext(ctx context.Context, testOpts *TestOptions, opts ...Option) (TestResult, error) {
+	return testing.TestContext(ctx, testOpts, opts...)
+}
+
 // GetSchemaType returns schema types from a kcl file or code.
 //
 // file: string
@@ -243,8 +474,8 @@ func Test(testOpts *TestOptions, opts ...Option) (TestResult, error) {
 // schema_name: string
 //
 //	The schema name got, when the schema name is empty, all schemas are returned.
-func GetSchemaType(filename string, src any, schemaName string) ([]*KclType, error) {
-	return kcl.GetSchemaType(filename, src, schemaName)
+func GetSchemaType(filename string, src any, schemaName string, opts ...Option) ([]*KclType, error) {
+	return kcl.GetSchemaType(filename, src, schemaName, opts...)
 }
 
 // GetSchemaTypeMapping returns a <schemaName>:<schemaType> mapping of schema types from a kcl file or code.
@@ -260,38 +491,43 @@ func GetSchemaType(filename string, src any, schemaName string) ([]*KclType, err
 // schema_name: string
 //
 //	The schema name got, when the schema name is empty, all schemas are returned.
-func GetSchemaTypeMapping(filename string, src any, schemaName string) (map[string]*KclType, error) {
-	return kcl.GetSchemaTypeMapping(filename, src, schemaName)
+func GetSchemaTypeMapping(filename string, src any, schemaName string, opts ...Option) (map[string]*KclType, error) {
+	return kcl.GetSchemaTypeMapping(filename, src, schemaName, opts...)
+}
+
+// GetSchemaTypeMappingContext is like GetSchemaTypeMapping but honors the cancellation and deadline of ctx.
+func GetSchemaTypeMappingContext(ctx context.Context, filename string, src any, schemaName string, opts ...Option) (map[string]*KclType, error) {
+	return kcl.GetSchemaTypeMappingContext(ctx, filename, src, schemaName, opts...)
 }
 
 // Parse KCL program with entry files and return the AST JSON string.
-func ParseProgram(args *ParseProgramArgs) (*ParseProgramResult, error) {
-	return parser.ParseProgram(args)
+func ParseProgram(args *ParseProgramArgs, opts ...Option) (*ParseProgramResult, error) {
+	return parser.ParseProgram(args, opts...)
 }
 
 // LoadPackage provides users with the ability to parse KCL program and semantic model
 // information including symbols, types, definitions, etc.
-func LoadPackage(args *LoadPackageArgs) (*LoadPackageResult, error) {
-	return loader.LoadPackage(args)
+func LoadPackage(args *LoadPackageArgs, opts ...Option) (*LoadPackageResult, error) {
+	return loader.LoadPackage(args, opts...)
 }
 
 // ListVariables provides users with the ability to parse KCL program and get all variables by specs.
-func ListVariables(args *ListVariablesArgs) (*ListVariablesResult, error) {
-	return loader.ListVariables(args)
+func ListVariables(args *ListVariablesArgs, opts ...Option) (*ListVariablesResult, error) {
+	return loader.ListVariables(args, opts...)
 }
 
 // ListOptions provides users with the ability to parse kcl program and get all option
 // calling information.
-func ListOptions(args *ListOptionsArgs) (*ListOptionsResult, error) {
-	return loader.ListOptions(args)
+func ListOptions(args *ListOptionsArgs, opts ...Option) (*ListOptionsResult, error) {
+	return loader.ListOptions(args, opts...)
 }
 
 // Download and update dependencies defined in the kcl.mod file and return the external package name and location list.
-func UpdateDependencies(args *UpdateDependenciesArgs) (*UpdateDependenciesResult, error) {
-	return module.UpdateDependencies(args)
+func UpdateDependencies(args *UpdateDependenciesArgs, opts ...Option) (*UpdateDependenciesResult, error) {
+	return module.UpdateDependencies(args, opts...)
 }
 
 // GetVersion returns the KCL service version information.
-func GetVersion() (*VersionResult, error) {
-	return kcl.GetVersion()
+func GetVersion(opts ...Option) (*VersionResult, error) {
+	return kcl.GetVersion(opts...)
 }
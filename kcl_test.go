@@ -7,12 +7,15 @@ package kcl_test
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	assert2 "github.com/stretchr/testify/assert"
 
@@ -544,3 +547,167 @@ func TestWithLogger(t *testing.T) {
 	assert2.Equal(t, "hello: world", result.GetRawYamlResult())
 	assert2.Equal(t, "Hello world\n", buf.String())
 }
+
+func TestBuildArtifact(t *testing.T) {
+	file, err := filepath.Abs("./testdata/option/main.k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact, err := kcl.Build(file, kcl.WithOptions("key2=build"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer artifact.Close()
+
+	for _, value := range []string{"v1", "v2"} {
+		result, err := artifact.Run(kcl.WithOptions("key2=" + value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert2.Equal(t, value, result.First().Get("b"))
+	}
+
+	saved := filepath.Join(t.TempDir(), "main.artifact")
+	if err := artifact.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := kcl.LoadArtifact(saved, kcl.WithOptions("key2=loaded"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := loaded.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert2.Equal(t, "loaded", result.First().Get("b"))
+}
+
+func TestWithFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/kcl.mod":      {Data: []byte("[package]\nname = \"config\"\n")},
+		"config/app/main.k":   {Data: []byte("import lib\n\nname = lib.name\n")},
+		"config/lib/lib.k":    {Data: []byte("name = \"kcl\"\n")},
+		"config/bad/main.k":   {Data: []byte("a: int = \"1\"\n")},
+		"config/other/main.k": {Data: []byte("b = 1\n")},
+	}
+	result, err := kcl.Run("main.k", kcl.WithFS(fsys, "config/app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert2.Equal(t, "name: kcl", result.GetRawYamlResult())
+
+	_, err = kcl.Run("main.k", kcl.WithFS(fsys, "config/bad"))
+	var kerr *kcl.Error
+	if !errors.As(err, &kerr) {
+		t.Fatalf("expect a *kcl.Error, got %v", err)
+	}
+	assert2.Equal(t, "config/bad/main.k", kerr.Diagnostics[0].Pos.Filename)
+}
+
+func TestWithOptionValue(t *testing.T) {
+	file, err := filepath.Abs("./testdata/option/main.k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := kcl.Run(file,
+		kcl.WithOptionValue("key1", "a=b"),
+		kcl.WithOptionValue("key2", "123"),
+		kcl.WithOptionValue("metadata-key", map[string]any{"replicas": 3}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert2.Equal(t, "a=b", result.First().Get("a"))
+	assert2.Equal(t, "123", result.First().Get("b"))
+	assert2.Equal(t, 3, result.First().Get("c.metadata.key.replicas"))
+}
+
+func TestWithStrictOptions(t *testing.T) {
+	file, err := filepath.Abs("./testdata/option/main.k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kcl.Run(file, kcl.WithStrictOptions(true), kcl.WithOptions("kye2=value"))
+	var optErr *kcl.OptionsError
+	if !errors.As(err, &optErr) {
+		t.Fatalf("expect a *kcl.OptionsError, got %v", err)
+	}
+	assert2.Equal(t, 2, len(optErr.Problems))
+	assert2.Equal(t, kcl.OptionUnknown, optErr.Problems[0].Kind)
+	assert2.Equal(t, "key2", optErr.Problems[0].Suggestion)
+	assert2.Equal(t, kcl.OptionMissing, optErr.Problems[1].Kind)
+
+	_, err = kcl.Run(file, kcl.WithStrictOptions(true), kcl.WithOptions("key2=value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	const code = `
+schema Person:
+    name: str = "kcl"
+    age: int = 1
+
+person = Person {
+    age = 101
+}
+`
+	result, err := kcl.Run("main.k", kcl.WithCode(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions, err := result.First().LookupOrigin("person.age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert2.Equal(t, 1, len(positions))
+	assert2.Equal(t, int64(7), positions[0].Line)
+
+	positions = result.First().Origin("person.name")
+	assert2.Equal(t, 1, len(positions))
+	assert2.Equal(t, int64(3), positions[0].Line)
+}
+
+func TestRunSettings(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.k"), []byte("env = option(\"env\")\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settings := filepath.Join(dir, "kcl.yaml")
+	if err := os.WriteFile(settings, []byte(fmt.Sprintf(`
+kcl_cli_configs:
+  file: [%q]
+  output: %q
+kcl_options:
+  - key: env
+    value: dev
+profiles:
+  prod:
+    kcl_options:
+      - key: env
+        value: prod
+`, filepath.Join(dir, "main.k"), filepath.Join(dir, "out", "main.json"))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := kcl.RunSettings(kcl.WithSettings(settings), kcl.WithSettingsProfile("prod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "out", "main.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert2.Equal(t, "{\n    \"env\": \"prod\"\n}\n", string(data))
+
+	// Without an output path the result is only returned.
+	if err := os.WriteFile(settings, []byte(fmt.Sprintf("kcl_cli_configs:\n  file: [%q]\n", filepath.Join(dir, "main.k"))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := kcl.RunSettings(kcl.WithSettings(settings), kcl.WithOptions("env=dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert2.Equal(t, "env: dev\n", result.GetRawYamlResult())
+}
@@ -0,0 +1,231 @@
+// Copyright The KCL Authors. All rights reserved.
+
+// Package k8s turns the result of a KCL program rendering Kubernetes
+// manifests into objects which can be validated, ordered, labeled and
+// written to files, e.g.
+//
+//	objs, err := k8s.Objects(result)
+//	if err != nil {
+//		return err
+//	}
+//	if err := k8s.Validate(objs); err != nil {
+//		return err
+//	}
+//	k8s.Sort(objs)
+//	k8s.SetLabels(objs, map[string]string{"app.kubernetes.io/managed-by": "kcl"})
+//	files, err := k8s.WriteFiles("manifests", objs, k8s.WriteOptions{})
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+)
+
+// Object is a Kubernetes object, e.g. a Deployment, decoded from a KCL
+// result.
+type Object map[string]any
+
+// APIVersion returns the apiVersion of the object, e.g. "apps/v1".
+func (o Object) APIVersion() string { return o.str("apiVersion") }
+
+// Kind returns the kind of the object, e.g. "Deployment".
+func (o Object) Kind() string { return o.str("kind") }
+
+// Group returns the API group of the object, "" for the core group.
+func (o Object) Group() string {
+	group, _, ok := strings.Cut(o.APIVersion(), "/")
+	if !ok {
+		return ""
+	}
+	return group
+}
+
+// Name returns metadata.name of the object.
+func (o Object) Name() string { return o.metadata().str("name") }
+
+// Namespace returns metadata.namespace of the object.
+func (o Object) Namespace() string { return o.metadata().str("namespace") }
+
+// Labels returns metadata.labels of the object.
+func (o Object) Labels() map[string]string { return o.metadata().stringMap("labels") }
+
+// Annotations returns metadata.annotations of the object.
+func (o Object) Annotations() map[string]string {
+	return o.metadata().stringMap("annotations")
+}
+
+// String returns the object as "apiVersion/kind/namespace/name", e.g.
+// "apps/v1/Deployment/default/nginx".
+func (o Object) String() string {
+	return strings.Join([]string{o.APIVersion(), o.Kind(), o.Namespace(), o.Name()}, "/")
+}
+
+func (o Object) str(key string) string {
+	s, _ := o[key].(string)
+	return s
+}
+
+func (o Object) metadata() Object {
+	m, _ := o["metadata"].(map[string]any)
+	return m
+}
+
+func (o Object) stringMap(key string) map[string]string {
+	m, _ := o[key].(map[string]any)
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			result[k] = s
+		} else {
+			result[k] = fmt.Sprint(v)
+		}
+	}
+	return result
+}
+
+// Objects returns the Kubernetes objects of the result, one per document.
+// A document holding an `items` list, e.g. a v1 List, is replaced by its
+// items. The objects are copies, changing them does not change the result.
+func Objects(result *kcl.KCLResultList) ([]Object, error) {
+	var objs []Object
+	for i, r := range result.Slice() {
+		m, err := r.ToMap()
+		if err != nil {
+			return nil, fmt.Errorf("k8s: document %d: expect a mapping: %w", i, err)
+		}
+		objs, err = appendObjects(objs, Object(deepCopy(m).(map[string]any)), fmt.Sprintf("document %d", i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objs, nil
+}
+
+func appendObjects(objs []Object, o Object, where string) ([]Object, error) {
+	items, hasItems := o["items"].([]any)
+	if !hasItems || !(o.Kind() == "" || strings.HasSuffix(o.Kind(), "List")) {
+		return append(objs, o), nil
+	}
+	for i, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("k8s: %s: items[%d]: expect a mapping, got %T", where, i, item)
+		}
+		var err error
+		objs, err = appendObjects(objs, Object(m), fmt.Sprintf("%s: items[%d]", where, i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objs, nil
+}
+
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, x := range v {
+			m[k] = deepCopy(x)
+		}
+		return m
+	case []any:
+		list := make([]any, len(v))
+		for i, x := range v {
+			list[i] = deepCopy(x)
+		}
+		return list
+	}
+	return v
+}
+
+// Problem is a missing required field of an object, found by Validate.
+type Problem struct {
+	// Index is the index of the object.
+	Index int
+	// Object is the object as formatted by Object.String.
+	Object string
+	// Field is the missing field, e.g. "metadata.name".
+	Field string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("object %d (%s): missing %s", p.Index, p.Object, p.Field)
+}
+
+// ValidationError is returned by Validate and holds all the problems found.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var lines []string
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return fmt.Sprintf("k8s: invalid objects:\n%s", strings.Join(lines, "\n"))
+}
+
+// Validate checks that every object has an apiVersion, a kind and a
+// metadata.name, or a metadata.generateName. It returns a *ValidationError
+// listing every missing field.
+func Validate(objs []Object) error {
+	var problems []Problem
+	for i, o := range objs {
+		missing := func(field string) {
+			problems = append(problems, Problem{Index: i, Object: o.String(), Field: field})
+		}
+		if o.APIVersion() == "" {
+			missing("apiVersion")
+		}
+		if o.Kind() == "" {
+			missing("kind")
+		}
+		if o.Name() == "" && o.metadata().str("generateName") == "" {
+			missing("metadata.name")
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// SetLabels adds the labels to metadata.labels of every object, replacing
+// existing labels with the same key.
+func SetLabels(objs []Object, labels map[string]string) {
+	for _, o := range objs {
+		o.setMetadataMap("labels", labels)
+	}
+}
+
+// SetAnnotations adds the annotations to metadata.annotations of every
+// object, replacing existing annotations with the same key.
+func SetAnnotations(objs []Object, annotations map[string]string) {
+	for _, o := range objs {
+		o.setMetadataMap("annotations", annotations)
+	}
+}
+
+func (o Object) setMetadataMap(key string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	metadata, ok := o["metadata"].(map[string]any)
+	if !ok {
+		metadata = make(map[string]any)
+		o["metadata"] = metadata
+	}
+	m, ok := metadata[key].(map[string]any)
+	if !ok {
+		m = make(map[string]any, len(values))
+		metadata[key] = m
+	}
+	for k, v := range values {
+		m[k] = v
+	}
+}
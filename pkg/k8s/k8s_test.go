@@ -0,0 +1,156 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package k8s
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+const testManifests = `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: check
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx
+    namespace: web
+    labels:
+      app: nginx
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: nginx
+    namespace: web
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+`
+
+func testObjects(t *testing.T) []Object {
+	t.Helper()
+	result, err := kcl.ExecResultToKCLResult(&kcl.Option{ExecProgramArgs: new(gpyrpc.ExecProgramArgs)}, &gpyrpc.ExecProgramResult{
+		JsonResult: "{}",
+		YamlResult: testManifests,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := Objects(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objs
+}
+
+func kinds(objs []Object) []string {
+	var kinds []string
+	for _, o := range objs {
+		kinds = append(kinds, o.Kind())
+	}
+	return kinds
+}
+
+func TestObjectsAndSort(t *testing.T) {
+	objs := testObjects(t)
+	if got, expect := kinds(objs), []string{"ValidatingWebhookConfiguration", "Deployment", "Service", "Widget", "Namespace"}; !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %v, got %v", expect, got)
+	}
+	if err := Validate(objs); err != nil {
+		t.Fatal(err)
+	}
+
+	Sort(objs)
+	if got, expect := kinds(objs), []string{"Namespace", "Service", "Deployment", "Widget", "ValidatingWebhookConfiguration"}; !reflect.DeepEqual(got, expect) {
+		t.Fatalf("expect %v, got %v", expect, got)
+	}
+	if got := objs[2].String(); got != "apps/v1/Deployment/web/nginx" {
+		t.Fatalf("unexpected object %s", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	objs := []Object{
+		{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]any{"generateName": "p-"}},
+		{"kind": "Pod", "metadata": map[string]any{}},
+	}
+	err := Validate(objs)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expect a *ValidationError, got %v", err)
+	}
+	expect := []Problem{
+		{Index: 1, Object: "/Pod//", Field: "apiVersion"},
+		{Index: 1, Object: "/Pod//", Field: "metadata.name"},
+	}
+	if !reflect.DeepEqual(verr.Problems, expect) {
+		t.Fatalf("expect %v, got %v", expect, verr.Problems)
+	}
+}
+
+func TestSetLabels(t *testing.T) {
+	objs := testObjects(t)
+	SetLabels(objs, map[string]string{"team": "a", "app": "web"})
+	SetAnnotations(objs, map[string]string{"owner": "a@example.com"})
+
+	labels := objs[1].Labels()
+	if labels["team"] != "a" || labels["app"] != "web" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if got := objs[0].Annotations()["owner"]; got != "a@example.com" {
+		t.Fatalf("unexpected annotation %q", got)
+	}
+	// The result itself is not changed.
+	if got := testObjects(t)[1].Labels(); !reflect.DeepEqual(got, map[string]string{"app": "nginx"}) {
+		t.Fatalf("unexpected labels %v", got)
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	objs := testObjects(t)
+	Sort(objs)
+	files, err := WriteFiles(dir, objs, WriteOptions{OrderPrefix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		filepath.Join(dir, "000-namespace-web.yaml"),
+		filepath.Join(dir, "web", "001-service-nginx.yaml"),
+		filepath.Join(dir, "web", "002-deployment-nginx.yaml"),
+		filepath.Join(dir, "003-widget-w.yaml"),
+		filepath.Join(dir, "004-validatingwebhookconfiguration-check.yaml"),
+	}
+	if !reflect.DeepEqual(files, expect) {
+		t.Fatalf("expect %v, got %v", expect, files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: web\n" {
+		t.Fatalf("unexpected file content %q", got)
+	}
+
+	if _, err := WriteFiles(dir, append(objs, objs[0]), WriteOptions{}); err == nil {
+		t.Fatal("expect an error for objects written to the same file")
+	}
+}
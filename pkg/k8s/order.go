@@ -0,0 +1,74 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package k8s
+
+import "sort"
+
+// ApplyOrder is the order Sort puts the kinds in. Namespaces and custom
+// resource definitions come first, so the objects in them can be created,
+// and admission webhooks last, so they do not reject the other objects
+// while their backends are not running yet. Kinds not listed, e.g. custom
+// resources, are put after the listed kinds, but before the webhooks.
+var ApplyOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"PriorityClass",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+// webhookKinds are applied after every other kind.
+var webhookKinds = []string{
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+	"ValidatingAdmissionPolicy",
+	"ValidatingAdmissionPolicyBinding",
+}
+
+// Sort sorts the objects into apply order, see ApplyOrder. The sort is
+// stable, objects of the same kind keep their order.
+func Sort(objs []Object) {
+	rank := make(map[string]int, len(ApplyOrder)+len(webhookKinds))
+	for i, kind := range ApplyOrder {
+		rank[kind] = i
+	}
+	unknown := len(ApplyOrder)
+	for i, kind := range webhookKinds {
+		rank[kind] = unknown + 1 + i
+	}
+	rankOf := func(o Object) int {
+		if r, ok := rank[o.Kind()]; ok {
+			return r
+		}
+		return unknown
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return rankOf(objs[i]) < rankOf(objs[j])
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WriteOptions controls the files written by WriteFiles.
+type WriteOptions struct {
+	// Format is the file format, "yaml" or "json". The default is "yaml".
+	Format string
+	// OrderPrefix prefixes the file names with the index of the object,
+	// e.g. "000-namespace-app.yaml", so the files sort in the order of
+	// the objects. Use it after Sort to keep the apply order.
+	OrderPrefix bool
+}
+
+var fileNameInvalidRegexp = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// WriteFiles writes every object to a file in dir, which is created if
+// needed. Namespaced objects are written into a sub directory named by
+// the namespace, e.g. "default/deployment-nginx.yaml", cluster scoped
+// objects into dir itself. It returns the paths of the written files.
+func WriteFiles(dir string, objs []Object, opts WriteOptions) ([]string, error) {
+	format := opts.Format
+	if format == "" {
+		format = "yaml"
+	}
+	if format != "yaml" && format != "json" {
+		return nil, fmt.Errorf("k8s: unsupported file format %q, expect yaml or json", format)
+	}
+
+	var files []string
+	written := make(map[string]int)
+	for i, o := range objs {
+		name := o.Name()
+		if name == "" {
+			name = o.metadata().str("generateName")
+		}
+		base := fileNameInvalidRegexp.ReplaceAllString(strings.ToLower(o.Kind()+"-"+name), "_")
+		if opts.OrderPrefix {
+			base = fmt.Sprintf("%03d-%s", i, base)
+		}
+		path := filepath.Join(dir, fileNameInvalidRegexp.ReplaceAllString(strings.ToLower(o.Namespace()), "_"), base+"."+format)
+		if j, ok := written[path]; ok {
+			return files, fmt.Errorf("k8s: objects %d (%s) and %d (%s) are both written to %s", j, objs[j], i, o, path)
+		}
+		written[path] = i
+
+		data, err := encodeObject(o, format)
+		if err != nil {
+			return files, fmt.Errorf("k8s: object %d (%s): %w", i, o, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return files, err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func encodeObject(o Object, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	if format == "json" {
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any(o)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(map[string]any(o)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -5,8 +5,8 @@ package kcl
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -14,7 +14,6 @@ import (
 	"strings"
 
 	"github.com/chai2010/jsonv"
-	"github.com/mitchellh/mapstructure"
 	"gopkg.in/yaml.v3"
 
 	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
@@ -27,6 +26,8 @@ type KCLResultList struct {
 	list            []KCLResult
 	raw_json_result string
 	raw_yaml_result string
+	// unredacted is the result without redaction, see WithRedaction.
+	unredacted *KCLResultList
 }
 
 // ToString returns the result as string.
@@ -172,6 +173,18 @@ func (p *KCLResultList) GetRawYamlResult() string {
 // KCLResult denotes the result for the Run API.
 type KCLResult struct {
 	result any
+	// document is the YAML document of the result, used to keep the key
+	// order of the KCL output, see Encode.
+	document string
+	// jsonDocument is the JSON value of the result in the JsonResult, the
+	// numbers of a result with a number mode are decoded from it.
+	jsonDocument string
+	// source is the program of the result, see Origin.
+	source *resultSource
+	// unredacted is the result without redaction, see WithRedaction.
+	unredacted *KCLResult
+	// numberMode is the decoding of the numbers, see WithNumberMode.
+	numberMode NumberMode
 }
 
 // NewResult constructs a KCLResult using the value
@@ -259,6 +272,10 @@ func (p *KCLResult) ToType(target any) error {
 	return nil
 }
 
+// Get returns the value at the dotted key. With a target, a mapping is
+// decoded into target[0] with its `mapstructure` tags, and target[0] is
+// returned, or the raw value if it fails. Use GetValue or Decode to get the
+// decoding error.
 func (m *KCLResult) Get(key string, target ...any) any {
 	ss := strings.Split(key, ".")
 	if len(ss) == 0 {
@@ -275,7 +292,7 @@ func (m *KCLResult) Get(key string, target ...any) any {
 	}
 
 	if m, ok := rv.(map[string]any); ok {
-		if err := mapstructure.Decode(m, target[0]); err == nil {
+		if err := decodeValue(m, target[0], DecodeOptions{TagName: "mapstructure"}); err == nil {
 			return target[0]
 		}
 	}
@@ -304,11 +321,10 @@ func (m *KCLResult) GetValue(key string, target ...any) (value any, err error) {
 
 	switch rv := rv.(type) {
 	case map[string]any:
-		if err := mapstructure.Decode(rv, target[0]); err == nil {
-			return target[0], nil
+		if err := decodeValue(rv, target[0], DecodeOptions{TagName: "mapstructure"}); err != nil {
+			return rv, err
 		}
-
-		return rv, err
+		return target[0], nil
 	case string:
 		if pv, ok2 := target[0].(*string); ok2 {
 			*pv = rv
@@ -334,17 +350,29 @@ func (m *KCLResult) GetValue(key string, target ...any) (value any, err error) {
 		default:
 			return "", fmt.Errorf("%s expect *float64 or *int type: got = %T", key, target[0])
 		}
+	case int64, json.Number:
+		return numberInto(key, rv, target[0])
 	default:
 		return rv, fmt.Errorf("unknown type: got = %T", target[0])
 	}
 }
 
 func (m *KCLResult) YAMLString() string {
+	if m.numberMode != NumberModeDefault && m.document != "" {
+		if s, ok := m.literalYAMLString(); ok {
+			return s
+		}
+	}
 	out, _ := yaml.Marshal(m.result)
 	return string(out)
 }
 
 func (m *KCLResult) JSONString() string {
+	if m.numberMode != NumberModeDefault && m.document != "" {
+		if s, ok := m.literalJSONString(); ok {
+			return s
+		}
+	}
 	var prefix = ""
 	var indent = "    "
 	x, _ := json.MarshalIndent(m.result, prefix, indent)
@@ -361,7 +389,13 @@ func MustRun(path string, opts ...Option) *KCLResultList {
 }
 
 func Run(path string, opts ...Option) (*KCLResultList, error) {
-	return run([]string{path}, opts...)
+	return run(context.Background(), []string{path}, opts...)
+}
+
+// RunContext is like Run but returns a *CancelError when ctx is cancelled or
+// its deadline passes before the KCL program finishes.
+func RunContext(ctx context.Context, path string, opts ...Option) (*KCLResultList, error) {
+	return run(ctx, []string{path}, opts...)
 }
 
 // RunWithOpts is the same as Run, but it does not require a path as input.
@@ -369,11 +403,17 @@ func Run(path string, opts ...Option) (*KCLResultList, error) {
 // or the workdir in method WorkDir(),
 // or it will return an error.
 func RunWithOpts(opts ...Option) (*KCLResultList, error) {
-	return run([]string{}, opts...)
+	return run(context.Background(), []string{}, opts...)
 }
 
 func RunFiles(paths []string, opts ...Option) (*KCLResultList, error) {
-	return run(paths, opts...)
+	return run(context.Background(), paths, opts...)
+}
+
+// RunFilesContext is like RunFiles but returns a *CancelError when ctx is
+// cancelled or its deadline passes before the KCL program finishes.
+func RunFilesContext(ctx context.Context, paths []string, opts ...Option) (*KCLResultList, error) {
+	return run(ctx, paths, opts...)
 }
 
 func getValues(myMap map[string]*gpyrpc.KclType) []*gpyrpc.KclType {
@@ -386,7 +426,9 @@ func getValues(myMap map[string]*gpyrpc.KclType) []*gpyrpc.KclType {
 
 func ExecResultToKCLResult(o *Option, resp *gpyrpc.ExecProgramResult, logger io.Writer, hooks Hooks) (*KCLResultList, error) {
 	for _, hook := range hooks {
-		hook.Do(o, resp)
+		if err := hook.Do(o, resp); err != nil {
+			return nil, err
+		}
 	}
 	if logger != nil && resp.LogMessage != "" {
 		_, err := logger.Write([]byte(resp.LogMessage))
@@ -395,7 +437,7 @@ func ExecResultToKCLResult(o *Option, resp *gpyrpc.ExecProgramResult, logger io.
 		}
 	}
 	if resp.ErrMessage != "" {
-		return nil, errors.New(resp.ErrMessage)
+		return nil, NewError(resp.ErrMessage)
 	}
 
 	var result KCLResultList
@@ -408,13 +450,49 @@ func ExecResultToKCLResult(o *Option, resp *gpyrpc.ExecProgramResult, logger io.
 		return &result, nil
 	}
 
-	for _, d := range documents {
+	var source *resultSource
+	if o != nil && o.fsys == nil {
+		source = newResultSource(o)
+	}
+	var numberMode NumberMode
+	if o != nil {
+		numberMode = o.numberMode
+	}
+	var jsonDocs []json.RawMessage
+	if numberMode != NumberModeDefault {
+		var ok bool
+		if jsonDocs, ok = jsonDocuments(resp.JsonResult, len(documents)); !ok {
+			jsonDocs = nil
+		}
+	}
+	for i, d := range documents {
 		var m any
-		if err := yaml.Unmarshal([]byte(d), &m); err != nil {
-			return nil, err
+		var jsonDoc string
+		switch {
+		case numberMode == NumberModeDefault:
+			if err := yaml.Unmarshal([]byte(d), &m); err != nil {
+				return nil, err
+			}
+		case jsonDocs != nil:
+			jsonDoc = string(jsonDocs[i])
+			if m, err = decodeJSONNumbers(jsonDocs[i], numberMode); err != nil {
+				return nil, err
+			}
+		default:
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(d), &node); err != nil {
+				return nil, err
+			}
+			if m, err = decodeNumberNode(&node, numberMode); err != nil {
+				return nil, err
+			}
 		}
 		result.list = append(result.list, KCLResult{
-			result: m,
+			result:       m,
+			document:     d,
+			jsonDocument: jsonDoc,
+			source:       source,
+			numberMode:   numberMode,
 		})
 	}
 	buffer := bytes.NewBuffer(nil)
@@ -427,22 +505,115 @@ func ExecResultToKCLResult(o *Option, resp *gpyrpc.ExecProgramResult, logger io.
 	return &result, nil
 }
 
-func runWithHooks(pathList []string, hooks Hooks, opts ...Option) (*KCLResultList, error) {
+func runWithHooks(ctx context.Context, pathList []string, hooks Hooks, opts ...Option) (*KCLResultList, error) {
 	args, err := ParseArgs(pathList, opts...)
 	if err != nil {
 		return nil, err
 	}
+	return runArgs(ctx, args, hooks)
+}
+
+// runArgs runs the program of the parsed arguments, see ParseArgs.
+func runArgs(ctx context.Context, args Option, hooks Hooks) (*KCLResultList, error) {
+	err := runPreExecHooks(&args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withOptionTimeout(ctx, &args)
+	defer cancel()
+
+	var key string
+	var workspace *fsWorkspace
+	var source *resultSource
+	if args.fsys != nil {
+		// The source must be read from the file system, not from the
+		// temporary copy removed after the run.
+		source = newResultSource(&args)
+		program, err := loadFSProgram(&args)
+		if err != nil {
+			return nil, err
+		}
+		// The key is computed before the arguments point to the temporary
+		// copy, which is a new directory on every run.
+		if args.cache != nil {
+			key = runCacheKey(&args, program)
+		}
+		if workspace, err = program.prepare(&args); err != nil {
+			return nil, err
+		}
+		defer workspace.Close()
+	} else if args.cache != nil {
+		key = runCacheKey(&args, nil)
+	}
+	if args.strictOptions {
+		if err := checkStrictOptions(ctx, &args); err != nil {
+			return nil, err
+		}
+	}
+
+	var resp *gpyrpc.ExecProgramResult
+	if key != "" {
+		resp, _ = args.cache.Get(key)
+	}
+	if resp == nil {
+		svc := ContextService(ctx, args.GetService())
+		resp, err = CallContext(ctx, "Run", func() (*gpyrpc.ExecProgramResult, error) {
+			return svc.ExecProgram(args.ExecProgramArgs)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if workspace != nil {
+			workspace.rewriteResult(resp)
+		}
+		if key != "" && resp.ErrMessage == "" {
+			args.cache.Set(key, resp)
+		}
+	}
+	hooks = append(hooks[:len(hooks):len(hooks)], args.postResultHooks...)
+	result, err := execResultList(&args, resp, hooks)
+	if err != nil {
+		return nil, err
+	}
+	if source != nil {
+		for _, list := range []*KCLResultList{result, result.Unredacted()} {
+			for i := range list.list {
+				list.list[i].source = source
+			}
+		}
+	}
+	return result, nil
+}
 
-	svc := Service()
-	resp, err := svc.ExecProgram(args.ExecProgramArgs)
+// runCacheKey returns the cache key of the run described by o, or "" when
+// its inputs can not be hashed. The programs are then run uncached.
+func runCacheKey(o *Option, program *fsProgram) string {
+	key, err := cacheKey(o, program)
+	if err != nil {
+		if logger := o.GetLogger(); logger != nil {
+			fmt.Fprintf(logger, "kcl: the run is not cached: %v\n", err)
+		}
+		return ""
+	}
+	return key
+}
+
+// execResultList converts resp into the result list of o, redacted when o
+// has a WithRedaction option.
+func execResultList(o *Option, resp *gpyrpc.ExecProgramResult, hooks Hooks) (*KCLResultList, error) {
+	if o.redaction == nil {
+		return ExecResultToKCLResult(o, resp, o.GetLogger(), hooks)
+	}
+	r, err := newRedactor(o.redaction, o.ExecProgramArgs)
 	if err != nil {
 		return nil, err
 	}
-	return ExecResultToKCLResult(&args, resp, args.GetLogger(), hooks)
+	return r.redactRun(o, resp, hooks)
 }
 
-func run(pathList []string, opts ...Option) (*KCLResultList, error) {
-	return runWithHooks(pathList, DefaultHooks, opts...)
+func run(ctx context.Context, pathList []string, opts ...Option) (*KCLResultList, error) {
+	return runWithHooks(ctx, pathList, DefaultHooks, opts...)
 }
 
 // SplitDocuments returns a slice of all documents contained in a YAML string. Multiple documents can be divided by the
@@ -0,0 +1,184 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+// Artifact is a compiled KCL program. It can be evaluated many times with
+// different options, e.g. different `-D` top level arguments, without
+// compiling the program again. An Artifact is safe for concurrent use.
+type Artifact struct {
+	path string
+	// opt holds the options the artifact was built or loaded with, they
+	// are merged before the options given to Run.
+	opt Option
+
+	mu sync.Mutex
+	// tmpDir is the directory owned by the artifact, removed by Close.
+	tmpDir string
+}
+
+// Build compiles the KCL program with path and opts into an Artifact stored
+// in a temporary directory. Call Save to keep the artifact and Close to
+// remove the temporary directory.
+func Build(path string, opts ...Option) (*Artifact, error) {
+	return BuildContext(context.Background(), path, opts...)
+}
+
+// BuildContext is like Build but returns a *CancelError when ctx is cancelled
+// or its deadline passes before the program is compiled.
+func BuildContext(ctx context.Context, path string, opts ...Option) (*Artifact, error) {
+	args, err := ParseArgs([]string{path}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := runPreExecHooks(&args); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withOptionTimeout(ctx, &args)
+	defer cancel()
+
+	if args.fsys != nil {
+		workspace, err := prepareFS(&args)
+		if err != nil {
+			return nil, err
+		}
+		defer workspace.Close()
+	}
+
+	tmpDir, err := os.MkdirTemp("", "kcl-artifact-")
+	if err != nil {
+		return nil, err
+	}
+	svc := ContextService(ctx, args.GetService())
+	resp, err := CallContext(ctx, "Build", func() (*gpyrpc.BuildProgramResult, error) {
+		return svc.BuildProgram(&gpyrpc.BuildProgramArgs{
+			ExecArgs: args.ExecProgramArgs,
+			Output:   filepath.Join(tmpDir, "program"),
+		})
+	})
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	if resp.Path == "" {
+		os.RemoveAll(tmpDir)
+		return nil, fmt.Errorf("kcl.Build: no artifact built for %s", path)
+	}
+	// The pre exec hooks already ran on the arguments of the artifact.
+	args.preExecHooks = nil
+	return &Artifact{path: resp.Path, opt: args, tmpDir: tmpDir}, nil
+}
+
+// LoadArtifact returns the Artifact saved at path, see Artifact.Save. The
+// opts are used as the default options of every Run.
+func LoadArtifact(path string, opts ...Option) (*Artifact, error) {
+	opt := NewOption().Merge(opts...)
+	if opt.Err != nil {
+		return nil, opt.Err
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, fmt.Errorf("kcl.LoadArtifact: %s is a directory", path)
+	}
+	return &Artifact{path: path, opt: *opt}, nil
+}
+
+// Path returns the file path of the artifact.
+func (a *Artifact) Path() string {
+	return a.path
+}
+
+// Save copies the artifact to path, which can be loaded by LoadArtifact
+// later, also in another process. The artifact must be loaded by the same
+// KCL version and on the same platform it was built on.
+func (a *Artifact) Save(path string) (err error) {
+	src, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// Close removes the temporary directory of an artifact returned by Build.
+// It is a no-op for an artifact returned by LoadArtifact.
+func (a *Artifact) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.tmpDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(a.tmpDir)
+	a.tmpDir = ""
+	return err
+}
+
+// Run evaluates the artifact. The opts are merged after the options the
+// artifact was built with, so `-D` arguments given by WithOptions override
+// the ones given to Build. Options changing the program itself, such as
+// WithKFilenames or WithCode, have no effect. The pre exec hooks given to
+// Build ran once when the program was built, the ones given to LoadArtifact
+// and Run run on every Run.
+func (a *Artifact) Run(opts ...Option) (*KCLResultList, error) {
+	return a.RunContext(context.Background(), opts...)
+}
+
+// RunContext is like Run but returns a *CancelError when ctx is cancelled
+// or its deadline passes before the artifact finishes.
+func (a *Artifact) RunContext(ctx context.Context, opts ...Option) (*KCLResultList, error) {
+	args := NewOption().Merge(a.opt).Merge(opts...)
+	if args.Err != nil {
+		return nil, args.Err
+	}
+	if err := runPreExecHooks(args); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withOptionTimeout(ctx, args)
+	defer cancel()
+
+	svc := ContextService(ctx, args.GetService())
+	resp, err := CallContext(ctx, "Run", func() (*gpyrpc.ExecProgramResult, error) {
+		return svc.ExecArtifact(&gpyrpc.ExecArtifactArgs{
+			Path:     a.path,
+			ExecArgs: args.ExecProgramArgs,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	hooks := append(DefaultHooks[:len(DefaultHooks):len(DefaultHooks)], args.postResultHooks...)
+	return execResultList(args, resp, hooks)
+}
@@ -0,0 +1,96 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/kcl-go/pkg/utils"
+	"kcl-lang.io/lib/go/api"
+)
+
+// artifactService answers ExecArtifact with the value of the first option.
+type artifactService struct {
+	api.ServiceClient
+	calls int
+	args  []*gpyrpc.Argument
+}
+
+func (s *artifactService) BuildProgram(args *gpyrpc.BuildProgramArgs) (*gpyrpc.BuildProgramResult, error) {
+	return &gpyrpc.BuildProgramResult{Path: args.Output}, nil
+}
+
+func (s *artifactService) ExecArtifact(args *gpyrpc.ExecArtifactArgs) (*gpyrpc.ExecProgramResult, error) {
+	s.calls++
+	s.args = args.ExecArgs.Args
+	value := args.ExecArgs.Args[0].Value
+	return &gpyrpc.ExecProgramResult{
+		JsonResult: `{"token": "` + value + `"}`,
+		YamlResult: "token: " + value + "\n",
+	}, nil
+}
+
+func TestArtifactSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	tmpDir := filepath.Join(dir, "tmp")
+	tAssert(t, os.MkdirAll(tmpDir, 0o755) == nil)
+	path := filepath.Join(tmpDir, "program")
+	tAssert(t, os.WriteFile(path, []byte("artifact"), 0o644) == nil)
+
+	a := &Artifact{path: path, tmpDir: tmpDir}
+	saved := filepath.Join(dir, "out", "main.artifact")
+	tAssert(t, a.Save(saved) == nil)
+
+	loaded, err := LoadArtifact(saved, WithOptions("key=value"))
+	tAssert(t, err == nil, err)
+	tAssert(t, loaded.Path() == saved, loaded.Path())
+	tAssert(t, len(loaded.opt.Args) == 1 && loaded.opt.Args[0].Name == "key", loaded.opt.Args)
+
+	data, err := os.ReadFile(loaded.Path())
+	tAssert(t, err == nil, err)
+	tAssert(t, string(data) == "artifact", string(data))
+
+	// Close removes the temporary directory of a built artifact only.
+	tAssert(t, a.Close() == nil)
+	tAssert(t, !utils.DirExists(tmpDir), "expect the temporary directory to be removed")
+	tAssert(t, loaded.Close() == nil)
+	tAssert(t, utils.FileExists(saved), "expect the saved artifact to be kept")
+
+	_, err = LoadArtifact(dir)
+	tAssert(t, err != nil, "expect an error loading a directory")
+}
+
+func TestArtifactRedaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "program")
+	tAssert(t, os.WriteFile(path, []byte("artifact"), 0o644) == nil)
+
+	svc := &artifactService{}
+	a, err := LoadArtifact(path, WithService(svc), WithRedaction(RedactOptions{OptionNames: []string{"token"}}))
+	tAssert(t, err == nil, err)
+	result, err := a.Run(WithOptions("token=s3cr3t"))
+	tAssert(t, err == nil, err)
+	tAssert(t, result.First().Get("token") == RedactedValue, result.First().JSONString())
+	tAssert(t, result.Unredacted().First().Get("token") == "s3cr3t", result.Unredacted())
+}
+
+func TestArtifactPreExecHooks(t *testing.T) {
+	var calls int
+	hook := PreExecHookFunc(func(o *Option, args *gpyrpc.ExecProgramArgs) error {
+		calls++
+		args.Args = append(args.Args, &gpyrpc.Argument{Name: "token", Value: "t"})
+		return nil
+	})
+	svc := &artifactService{}
+	a, err := Build("main.k", WithService(svc), WithPreExecHooks(hook))
+	tAssert(t, err == nil, err)
+	defer a.Close()
+	for i := 0; i < 2; i++ {
+		_, err = a.Run()
+		tAssert(t, err == nil, err)
+	}
+	tAssert(t, calls == 1, calls)
+	tAssert(t, len(svc.args) == 1, svc.args)
+}
@@ -0,0 +1,189 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// BatchJob is a KCL program evaluated by RunBatch.
+type BatchJob struct {
+	// Name identifies the job in results and progress reports, e.g. "prod/app".
+	Name string
+	// Paths are the KCL files or directories of the program, as for RunFiles.
+	Paths []string
+	// Options are the options of the run.
+	Options []Option
+}
+
+// BatchOptions controls how RunBatch evaluates its jobs.
+type BatchOptions struct {
+	// Concurrency is the maximum number of jobs evaluated at the same time.
+	// The default is runtime.GOMAXPROCS(0).
+	Concurrency int
+	// FailFast stops starting new jobs after the first failed job and
+	// cancels the jobs which are still running.
+	FailFast bool
+	// Progress, if not nil, is called after each job finishes. Calls are
+	// serialized, so the callback does not need to be safe for concurrent use.
+	Progress func(p BatchProgress)
+}
+
+// BatchResult is the outcome of a single BatchJob.
+type BatchResult struct {
+	// Index is the index of the job in the RunBatch input.
+	Index int
+	Job   BatchJob
+	// Result is nil when Err is not nil.
+	Result *KCLResultList
+	Err    error
+	// Skipped reports that the job was never started because the batch
+	// was cancelled or stopped by FailFast. Err is a *CancelError then.
+	Skipped bool
+	// Cancelled reports that the job was running when the batch was
+	// cancelled or stopped by FailFast, and returned the context error.
+	Cancelled bool
+	Duration  time.Duration
+}
+
+// BatchProgress is passed to BatchOptions.Progress after each job finishes.
+type BatchProgress struct {
+	// Done is the number of finished jobs, including the reported one.
+	Done  int
+	Total int
+	// Result is the result of the job which just finished.
+	Result *BatchResult
+}
+
+// BatchSummary summarizes the results of a RunBatch.
+type BatchSummary struct {
+	Total     int
+	Succeeded int
+	Failed    int
+	Cancelled int
+	Skipped   int
+	// Duration is the wall time of the whole batch.
+	Duration time.Duration
+}
+
+// BatchReport is returned by RunBatch.
+type BatchReport struct {
+	// Results has one entry per job, in input order.
+	Results []BatchResult
+	Summary BatchSummary
+}
+
+// Failed returns the results of the jobs which failed, were cancelled or
+// were skipped, in input order.
+func (r *BatchReport) Failed() []BatchResult {
+	var failed []BatchResult
+	for _, x := range r.Results {
+		if x.Err != nil {
+			failed = append(failed, x)
+		}
+	}
+	return failed
+}
+
+// RunBatch evaluates jobs concurrently and returns their results in input
+// order. A failed job does not stop the others unless opts.FailFast is set.
+// When ctx is cancelled, or a job fails with opts.FailFast, running jobs are
+// reported as cancelled and jobs not yet started as skipped.
+func RunBatch(ctx context.Context, jobs []BatchJob, opts BatchOptions) *BatchReport {
+	return runBatch(ctx, jobs, opts, func(ctx context.Context, job BatchJob) (*KCLResultList, error) {
+		return run(ctx, job.Paths, job.Options...)
+	})
+}
+
+func runBatch(ctx context.Context, jobs []BatchJob, opts BatchOptions,
+	runJob func(ctx context.Context, job BatchJob) (*KCLResultList, error),
+) *BatchReport {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	report := &BatchReport{Results: make([]BatchResult, len(jobs))}
+	start := time.Now()
+
+	var mu sync.Mutex
+	var done int
+	finish := func(r *BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if r.Err != nil && opts.FailFast {
+			cancel()
+		}
+		if opts.Progress != nil {
+			opts.Progress(BatchProgress{Done: done, Total: len(jobs), Result: r})
+		}
+	}
+
+	var limit = make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		r := &report.Results[i]
+		r.Index, r.Job = i, job
+
+		acquired := false
+		select {
+		case limit <- struct{}{}:
+			acquired = true
+		case <-ctx.Done():
+		}
+		// Checked after acquiring a slot too, as select picks randomly.
+		if err := ctx.Err(); err != nil {
+			if acquired {
+				<-limit
+			}
+			r.Err = &CancelError{Op: "RunBatch", Err: err}
+			r.Skipped = true
+			finish(r)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+			t := time.Now()
+			r.Result, r.Err = runJob(ctx, r.Job)
+			r.Duration = time.Since(t)
+			if r.Err != nil {
+				r.Result = nil
+				// Stopped by the batch rather than failed on its own.
+				r.Cancelled = ctx.Err() != nil && errors.Is(r.Err, ctx.Err())
+			}
+			finish(r)
+		}()
+	}
+	wg.Wait()
+
+	report.Summary = BatchSummary{Total: len(jobs), Duration: time.Since(start)}
+	for _, r := range report.Results {
+		switch {
+		case r.Skipped:
+			report.Summary.Skipped++
+		case r.Cancelled:
+			report.Summary.Cancelled++
+		case r.Err != nil:
+			report.Summary.Failed++
+		default:
+			report.Summary.Succeeded++
+		}
+	}
+	return report
+}
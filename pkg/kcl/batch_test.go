@@ -0,0 +1,97 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBatch(t *testing.T) {
+	var jobs []BatchJob
+	for i := 0; i < 20; i++ {
+		jobs = append(jobs, BatchJob{Name: fmt.Sprint(i)})
+	}
+
+	var running, maxRunning atomic.Int32
+	var progress []int
+	report := runBatch(context.Background(), jobs, BatchOptions{
+		Concurrency: 3,
+		Progress: func(p BatchProgress) {
+			tAssert(t, p.Total == len(jobs), p.Total)
+			progress = append(progress, p.Done)
+		},
+	}, func(ctx context.Context, job BatchJob) (*KCLResultList, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		if job.Name == "7" {
+			return nil, errors.New("job 7 failed")
+		}
+		return &KCLResultList{list: []KCLResult{NewResult(job.Name)}}, nil
+	})
+
+	tAssert(t, maxRunning.Load() <= 3, maxRunning.Load())
+	tAssert(t, len(progress) == len(jobs) && progress[len(jobs)-1] == len(jobs), progress)
+	for i, r := range report.Results {
+		tAssert(t, r.Index == i && r.Job.Name == fmt.Sprint(i), r)
+		if i == 7 {
+			tAssert(t, r.Err != nil && r.Result == nil, r)
+			continue
+		}
+		s, err := r.Result.ToString()
+		tAssert(t, err == nil && s == fmt.Sprint(i), s, err)
+		tAssert(t, r.Duration > 0, r.Duration)
+	}
+	tAssert(t, report.Summary.Total == 20 && report.Summary.Succeeded == 19 && report.Summary.Failed == 1, report.Summary)
+	tAssert(t, len(report.Failed()) == 1 && report.Failed()[0].Index == 7, report.Failed())
+}
+
+func TestRunBatchFailFast(t *testing.T) {
+	jobs := make([]BatchJob, 10)
+	report := runBatch(context.Background(), jobs, BatchOptions{
+		Concurrency: 1,
+		FailFast:    true,
+	}, func(ctx context.Context, job BatchJob) (*KCLResultList, error) {
+		return nil, errors.New("failed")
+	})
+
+	tAssert(t, report.Results[0].Err != nil && !report.Results[0].Skipped, report.Results[0])
+	for _, r := range report.Results[1:] {
+		var cancelErr *CancelError
+		tAssert(t, r.Skipped && errors.As(r.Err, &cancelErr), r)
+	}
+	tAssert(t, report.Summary.Failed == 1 && report.Summary.Skipped == 9, report.Summary)
+}
+
+func TestRunBatchFailFastCancelled(t *testing.T) {
+	jobs := []BatchJob{{Name: "fail"}, {Name: "slow"}, {Name: "next"}}
+	started := make(chan struct{})
+	report := runBatch(context.Background(), jobs, BatchOptions{
+		Concurrency: 2,
+		FailFast:    true,
+	}, func(ctx context.Context, job BatchJob) (*KCLResultList, error) {
+		if job.Name == "slow" {
+			close(started)
+			<-ctx.Done()
+			return nil, &CancelError{Op: "Run", Err: ctx.Err()}
+		}
+		<-started
+		return nil, errors.New("failed")
+	})
+
+	tAssert(t, report.Results[0].Err != nil && !report.Results[0].Cancelled, report.Results[0])
+	tAssert(t, report.Results[1].Cancelled && !report.Results[1].Skipped, report.Results[1])
+	tAssert(t, report.Results[2].Skipped, report.Results[2])
+	tAssert(t, report.Summary.Failed == 1 && report.Summary.Cancelled == 1 && report.Summary.Skipped == 1, report.Summary)
+}
@@ -0,0 +1,413 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	tools_list "kcl-lang.io/kcl-go/pkg/tools/list"
+	"kcl-lang.io/kcl-go/pkg/utils"
+	"kcl-lang.io/lib/go/api"
+	"kcl-lang.io/lib/go/native"
+)
+
+// Cache stores ExecProgramResult values by a content-addressed key. The key
+// covers every input file, the merged ExecProgramArgs and the KCL version,
+// so a hit means the program would produce the same result. Only results
+// without an ErrMessage are stored.
+//
+// Cached programs must be hermetic: results depending on the environment,
+// the network or plugins are not tracked by the key.
+type Cache interface {
+	Get(key string) (*gpyrpc.ExecProgramResult, bool)
+	Set(key string, result *gpyrpc.ExecProgramResult)
+	Stats() CacheStats
+}
+
+// CacheStats reports the hit and miss counters of a Cache.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// WithCache returns a Option which hold a result cache.
+func WithCache(cache Cache) Option {
+	var opt = NewOption()
+	opt.cache = cache
+	return *opt
+}
+
+// MemoryCache is an in-memory LRU Cache, it is safe for concurrent use.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+}
+
+type memoryCacheEntry struct {
+	key    string
+	result *gpyrpc.ExecProgramResult
+}
+
+// NewMemoryCache returns a MemoryCache holding at most capacity results,
+// a capacity <= 0 means no limit.
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(key string) (*gpyrpc.ExecProgramResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		c.hits.Add(1)
+		return cloneExecResult(e.Value.(*memoryCacheEntry).result), true
+	}
+	c.misses.Add(1)
+	return nil, false
+}
+
+func (c *MemoryCache) Set(key string, result *gpyrpc.ExecProgramResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*memoryCacheEntry).result = cloneExecResult(result)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&memoryCacheEntry{key: key, result: cloneExecResult(result)})
+	if c.capacity > 0 && c.ll.Len() > c.capacity {
+		if e := c.ll.Back(); e != nil {
+			c.ll.Remove(e)
+			delete(c.items, e.Value.(*memoryCacheEntry).key)
+		}
+	}
+}
+
+// Len returns the number of cached results.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *MemoryCache) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// DirCache is a Cache storing each result as a JSON file in a directory, so
+// results survive across processes. It is safe for concurrent use.
+type DirCache struct {
+	dir    string
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewDirCache returns a DirCache in dir, creating the directory if needed.
+func NewDirCache(dir string) (*DirCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirCache{dir: dir}, nil
+}
+
+func (c *DirCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+func (c *DirCache) Get(key string) (*gpyrpc.ExecProgramResult, bool) {
+	if len(key) < 2 {
+		c.misses.Add(1)
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+	var entry dirCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return &gpyrpc.ExecProgramResult{
+		JsonResult: entry.JsonResult,
+		YamlResult: entry.YamlResult,
+		LogMessage: entry.LogMessage,
+	}, true
+}
+
+func (c *DirCache) Set(key string, result *gpyrpc.ExecProgramResult) {
+	if len(key) < 2 || result == nil {
+		return
+	}
+	data, err := json.Marshal(&dirCacheEntry{
+		JsonResult: result.JsonResult,
+		YamlResult: result.YamlResult,
+		LogMessage: result.LogMessage,
+	})
+	if err != nil {
+		return
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	// Write to a temp file first so concurrent readers never see a partial entry.
+	f, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (c *DirCache) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+type dirCacheEntry struct {
+	JsonResult string `json:"json_result"`
+	YamlResult string `json:"yaml_result"`
+	LogMessage string `json:"log_message,omitempty"`
+}
+
+func cloneExecResult(r *gpyrpc.ExecProgramResult) *gpyrpc.ExecProgramResult {
+	if r == nil {
+		return nil
+	}
+	return &gpyrpc.ExecProgramResult{
+		JsonResult: r.JsonResult,
+		YamlResult: r.YamlResult,
+		LogMessage: r.LogMessage,
+		ErrMessage: r.ErrMessage,
+	}
+}
+
+// cacheVersions memoizes the versions of the services, see cacheVersion.
+var cacheVersions sync.Map
+
+// cacheVersion returns the KCL version of svc hashed in the cache keys, so
+// two services never share a key. Only the successful lookups are memoized.
+func cacheVersion(svc api.ServiceClient) (string, error) {
+	key, ok := cacheServiceKey(svc)
+	if ok {
+		if v, found := cacheVersions.Load(key); found {
+			return v.(string), nil
+		}
+	}
+	v, err := svc.GetVersion(&gpyrpc.GetVersionArgs{})
+	if err != nil {
+		return "", err
+	}
+	version := v.VersionInfo + "/" + v.Checksum
+	if ok {
+		cacheVersions.Store(key, version)
+	}
+	return version, nil
+}
+
+// cacheServiceKey returns the key of svc in cacheVersions. The native
+// services all call the library of the process, other services are their
+// own key when they are comparable.
+func cacheServiceKey(svc api.ServiceClient) (any, bool) {
+	if _, ok := svc.(*native.NativeServiceClient); ok {
+		return reflect.TypeOf(svc), true
+	}
+	if t := reflect.TypeOf(svc); t == nil || !t.Comparable() {
+		return nil, false
+	}
+	return svc, true
+}
+
+// cacheKey returns the content-addressed cache key of the run described by o.
+// It hashes the KCL version, the merged ExecProgramArgs and every input file:
+// the entry files, the files they depend on within the KCL module and the
+// files of external packages. The files of a program run from a WithFS file
+// system are hashed from the fs.FS, o must then hold the arguments before
+// prepareFS.
+func cacheKey(o *Option, program *fsProgram) (string, error) {
+	version, err := cacheVersion(o.GetService())
+	if err != nil {
+		return "", err
+	}
+	inputs := o.ExecProgramArgs
+	if program != nil {
+		// Only the external packages are read from the local disk.
+		inputs = &gpyrpc.ExecProgramArgs{ExternalPkgs: o.ExternalPkgs}
+	}
+	files, err := cacheInputFiles(inputs)
+	if err != nil {
+		return "", err
+	}
+	argsJSON, err := json.Marshal(o.ExecProgramArgs)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "version:%s\n", version)
+	fmt.Fprintf(h, "args:%s\n", argsJSON)
+	if program != nil {
+		fmt.Fprintf(h, "fs:%s\n", program.src.root)
+		for _, name := range program.files {
+			if err := hashFSFile(h, program.src.fsys, name); err != nil {
+				return "", err
+			}
+		}
+	}
+	for _, file := range files {
+		if err := hashFile(h, file); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fh := sha256.New()
+	if _, err := io.Copy(fh, f); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "file:%s:%x\n", filepath.ToSlash(path), fh.Sum(nil))
+	return err
+}
+
+func hashFSFile(w io.Writer, fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "fsfile:%s:%x\n", name, sha256.Sum256(data))
+	return err
+}
+
+// cacheInputFiles returns the sorted absolute paths of all files the run
+// described by args may read.
+func cacheInputFiles(args *gpyrpc.ExecProgramArgs) ([]string, error) {
+	files := make(map[string]struct{})
+	addDirFiles := func(dir string, recursive bool) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != dir && (!recursive || strings.HasPrefix(d.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, ".k") || d.Name() == "kcl.mod" || d.Name() == "kcl.mod.lock" {
+				files[path] = struct{}{}
+			}
+			return nil
+		})
+	}
+
+	for i, name := range args.KFilenameList {
+		// Files with inline code are hashed as part of the args.
+		if i < len(args.KCodeList) {
+			continue
+		}
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(args.WorkDir, path)
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		pkgDir := path
+		if fi.IsDir() {
+			if err := addDirFiles(path, false); err != nil {
+				return nil, err
+			}
+		} else {
+			files[path] = struct{}{}
+			pkgDir = filepath.Dir(path)
+		}
+
+		deps, err := listDepFiles(pkgDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			files[filepath.FromSlash(dep)] = struct{}{}
+		}
+		if pkgroot, _, err := tools_list.FindPkgInfo(pkgDir); err == nil {
+			for _, name := range []string{"kcl.mod", "kcl.mod.lock"} {
+				if s := filepath.Join(pkgroot, name); utils.FileExists(s) {
+					files[s] = struct{}{}
+				}
+			}
+		}
+	}
+	for _, pkg := range args.ExternalPkgs {
+		if err := addDirFiles(pkg.PkgPath, true); err != nil {
+			return nil, err
+		}
+	}
+
+	var sorted []string
+	for s := range files {
+		sorted = append(sorted, s)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// listDepFiles lists the files the package in dir depends on within its KCL
+// module. A directory outside of any KCL module has no such dependencies.
+func listDepFiles(dir string) ([]string, error) {
+	if _, _, err := tools_list.FindPkgInfo(dir); err != nil {
+		return nil, nil
+	}
+	return tools_list.ListDepFiles(dir, &tools_list.Option{
+		UseAbsPath:        true,
+		FlagAll:           true,
+		IgnoreImportError: true,
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/kcl-go/pkg/utils"
+	"kcl-lang.io/lib/go/api"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+	c.Set("a", &gpyrpc.ExecProgramResult{JsonResult: `{"a": 1}`})
+	c.Set("b", &gpyrpc.ExecProgramResult{JsonResult: `{"b": 1}`})
+
+	// Touch "a" so that "b" is the least recently used entry.
+	r, ok := c.Get("a")
+	tAssert(t, ok && r.JsonResult == `{"a": 1}`, r)
+
+	c.Set("c", &gpyrpc.ExecProgramResult{JsonResult: `{"c": 1}`})
+	tAssert(t, c.Len() == 2, c.Len())
+	_, ok = c.Get("b")
+	tAssert(t, !ok, "expect b to be evicted")
+	_, ok = c.Get("c")
+	tAssert(t, ok, "expect c to be cached")
+
+	stats := c.Stats()
+	tAssert(t, stats.Hits == 2 && stats.Misses == 1, stats)
+
+	// Results are copied in and out of the cache.
+	r.JsonResult = "changed"
+	r, _ = c.Get("a")
+	tAssert(t, r.JsonResult == `{"a": 1}`, r)
+}
+
+func TestDirCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDirCache(dir)
+	tAssert(t, err == nil, err)
+
+	key := "0123456789abcdef"
+	_, ok := c.Get(key)
+	tAssert(t, !ok, "expect a miss on an empty cache")
+
+	c.Set(key, &gpyrpc.ExecProgramResult{JsonResult: `{"a": 1}`, YamlResult: "a: 1"})
+	tAssert(t, utils.FileExists(filepath.Join(dir, "01", key+".json")), "expect a cache file")
+
+	// A new DirCache on the same directory sees the stored result.
+	c2, err := NewDirCache(dir)
+	tAssert(t, err == nil, err)
+	r, ok := c2.Get(key)
+	tAssert(t, ok, "expect a hit")
+	tAssert(t, r.JsonResult == `{"a": 1}` && r.YamlResult == "a: 1", r)
+
+	tAssert(t, c.Stats() == CacheStats{Misses: 1}, c.Stats())
+	tAssert(t, c2.Stats() == CacheStats{Hits: 1}, c2.Stats())
+}
+
+func TestCacheInputFiles(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.k")
+	otherFile := filepath.Join(dir, "other.k")
+	tAssert(t, os.WriteFile(mainFile, []byte("a = 1\n"), 0o644) == nil)
+	tAssert(t, os.WriteFile(otherFile, []byte("b = 1\n"), 0o644) == nil)
+	tAssert(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("#"), 0o644) == nil)
+
+	files, err := cacheInputFiles(&gpyrpc.ExecProgramArgs{KFilenameList: []string{dir}})
+	tAssert(t, err == nil, err)
+	tAssert(t, len(files) == 2 && files[0] == mainFile && files[1] == otherFile, files)
+
+	files, err = cacheInputFiles(&gpyrpc.ExecProgramArgs{
+		WorkDir:       dir,
+		KFilenameList: []string{"main.k"},
+	})
+	tAssert(t, err == nil, err)
+	tAssert(t, len(files) == 1 && files[0] == mainFile, files)
+
+	// Inline code is not read from disk.
+	files, err = cacheInputFiles(&gpyrpc.ExecProgramArgs{
+		KFilenameList: []string{"missing.k"},
+		KCodeList:     []string{"a = 1"},
+	})
+	tAssert(t, err == nil, err)
+	tAssert(t, len(files) == 0, files)
+}
+
+// versionService answers GetVersion with its version, or fails while
+// its version is empty.
+type versionService struct {
+	api.ServiceClient
+	version string
+	calls   int
+}
+
+func (s *versionService) GetVersion(*gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+	s.calls++
+	if s.version == "" {
+		return nil, errors.New("no version")
+	}
+	return &gpyrpc.GetVersionResult{VersionInfo: s.version}, nil
+}
+
+func TestCacheKeyService(t *testing.T) {
+	dir := t.TempDir()
+	tAssert(t, os.WriteFile(filepath.Join(dir, "main.k"), []byte("a = 1\n"), 0o644) == nil)
+	key := func(svc api.ServiceClient) (string, error) {
+		o, err := ParseArgs([]string{filepath.Join(dir, "main.k")}, WithService(svc))
+		tAssert(t, err == nil, err)
+		return cacheKey(&o, nil)
+	}
+
+	// A failed version lookup is retried by the next run.
+	svc := &versionService{}
+	_, err := key(svc)
+	tAssert(t, err != nil, "expect a version error")
+	svc.version = "1"
+	k1, err := key(svc)
+	tAssert(t, err == nil, err)
+	_, err = key(svc)
+	tAssert(t, err == nil && svc.calls == 2, svc.calls, err)
+
+	k2, err := key(&versionService{version: "2"})
+	tAssert(t, err == nil && k1 != k2, "expect the services to have their own keys")
+}
+
+func TestCacheKeyFS(t *testing.T) {
+	svc := &versionService{version: "test"}
+	fsys := fstest.MapFS{
+		"app/main.k": {Data: []byte("a = 1\n")},
+	}
+	key := func() string {
+		t.Helper()
+		o, err := ParseArgs(nil, WithFS(fsys, "app"), WithService(svc))
+		tAssert(t, err == nil, err)
+		program, err := loadFSProgram(&o)
+		tAssert(t, err == nil, err)
+		key, err := cacheKey(&o, program)
+		tAssert(t, err == nil, err)
+		// The temporary copy does not change the key.
+		w, err := program.prepare(&o)
+		tAssert(t, err == nil, err)
+		w.Close()
+		return key
+	}
+	first := key()
+	tAssert(t, key() == first, "expect the same key")
+	fsys["app/main.k"] = &fstest.MapFile{Data: []byte("a = 2\n")}
+	tAssert(t, key() != first, "expect a new key")
+}
@@ -0,0 +1,88 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"context"
+	"fmt"
+
+	"kcl-lang.io/lib/go/api"
+)
+
+// CancelError is returned by the context-aware APIs when the context is
+// cancelled or its deadline passes before the KCL service call returns.
+// It wraps the context error, so errors.Is(err, context.DeadlineExceeded)
+// and errors.Is(err, context.Canceled) work as expected.
+type CancelError struct {
+	// Op is the name of the abandoned operation, e.g. "Run".
+	Op string
+	// Err is the context error, context.Canceled or context.DeadlineExceeded.
+	Err error
+}
+
+func (e *CancelError) Error() string {
+	return fmt.Sprintf("kcl.%s: %v", e.Op, e.Err)
+}
+
+func (e *CancelError) Unwrap() error {
+	return e.Err
+}
+
+// CallContext calls fn and waits until it returns or ctx is done, whichever
+// comes first. When ctx is done first, a *CancelError is returned and the
+// native call is abandoned: it keeps running in the background until the KCL
+// runtime returns, and its result is dropped. A service bound to ctx with
+// ContextService stops the call itself, e.g. a pool.Pool kills its worker.
+func CallContext[T any](ctx context.Context, op string, fn func() (T, error)) (T, error) {
+	var zero T
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return zero, &CancelError{Op: op, Err: err}
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v: v, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		return zero, &CancelError{Op: op, Err: ctx.Err()}
+	}
+}
+
+// ContextService returns svc bound to ctx if it supports it, i.e. it has a
+// WithContext(context.Context) api.ServiceClient method like pool.Pool, and
+// svc itself otherwise.
+func ContextService(ctx context.Context, svc api.ServiceClient) api.ServiceClient {
+	if s, ok := svc.(interface {
+		WithContext(context.Context) api.ServiceClient
+	}); ok && ctx != nil {
+		return s.WithContext(ctx)
+	}
+	return svc
+}
+
+// withOptionTimeout derives a context from ctx which honors the timeout
+// set by WithTimeout. The returned cancel func must always be called.
+func withOptionTimeout(ctx context.Context, o *Option) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if o != nil && o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return context.WithCancel(ctx)
+}
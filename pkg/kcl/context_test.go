@@ -0,0 +1,83 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kcl-lang.io/lib/go/api"
+)
+
+func TestCallContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+	_, err := CallContext(ctx, "Run", func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	tAssert(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	var cancelErr *CancelError
+	tAssert(t, errors.As(err, &cancelErr), err)
+	tAssert(t, cancelErr.Op == "Run", cancelErr.Op)
+}
+
+func TestCallContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	_, err := CallContext(ctx, "Run", func() (int, error) {
+		called = true
+		return 1, nil
+	})
+	tAssert(t, errors.Is(err, context.Canceled), err)
+	tAssert(t, !called, "fn must not be called with a done context")
+}
+
+func TestCallContextResult(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	v, err := CallContext(ctx, "Run", func() (int, error) {
+		return 42, nil
+	})
+	tAssert(t, err == nil, err)
+	tAssert(t, v == 42, v)
+}
+
+func TestWithTimeout(t *testing.T) {
+	opt := NewOption().Merge(WithTimeout(time.Second), WithWorkDir("."))
+	tAssert(t, opt.GetTimeout() == time.Second, opt.GetTimeout())
+
+	ctx, cancel := withOptionTimeout(context.Background(), opt)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	tAssert(t, ok, "expect a deadline from WithTimeout")
+}
+
+// contextService is a service which can be bound to a context.
+type contextService struct {
+	api.ServiceClient
+	ctx context.Context
+}
+
+func (s *contextService) WithContext(ctx context.Context) api.ServiceClient {
+	return &contextService{ctx: ctx}
+}
+
+func TestContextService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bound, ok := ContextService(ctx, &contextService{}).(*contextService)
+	tAssert(t, ok && bound.ctx == ctx, "expect the service bound to ctx")
+
+	svc := &versionService{}
+	tAssert(t, ContextService(ctx, svc) == api.ServiceClient(svc), "expect the service itself")
+}
@@ -0,0 +1,229 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// DecodeOptions controls how a KCL result is decoded into a Go value.
+type DecodeOptions struct {
+	// Strict reports result keys which have no matching Go field and Go
+	// fields which have no matching result key. Fields tagged with
+	// `omitempty` or `-` are never reported as missing.
+	Strict bool
+	// TagName is the struct tag used to match result keys with Go fields,
+	// e.g. "json" or "yaml". The default is "json". Fields without the tag
+	// are matched by their name, case-insensitively.
+	TagName string
+}
+
+// DecodeError is returned by Decode in strict mode. All field paths are
+// full key paths from the document root, e.g. `spec.containers[0].image`.
+type DecodeError struct {
+	// UnknownFields are result keys with no matching Go field.
+	UnknownFields []string
+	// MissingFields are Go fields with no matching result key.
+	MissingFields []string
+}
+
+func (e *DecodeError) Error() string {
+	var parts []string
+	if len(e.UnknownFields) > 0 {
+		parts = append(parts, "unknown fields: "+strings.Join(e.UnknownFields, ", "))
+	}
+	if len(e.MissingFields) > 0 {
+		parts = append(parts, "missing fields: "+strings.Join(e.MissingFields, ", "))
+	}
+	return "kcl: decode failed, " + strings.Join(parts, "; ")
+}
+
+// Decode decodes the result into target, which must be a non-nil pointer.
+// Struct fields are matched by their json tag by default, integers and
+// floats are converted to the target numeric type, strings such as "1h30m"
+// decode into time.Duration and RFC 3339 strings decode into time.Time.
+// Embedded structs are flattened into their parent.
+func (m *KCLResult) Decode(target any, opts ...DecodeOptions) error {
+	var opt DecodeOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	return decodeValue(m.result, target, opt)
+}
+
+// Decode decodes the first result of the list into target, see KCLResult.Decode.
+func (p *KCLResultList) Decode(target any, opts ...DecodeOptions) error {
+	if p.Len() == 0 {
+		return fmt.Errorf("result is nil")
+	}
+	return p.First().Decode(target, opts...)
+}
+
+// RunAs evaluates the KCL program with path and opts, then decodes the first
+// result into a value of type T with the default DecodeOptions.
+func RunAs[T any](path string, opts ...Option) (T, error) {
+	var v T
+	result, err := Run(path, opts...)
+	if err != nil {
+		return v, err
+	}
+	if err := result.Decode(&v); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func decodeValue(src, target any, opt DecodeOptions) error {
+	targetVal := reflect.ValueOf(target)
+	if targetVal.Kind() != reflect.Ptr || targetVal.IsNil() {
+		return fmt.Errorf("failed to decode result to %T: target must be a non-nil pointer", target)
+	}
+	tagName := opt.TagName
+	if tagName == "" {
+		tagName = "json"
+	}
+
+	var md mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToTimeHookFunc(time.RFC3339),
+		),
+		Metadata: &md,
+		Result:   target,
+		TagName:  tagName,
+		Squash:   true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(src); err != nil {
+		return fmt.Errorf("failed to decode result to %T: %w", target, err)
+	}
+	if !opt.Strict {
+		return nil
+	}
+
+	var decodeErr DecodeError
+	decodeErr.UnknownFields = append(decodeErr.UnknownFields, md.Unused...)
+	for _, path := range md.Unset {
+		if f, ok := fieldByPath(targetVal.Type().Elem(), path, tagName); ok && isRequiredField(f, tagName) {
+			decodeErr.MissingFields = append(decodeErr.MissingFields, path)
+		}
+	}
+	if len(decodeErr.UnknownFields) == 0 && len(decodeErr.MissingFields) == 0 {
+		return nil
+	}
+	sort.Strings(decodeErr.UnknownFields)
+	sort.Strings(decodeErr.MissingFields)
+	return &decodeErr
+}
+
+// isRequiredField reports whether a struct field must be present in strict mode.
+func isRequiredField(f reflect.StructField, tagName string) bool {
+	if !f.IsExported() {
+		return false
+	}
+	tag := strings.Split(f.Tag.Get(tagName), ",")
+	if tag[0] == "-" {
+		return false
+	}
+	for _, x := range tag[1:] {
+		if x == "omitempty" {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldByPath resolves a mapstructure field path such as `a.b[0].c` to the
+// struct field it refers to, starting from the type t.
+func fieldByPath(t reflect.Type, path, tagName string) (reflect.StructField, bool) {
+	var field reflect.StructField
+	for _, seg := range splitFieldPath(path) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if strings.HasPrefix(seg, "[") {
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+				continue
+			default:
+				return field, false
+			}
+		}
+		if t.Kind() != reflect.Struct {
+			return field, false
+		}
+		f, ok := structFieldByKey(t, seg, tagName)
+		if !ok {
+			return field, false
+		}
+		field, t = f, f.Type
+	}
+	return field, true
+}
+
+// structFieldByKey finds the field of the struct type t matched by key,
+// looking into embedded structs like mapstructure does when squashing.
+func structFieldByKey(t reflect.Type, key, tagName string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct {
+			if sf, ok := structFieldByKey(ft, key, tagName); ok {
+				return sf, true
+			}
+			continue
+		}
+		name := f.Name
+		if tag := strings.SplitN(f.Tag.Get(tagName), ",", 2)[0]; tag != "" {
+			name = tag
+		}
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// splitFieldPath splits `a.b[0].c` into ["a", "b", "[0]", "c"].
+func splitFieldPath(path string) []string {
+	var segs []string
+	var cur strings.Builder
+	depth := 0
+	flush := func() {
+		if cur.Len() > 0 {
+			segs = append(segs, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range path {
+		switch {
+		case r == '[' && depth == 0:
+			flush()
+			depth++
+			cur.WriteRune(r)
+		case r == ']' && depth == 1:
+			depth--
+			cur.WriteRune(r)
+			flush()
+		case r == '.' && depth == 0:
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return segs
+}
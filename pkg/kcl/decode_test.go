@@ -0,0 +1,127 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type decodeMeta struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type decodeContainer struct {
+	Image string `json:"image"`
+	Port  int64  `json:"port,omitempty"`
+}
+
+type decodeConfig struct {
+	decodeMeta
+	Replicas   int               `json:"replicas"`
+	Timeout    time.Duration     `json:"timeout"`
+	Ratio      float64           `json:"ratio"`
+	Containers []decodeContainer `json:"containers"`
+}
+
+func tDecodeResult(tb testing.TB, s string) *KCLResult {
+	tb.Helper()
+	var v any
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		tb.Fatal(err)
+	}
+	result := NewResult(v)
+	return &result
+}
+
+func TestDecode(t *testing.T) {
+	result := tDecodeResult(t, `
+name: app
+labels:
+  app: nginx
+replicas: 3
+timeout: 1m30s
+ratio: 1
+containers:
+- image: nginx
+  port: 80
+`)
+	var config decodeConfig
+	err := result.Decode(&config, DecodeOptions{Strict: true})
+	tAssert(t, err == nil, err)
+	tAssert(t, config.Name == "app", config.Name)
+	tAssert(t, config.Labels["app"] == "nginx", config.Labels)
+	tAssert(t, config.Replicas == 3, config.Replicas)
+	tAssert(t, config.Timeout == 90*time.Second, config.Timeout)
+	tAssert(t, config.Ratio == 1.0, config.Ratio)
+	tAssert(t, len(config.Containers) == 1 && config.Containers[0].Port == 80, config.Containers)
+}
+
+func TestDecodeStrict(t *testing.T) {
+	result := tDecodeResult(t, `
+name: app
+replicas: 3
+ratio: 0.5
+containers:
+- image: nginx
+  imagePullPolicy: Always
+- port: 80
+`)
+	var config decodeConfig
+	tAssert(t, result.Decode(&config) == nil, "non-strict decode should ignore unknown and missing fields")
+
+	err := result.Decode(&config, DecodeOptions{Strict: true})
+	var decodeErr *DecodeError
+	tAssert(t, errors.As(err, &decodeErr), err)
+	tAssert(t, len(decodeErr.UnknownFields) == 1 && decodeErr.UnknownFields[0] == "containers[0].imagePullPolicy", decodeErr.UnknownFields)
+	tAssert(t, len(decodeErr.MissingFields) == 2, decodeErr.MissingFields)
+	tAssert(t, decodeErr.MissingFields[0] == "containers[1].image", decodeErr.MissingFields)
+	tAssert(t, decodeErr.MissingFields[1] == "timeout", decodeErr.MissingFields)
+}
+
+func TestDecodeTagName(t *testing.T) {
+	result := tDecodeResult(t, `
+api_version: v1
+`)
+	var v struct {
+		APIVersion string `yaml:"api_version"`
+	}
+	err := result.Decode(&v, DecodeOptions{TagName: "yaml", Strict: true})
+	tAssert(t, err == nil, err)
+	tAssert(t, v.APIVersion == "v1", v.APIVersion)
+}
+
+func TestDecodeInvalidTarget(t *testing.T) {
+	result := tDecodeResult(t, `a: 1`)
+	var v struct{ A int }
+	tAssert(t, result.Decode(v) != nil, "expect error for a non-pointer target")
+}
+
+func TestGetValueMapstructure(t *testing.T) {
+	result := tDecodeResult(t, `
+app:
+  api_version: v1
+  replicas: 2
+`)
+	var v struct {
+		APIVersion string `mapstructure:"api_version" json:"apiVersion"`
+		Replicas   int    `mapstructure:"replicas"`
+	}
+	_, err := result.GetValue("app", &v)
+	tAssert(t, err == nil, err)
+	tAssert(t, v.APIVersion == "v1" && v.Replicas == 2, v)
+
+	// Get returns the raw value if decoding fails, GetValue the error.
+	var bad struct {
+		Replicas []string `mapstructure:"replicas"`
+	}
+	_, ok := result.Get("app", &bad).(map[string]any)
+	tAssert(t, ok, "expect the raw value")
+	_, err = result.GetValue("app", &bad)
+	tAssert(t, err != nil, "expect a decoding error")
+	tAssert(t, result.Get("app", &v) == &v, "expect the target")
+}
@@ -0,0 +1,256 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind classifies a Change.
+type ChangeKind string
+
+const (
+	// ChangeAdded is a value or document only in the new result.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved is a value or document only in the old result.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified is a value which differs between the two results.
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change is a single difference found by DiffResults.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// Document is the identity key of the document, see DiffOptions.KeyPaths.
+	Document string `json:"document"`
+	// Path is the path of the value in the document, usable by Query, e.g.
+	// `spec.containers[0].image`. It is empty for an added or removed
+	// document.
+	Path string `json:"path,omitempty"`
+	// From is the old value, nil for ChangeAdded.
+	From any `json:"from,omitempty"`
+	// To is the new value, nil for ChangeRemoved.
+	To any `json:"to,omitempty"`
+}
+
+// DefaultDiffKeyPaths returns the key paths identifying Kubernetes objects,
+// the default of DiffOptions.KeyPaths.
+func DefaultDiffKeyPaths() []string {
+	return []string{"apiVersion", "kind", "metadata.namespace", "metadata.name"}
+}
+
+// DiffOptions controls how DiffResults pairs documents.
+type DiffOptions struct {
+	// KeyPaths are the dotted paths of the values identifying a document,
+	// joined by "/" into Change.Document. The default is
+	// DefaultDiffKeyPaths(). Documents without any of the values are paired
+	// in order and identified by their position among them, e.g. "#0".
+	KeyPaths []string
+	// Key, if set, is used instead of KeyPaths to identify a document.
+	// Returning "" identifies the document by its position.
+	Key func(doc any) string
+}
+
+// key returns the identity key of doc, or "" if it has none.
+func (o *DiffOptions) key(doc any) string {
+	if o.Key != nil {
+		return o.Key(doc)
+	}
+	paths := o.KeyPaths
+	if len(paths) == 0 {
+		paths = DefaultDiffKeyPaths()
+	}
+	parts := make([]string, len(paths))
+	found := false
+	for i, path := range paths {
+		if v, ok := lookupDotPath(doc, path); ok && v != nil {
+			parts[i] = fmt.Sprint(v)
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+	return strings.Join(parts, "/")
+}
+
+func lookupDotPath(v any, path string) (any, bool) {
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// DiffResults compares the documents of the results a and b, e.g. two renders
+// of the same package with different options. Documents are paired by their
+// identity key, see DiffOptions, and documents with the same key in order.
+// Changes are ordered by the documents of a, then the added documents of b,
+// and by path within a document.
+func DiffResults(a, b *KCLResultList, opt DiffOptions) []Change {
+	type document struct {
+		key   string
+		value any
+	}
+	documents := func(l *KCLResultList) []document {
+		if l == nil {
+			return nil
+		}
+		var docs []document
+		unkeyed := 0
+		for _, r := range l.Slice() {
+			key := opt.key(r.result)
+			if key == "" {
+				key = "#" + strconv.Itoa(unkeyed)
+				unkeyed++
+			}
+			docs = append(docs, document{key: key, value: r.result})
+		}
+		return docs
+	}
+	docsA, docsB := documents(a), documents(b)
+
+	// Documents with the same key are paired in order.
+	indexB := make(map[string][]int, len(docsB))
+	for i, d := range docsB {
+		indexB[d.key] = append(indexB[d.key], i)
+	}
+	var changes []Change
+	paired := make(map[int]bool)
+	for _, d := range docsA {
+		if len(indexB[d.key]) == 0 {
+			changes = append(changes, Change{Kind: ChangeRemoved, Document: d.key, From: d.value})
+			continue
+		}
+		i := indexB[d.key][0]
+		indexB[d.key] = indexB[d.key][1:]
+		paired[i] = true
+		changes = diffValues(changes, d.key, "", d.value, docsB[i].value)
+	}
+	for i, d := range docsB {
+		if !paired[i] {
+			changes = append(changes, Change{Kind: ChangeAdded, Document: d.key, To: d.value})
+		}
+	}
+	return changes
+}
+
+func diffValues(changes []Change, doc, path string, a, b any) []Change {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(a)+len(b))
+			for k := range a {
+				keys = append(keys, k)
+			}
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				va, inA := a[k]
+				vb, inB := b[k]
+				p := diffPathKey(path, k)
+				switch {
+				case !inB:
+					changes = append(changes, Change{Kind: ChangeRemoved, Document: doc, Path: p, From: va})
+				case !inA:
+					changes = append(changes, Change{Kind: ChangeAdded, Document: doc, Path: p, To: vb})
+				default:
+					changes = diffValues(changes, doc, p, va, vb)
+				}
+			}
+			return changes
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			for i := 0; i < max(len(a), len(b)); i++ {
+				p := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(b):
+					changes = append(changes, Change{Kind: ChangeRemoved, Document: doc, Path: p, From: a[i]})
+				case i >= len(a):
+					changes = append(changes, Change{Kind: ChangeAdded, Document: doc, Path: p, To: b[i]})
+				default:
+					changes = diffValues(changes, doc, p, a[i], b[i])
+				}
+			}
+			return changes
+		}
+	}
+	if !queryEqual(a, b) {
+		changes = append(changes, Change{Kind: ChangeModified, Document: doc, Path: path, From: a, To: b})
+	}
+	return changes
+}
+
+// diffPathKey appends the key k to path, quoting it when it is not a plain
+// name, e.g. `metadata.labels["app.kubernetes.io/name"]`.
+func diffPathKey(path, k string) string {
+	if k == "" || strings.ContainsAny(k, ".[]'\" \t*$@") {
+		return path + "[" + strconv.Quote(k) + "]"
+	}
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+// WriteDiff writes the changes to w as unified-diff-style text, with a hunk
+// header per document, `-` lines for old values and `+` lines for new ones.
+func WriteDiff(w io.Writer, changes []Change) error {
+	var buf bytes.Buffer
+	doc := ""
+	for i, c := range changes {
+		if i == 0 || c.Document != doc {
+			doc = c.Document
+			fmt.Fprintf(&buf, "@@ %s @@\n", doc)
+		}
+		label := c.Path
+		if label == "" {
+			label = "(document)"
+		}
+		if c.Kind != ChangeAdded {
+			fmt.Fprintf(&buf, "- %s: %s\n", label, diffValueString(c.From))
+		}
+		if c.Kind != ChangeRemoved {
+			fmt.Fprintf(&buf, "+ %s: %s\n", label, diffValueString(c.To))
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteDiffJSON writes the changes to w as an indented JSON array.
+func WriteDiffJSON(w io.Writer, changes []Change) error {
+	if changes == nil {
+		changes = []Change{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	return enc.Encode(changes)
+}
+
+func diffValueString(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
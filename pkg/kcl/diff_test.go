@@ -0,0 +1,106 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	a := encodeTestResult(t, `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+  labels:
+    app.kubernetes.io/name: web
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+`)
+	b := encodeTestResult(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+  labels:
+    app.kubernetes.io/name: web
+    env: prod
+spec:
+  replicas: 3
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+  - port: 443
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+`)
+
+	changes := DiffResults(a, b, DiffOptions{})
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, changes); err != nil {
+		t.Fatal(err)
+	}
+	expect := `@@ v1/Service//web @@
++ spec.ports[1]: {"port":443}
+@@ apps/v1/Deployment/default/web @@
++ metadata.labels.env: "prod"
+- spec.replicas: 1
++ spec.replicas: 3
+@@ v1/ConfigMap//old @@
+- (document): {"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"old"}}
+@@ v1/ConfigMap//new @@
++ (document): {"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"new"}}
+`
+	tAssert(t, buf.String() == expect, buf.String())
+
+	buf.Reset()
+	if err := WriteDiffJSON(&buf, changes[:1]); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Change
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, len(decoded) == 1 && decoded[0].Kind == ChangeAdded && decoded[0].Path == "spec.ports[1]", buf.String())
+
+	tAssert(t, len(DiffResults(a, a, DiffOptions{})) == 0)
+}
+
+func TestDiffResultsKey(t *testing.T) {
+	a := encodeTestResult(t, "id: x\nvalue: 1\n---\nvalue: 2\n")
+	b := encodeTestResult(t, "value: 3\n---\nid: x\nvalue: 1\n")
+
+	changes := DiffResults(a, b, DiffOptions{KeyPaths: []string{"id"}})
+	tAssert(t, len(changes) == 1, changes)
+	tAssert(t, changes[0].Document == "#0" && changes[0].Path == "value", changes[0])
+
+	changes = DiffResults(a, b, DiffOptions{Key: func(doc any) string { return "all" }})
+	tAssert(t, len(changes) == 4, changes)
+	tAssert(t, changes[0].Kind == ChangeRemoved && changes[0].Path == "id", changes[0])
+	tAssert(t, changes[2].Kind == ChangeAdded && changes[2].Path == "id", changes[2])
+
+	tAssert(t, diffPathKey("metadata.labels", "app.kubernetes.io/name") == `metadata.labels["app.kubernetes.io/name"]`)
+}
@@ -0,0 +1,585 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	goyaml "github.com/goccy/go-yaml"
+	"gopkg.in/yaml.v3"
+
+	"kcl-lang.io/kcl-go/pkg/3rdparty/toml"
+)
+
+// EncodeFormat is an output format of Encode.
+type EncodeFormat string
+
+const (
+	// EncodeJSON is indented JSON, a list of results is a JSON array.
+	EncodeJSON EncodeFormat = "json"
+	// EncodeYAML is YAML, a list of results is a YAML stream.
+	EncodeYAML EncodeFormat = "yaml"
+	// EncodeJSONLines writes each result as compact JSON on its own line.
+	EncodeJSONLines EncodeFormat = "jsonl"
+	// EncodeTOML is TOML, the result must be a mapping.
+	EncodeTOML EncodeFormat = "toml"
+	// EncodeDotenv writes `KEY=value` lines with the flattened keys joined
+	// by "_" and converted to upper case identifiers, e.g. DB_HOSTS_0.
+	EncodeDotenv EncodeFormat = "dotenv"
+	// EncodeProperties writes Java properties with flattened keys, e.g.
+	// `db.hosts[0]=a`.
+	EncodeProperties EncodeFormat = "properties"
+	// EncodeTFVarsJSON is a Terraform `.tfvars.json` file.
+	EncodeTFVarsJSON EncodeFormat = "tfvars.json"
+	// EncodeTFVars is a Terraform `.tfvars` file in HCL syntax.
+	EncodeTFVars EncodeFormat = "tfvars"
+	// EncodeXML is XML, with mapping keys as element names and list items
+	// as repeated elements.
+	EncodeXML EncodeFormat = "xml"
+)
+
+// EncodeOptions controls the output of Encode.
+type EncodeOptions struct {
+	// Indent is the number of spaces of an indentation level. The default is
+	// 4 for JSON and 2 for the other formats.
+	Indent int
+	// SortKeys sorts mapping keys, otherwise the key order of the KCL output
+	// is kept.
+	SortKeys bool
+	// XMLRoot is the root element name of XML output, the default is "root".
+	XMLRoot string
+}
+
+// Encode writes the result to w in the given format.
+func (m *KCLResult) Encode(w io.Writer, format EncodeFormat, opts ...EncodeOptions) error {
+	var opt EncodeOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	v, err := m.orderedValue(opt.SortKeys)
+	if err != nil {
+		return err
+	}
+	return encodeDocuments(w, format, opt, []any{v})
+}
+
+// Encode writes the results to w in the given format. JSON writes an array
+// of the results, YAML a stream of documents and JSON Lines one line per
+// result. The other formats only support a single result.
+func (p *KCLResultList) Encode(w io.Writer, format EncodeFormat, opts ...EncodeOptions) error {
+	var opt EncodeOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	var docs []any
+	for i := range p.list {
+		v, err := p.list[i].orderedValue(opt.SortKeys)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, v)
+	}
+	return encodeDocuments(w, format, opt, docs)
+}
+
+// orderedValue returns the result with mappings as goyaml.MapSlice in the
+// key order of the KCL output, or in sorted order if sortKeys is set or the
+// order is unknown.
+func (m *KCLResult) orderedValue(sortKeys bool) (any, error) {
+	if m.numberMode != NumberModeDefault && m.document != "" {
+		return m.literalValue(sortKeys)
+	}
+	if m.document == "" || sortKeys {
+		return sortedValue(m.result), nil
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(m.document), &node); err != nil {
+		return nil, err
+	}
+	return nodeValue(&node)
+}
+
+// nodeValue converts a YAML node to a value, with mappings as goyaml.MapSlice.
+func nodeValue(node *yaml.Node) (any, error) {
+	return nodeValueMode(node, NumberModeDefault)
+}
+
+// nodeValueMode is like nodeValue, but with a mode other than
+// NumberModeDefault the numbers are kept as their literal.
+func nodeValueMode(node *yaml.Node, mode NumberMode) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return nodeValueMode(node.Content[0], mode)
+	case yaml.AliasNode:
+		return nodeValueMode(node.Alias, mode)
+	case yaml.MappingNode:
+		m := goyaml.MapSlice{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := nodeValueMode(node.Content[i+1], mode)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, goyaml.MapItem{Key: node.Content[i].Value, Value: v})
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		list := []any{}
+		for _, item := range node.Content {
+			v, err := nodeValueMode(item, mode)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	if lit, ok := numberLiteralOf(node); ok && mode != NumberModeDefault {
+		return numberLiteral(lit), nil
+	}
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// sortedValue converts maps to goyaml.MapSlice with sorted keys.
+func sortedValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		m := make(goyaml.MapSlice, 0, len(keys))
+		for _, k := range keys {
+			m = append(m, goyaml.MapItem{Key: k, Value: sortedValue(v[k])})
+		}
+		return m
+	case goyaml.MapSlice:
+		m := make(goyaml.MapSlice, 0, len(v))
+		for _, item := range v {
+			m = append(m, goyaml.MapItem{Key: item.Key, Value: sortedValue(item.Value)})
+		}
+		sort.SliceStable(m, func(i, j int) bool { return fmt.Sprint(m[i].Key) < fmt.Sprint(m[j].Key) })
+		return m
+	case []any:
+		list := make([]any, len(v))
+		for i, x := range v {
+			list[i] = sortedValue(x)
+		}
+		return list
+	}
+	return v
+}
+
+func encodeDocuments(w io.Writer, format EncodeFormat, opt EncodeOptions, docs []any) error {
+	indent := opt.Indent
+	if indent <= 0 {
+		indent = 2
+		if format == EncodeJSON || format == EncodeTFVarsJSON {
+			indent = 4
+		}
+	}
+	prefix := strings.Repeat(" ", indent)
+
+	var buf bytes.Buffer
+	switch format {
+	case EncodeJSON:
+		var v any = docs
+		if len(docs) == 1 {
+			v = docs[0]
+		}
+		writeJSON(&buf, v, prefix, "")
+		buf.WriteByte('\n')
+	case EncodeJSONLines:
+		for _, doc := range docs {
+			writeJSON(&buf, doc, "", "")
+			buf.WriteByte('\n')
+		}
+	case EncodeYAML:
+		for i, doc := range docs {
+			if i > 0 {
+				buf.WriteString("---\n")
+			}
+			out, err := goyaml.MarshalWithOptions(doc, goyaml.Indent(indent), goyaml.IndentSequence(true))
+			if err != nil {
+				return err
+			}
+			buf.Write(out)
+		}
+	default:
+		if len(docs) != 1 {
+			return fmt.Errorf("kcl: encode %s: expect a single result, got %d", format, len(docs))
+		}
+		var err error
+		switch format {
+		case EncodeTOML:
+			err = writeTOML(&buf, docs[0], prefix)
+		case EncodeDotenv:
+			err = writeDotenv(&buf, docs[0])
+		case EncodeProperties:
+			err = writeProperties(&buf, docs[0])
+		case EncodeTFVarsJSON:
+			if _, ok := docs[0].(goyaml.MapSlice); !ok {
+				return fmt.Errorf("kcl: encode %s: expect a mapping, got %T", format, docs[0])
+			}
+			writeJSON(&buf, docs[0], prefix, "")
+			buf.WriteByte('\n')
+		case EncodeTFVars:
+			err = writeTFVars(&buf, docs[0], prefix)
+		case EncodeXML:
+			err = writeXML(&buf, docs[0], prefix, opt.XMLRoot)
+		default:
+			return fmt.Errorf("kcl: unsupported encode format %q", format)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeJSON writes v as JSON in order. An empty indent writes compact JSON.
+func writeJSON(buf *bytes.Buffer, v any, indent, cur string) {
+	newline := func(level string) {
+		if indent != "" {
+			buf.WriteByte('\n')
+			buf.WriteString(level)
+		}
+	}
+	sep := ":"
+	if indent != "" {
+		sep = ": "
+	}
+	switch v := v.(type) {
+	case goyaml.MapSlice:
+		if len(v) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteByte('{')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(cur + indent)
+			writeJSONScalar(buf, fmt.Sprint(item.Key))
+			buf.WriteString(sep)
+			writeJSON(buf, item.Value, indent, cur+indent)
+		}
+		newline(cur)
+		buf.WriteByte('}')
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(cur + indent)
+			writeJSON(buf, item, indent, cur+indent)
+		}
+		newline(cur)
+		buf.WriteByte(']')
+	default:
+		writeJSONScalar(buf, v)
+	}
+}
+
+func writeJSONScalar(buf *bytes.Buffer, v any) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(buf, "%q", fmt.Sprint(v))
+		return
+	}
+	buf.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+}
+
+func writeTOML(buf *bytes.Buffer, v any, indent string) error {
+	m, ok := v.(goyaml.MapSlice)
+	if !ok {
+		return fmt.Errorf("kcl: encode toml: expect a mapping, got %T", v)
+	}
+	enc := toml.NewEncoder(buf)
+	enc.Indent = indent
+	return enc.Encode(m)
+}
+
+// flatten calls fn for each scalar of v with its key path.
+func flatten(v any, path []any, fn func(path []any, v any)) {
+	switch v := v.(type) {
+	case goyaml.MapSlice:
+		for _, item := range v {
+			flatten(item.Value, append(path[:len(path):len(path)], fmt.Sprint(item.Key)), fn)
+		}
+	case []any:
+		for i, item := range v {
+			flatten(item, append(path[:len(path):len(path)], i), fn)
+		}
+	default:
+		fn(path, v)
+	}
+}
+
+// flatScalar formats a scalar for the flat formats, nil is empty.
+func flatScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+var dotenvInvalidRegexp = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+func writeDotenv(buf *bytes.Buffer, v any) error {
+	if _, ok := v.(goyaml.MapSlice); !ok {
+		return fmt.Errorf("kcl: encode dotenv: expect a mapping, got %T", v)
+	}
+	flatten(v, nil, func(path []any, v any) {
+		var parts []string
+		for _, p := range path {
+			parts = append(parts, fmt.Sprint(p))
+		}
+		key := strings.ToUpper(dotenvInvalidRegexp.ReplaceAllString(strings.Join(parts, "_"), "_"))
+		if key != "" && key[0] >= '0' && key[0] <= '9' {
+			key = "_" + key
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(dotenvQuote(flatScalar(v)))
+		buf.WriteByte('\n')
+	})
+	return nil
+}
+
+// dotenvQuote double quotes s when it is not a plain word.
+func dotenvQuote(s string) string {
+	plain := true
+	for _, r := range s {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.,:/@+%", r)) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
+
+func writeProperties(buf *bytes.Buffer, v any) error {
+	if _, ok := v.(goyaml.MapSlice); !ok {
+		return fmt.Errorf("kcl: encode properties: expect a mapping, got %T", v)
+	}
+	flatten(v, nil, func(path []any, v any) {
+		var key strings.Builder
+		for i, p := range path {
+			switch p := p.(type) {
+			case int:
+				fmt.Fprintf(&key, "[%d]", p)
+			default:
+				if i > 0 {
+					key.WriteByte('.')
+				}
+				key.WriteString(fmt.Sprint(p))
+			}
+		}
+		buf.WriteString(propertiesEscape(key.String(), true))
+		buf.WriteByte('=')
+		buf.WriteString(propertiesEscape(flatScalar(v), false))
+		buf.WriteByte('\n')
+	})
+	return nil
+}
+
+// propertiesEscape escapes s as a key or value of a Java properties file,
+// non ASCII characters are written as \uXXXX escapes.
+func propertiesEscape(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case strings.ContainsRune("=:#!", r) && (isKey || i == 0):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16Units(r) {
+				fmt.Fprintf(&b, `\u%04x`, u)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func utf16Units(r rune) []uint16 {
+	if r < 0x10000 {
+		return []uint16{uint16(r)}
+	}
+	r -= 0x10000
+	return []uint16{uint16(0xd800 + (r>>10)&0x3ff), uint16(0xdc00 + r&0x3ff)}
+}
+
+var hclIdentRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func writeTFVars(buf *bytes.Buffer, v any, indent string) error {
+	m, ok := v.(goyaml.MapSlice)
+	if !ok {
+		return fmt.Errorf("kcl: encode tfvars: expect a mapping, got %T", v)
+	}
+	for _, item := range m {
+		key := fmt.Sprint(item.Key)
+		if !hclIdentRegexp.MatchString(key) {
+			return fmt.Errorf("kcl: encode tfvars: invalid variable name %q", key)
+		}
+		buf.WriteString(key)
+		buf.WriteString(" = ")
+		writeHCL(buf, item.Value, indent, "")
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+func writeHCL(buf *bytes.Buffer, v any, indent, cur string) {
+	switch v := v.(type) {
+	case goyaml.MapSlice:
+		if len(v) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{\n")
+		for _, item := range v {
+			key := fmt.Sprint(item.Key)
+			buf.WriteString(cur + indent)
+			if hclIdentRegexp.MatchString(key) {
+				buf.WriteString(key)
+			} else {
+				writeJSONScalar(buf, key)
+			}
+			buf.WriteString(" = ")
+			writeHCL(buf, item.Value, indent, cur+indent)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(cur + "}")
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for _, item := range v {
+			buf.WriteString(cur + indent)
+			writeHCL(buf, item, indent, cur+indent)
+			buf.WriteString(",\n")
+		}
+		buf.WriteString(cur + "]")
+	case string:
+		// Escape HCL template sequences, tfvars values are literal.
+		s, _ := json.Marshal(v)
+		s = bytes.ReplaceAll(s, []byte("${"), []byte("$${"))
+		s = bytes.ReplaceAll(s, []byte("%{"), []byte("%%{"))
+		buf.Write(s)
+	case nil:
+		buf.WriteString("null")
+	default:
+		writeJSONScalar(buf, v)
+	}
+}
+
+var xmlNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+func writeXML(buf *bytes.Buffer, v any, indent, root string) error {
+	if root == "" {
+		root = "root"
+	}
+	if !isXMLName(root) {
+		return fmt.Errorf("kcl: encode xml: invalid root element name %q", root)
+	}
+	buf.WriteString(xml.Header)
+	writeXMLElement(buf, root, v, indent, "")
+	return nil
+}
+
+func isXMLName(s string) bool {
+	return xmlNameRegexp.MatchString(s) && !strings.HasPrefix(strings.ToLower(s), "xml")
+}
+
+func writeXMLElement(buf *bytes.Buffer, name string, v any, indent, cur string) {
+	// Keys which are not valid element names use <entry key="...">.
+	open, close := name, name
+	if !isXMLName(name) {
+		var attr bytes.Buffer
+		xml.EscapeText(&attr, []byte(name))
+		open, close = `entry key="`+attr.String()+`"`, "entry"
+	}
+	switch v := v.(type) {
+	case goyaml.MapSlice:
+		if len(v) == 0 {
+			fmt.Fprintf(buf, "%s<%s/>\n", cur, open)
+			return
+		}
+		fmt.Fprintf(buf, "%s<%s>\n", cur, open)
+		for _, item := range v {
+			key := fmt.Sprint(item.Key)
+			if list, ok := item.Value.([]any); ok {
+				// List items are repeated elements named by the key.
+				for _, x := range list {
+					writeXMLElement(buf, key, x, indent, cur+indent)
+				}
+				continue
+			}
+			writeXMLElement(buf, key, item.Value, indent, cur+indent)
+		}
+		fmt.Fprintf(buf, "%s</%s>\n", cur, close)
+	case []any:
+		fmt.Fprintf(buf, "%s<%s>\n", cur, open)
+		for _, x := range v {
+			writeXMLElement(buf, "item", x, indent, cur+indent)
+		}
+		fmt.Fprintf(buf, "%s</%s>\n", cur, close)
+	case nil:
+		fmt.Fprintf(buf, "%s<%s/>\n", cur, open)
+	default:
+		var text bytes.Buffer
+		xml.EscapeText(&text, []byte(flatScalar(v)))
+		fmt.Fprintf(buf, "%s<%s>%s</%s>\n", cur, open, text.String(), close)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+func encodeTestResult(t *testing.T, yamlResult string) *KCLResultList {
+	t.Helper()
+	result, err := ExecResultToKCLResult(&Option{ExecProgramArgs: new(gpyrpc.ExecProgramArgs)}, &gpyrpc.ExecProgramResult{
+		JsonResult: "{}",
+		YamlResult: yamlResult,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestEncode(t *testing.T) {
+	list := encodeTestResult(t, `name: app
+db:
+  port: 5432
+  hosts:
+  - a
+  - b
+tags:
+  team: "x y"
+`)
+	r := list.First()
+
+	tests := []struct {
+		format EncodeFormat
+		opt    EncodeOptions
+		expect string
+	}{
+		{EncodeJSON, EncodeOptions{Indent: 2}, `{
+  "name": "app",
+  "db": {
+    "port": 5432,
+    "hosts": [
+      "a",
+      "b"
+    ]
+  },
+  "tags": {
+    "team": "x y"
+  }
+}
+`},
+		{EncodeJSONLines, EncodeOptions{}, `{"name":"app","db":{"port":5432,"hosts":["a","b"]},"tags":{"team":"x y"}}
+`},
+		{EncodeJSONLines, EncodeOptions{SortKeys: true}, `{"db":{"hosts":["a","b"],"port":5432},"name":"app","tags":{"team":"x y"}}
+`},
+		{EncodeDotenv, EncodeOptions{}, `NAME=app
+DB_PORT=5432
+DB_HOSTS_0=a
+DB_HOSTS_1=b
+TAGS_TEAM="x y"
+`},
+		{EncodeProperties, EncodeOptions{}, `name=app
+db.port=5432
+db.hosts[0]=a
+db.hosts[1]=b
+tags.team=x y
+`},
+		{EncodeTFVars, EncodeOptions{}, `name = "app"
+db = {
+  port = 5432
+  hosts = [
+    "a",
+    "b",
+  ]
+}
+tags = {
+  team = "x y"
+}
+`},
+		{EncodeXML, EncodeOptions{XMLRoot: "config"}, `<?xml version="1.0" encoding="UTF-8"?>
+<config>
+  <name>app</name>
+  <db>
+    <port>5432</port>
+    <hosts>a</hosts>
+    <hosts>b</hosts>
+  </db>
+  <tags>
+    <team>x y</team>
+  </tags>
+</config>
+`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := r.Encode(&buf, tt.format, tt.opt); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		tAssert(t, buf.String() == tt.expect, tt.format, buf.String())
+	}
+}
+
+func TestEncodeTOML(t *testing.T) {
+	r := encodeTestResult(t, "name: app\ndb:\n  port: 5432\n").First()
+	var buf bytes.Buffer
+	if err := r.Encode(&buf, EncodeTOML); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	tAssert(t, strings.Index(out, `name = "app"`) >= 0, out)
+	tAssert(t, strings.Index(out, "[db]") > strings.Index(out, "name"), out)
+	tAssert(t, strings.Contains(out, "port = 5432"), out)
+
+	list := encodeTestResult(t, "- 1\n- 2\n")
+	tAssert(t, list.First().Encode(&buf, EncodeTOML) != nil)
+}
+
+func TestEncodeEscape(t *testing.T) {
+	r := NewResult(map[string]any{
+		"a b": "=x\nü",
+		"s":   `say "${hi}"`,
+	})
+	var buf bytes.Buffer
+	if err := r.Encode(&buf, EncodeProperties); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, buf.String() == "a\\ b=\\=x\\n\\u00fc\ns=say \"${hi}\"\n", buf.String())
+
+	buf.Reset()
+	if err := r.Encode(&buf, EncodeDotenv); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, buf.String() == "A_B=\"=x\\nü\"\nS=\"say \\\"\\${hi}\\\"\"\n", buf.String())
+
+	buf.Reset()
+	tAssert(t, r.Encode(&buf, EncodeTFVars) != nil, "invalid variable name")
+	buf.Reset()
+	r = NewResult(map[string]any{"s": "${hi}"})
+	if err := r.Encode(&buf, EncodeTFVars); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, buf.String() == "s = \"$${hi}\"\n", buf.String())
+}
+
+func TestEncodeList(t *testing.T) {
+	list := encodeTestResult(t, "b: 1\na: 2\n---\nc: 3\n")
+
+	var buf bytes.Buffer
+	if err := list.Encode(&buf, EncodeJSONLines); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, buf.String() == "{\"b\":1,\"a\":2}\n{\"c\":3}\n", buf.String())
+
+	buf.Reset()
+	if err := list.Encode(&buf, EncodeYAML); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, buf.String() == "b: 1\na: 2\n---\nc: 3\n", buf.String())
+
+	buf.Reset()
+	if err := list.Encode(&buf, EncodeJSON, EncodeOptions{Indent: 1}); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, buf.String() == "[\n {\n  \"b\": 1,\n  \"a\": 2\n },\n {\n  \"c\": 3\n }\n]\n", buf.String())
+
+	tAssert(t, list.Encode(&buf, EncodeTOML) != nil)
+	tAssert(t, list.Encode(&buf, "ini") != nil)
+}
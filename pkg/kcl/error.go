@@ -0,0 +1,282 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+// ErrorKind classifies a KCL diagnostic.
+type ErrorKind string
+
+const (
+	ErrorKindUnknown            ErrorKind = "Unknown"
+	ErrorKindSyntaxError        ErrorKind = "SyntaxError"
+	ErrorKindCompileError       ErrorKind = "CompileError"
+	ErrorKindTypeError          ErrorKind = "TypeError"
+	ErrorKindSchemaCheckFailure ErrorKind = "SchemaCheckFailure"
+	ErrorKindAssertionError     ErrorKind = "AssertionError"
+	ErrorKindRuntimePanic       ErrorKind = "RuntimePanic"
+)
+
+// Pos is a position in a KCL source file. Line and Column are 1-based, a
+// zero value means the position is unknown.
+type Pos struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+func (p Pos) String() string {
+	s := p.Filename
+	if p.Line > 0 {
+		s += ":" + strconv.Itoa(p.Line)
+		if p.Column > 0 {
+			s += ":" + strconv.Itoa(p.Column)
+		}
+	}
+	return s
+}
+
+// IsValid reports whether the position is known.
+func (p Pos) IsValid() bool {
+	return p.Filename != "" || p.Line > 0
+}
+
+// Diagnostic is a single error or warning reported by KCL.
+type Diagnostic struct {
+	// Level is "error" or "warning".
+	Level string `json:"level"`
+	// Code is the KCL error code, e.g. "E2G22".
+	Code string `json:"code,omitempty"`
+	// Kind is the error kind derived from the code and message.
+	Kind ErrorKind `json:"kind"`
+	// Pos is the source position of the diagnostic.
+	Pos Pos `json:"pos"`
+	// Message is the human readable message.
+	Message string `json:"message"`
+	// Snippet is the source code at Pos, as printed by KCL.
+	Snippet string `json:"snippet,omitempty"`
+}
+
+func (d *Diagnostic) String() string {
+	if d.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s: %s", d.Pos, d.Kind, d.Message)
+	}
+	return fmt.Sprintf("%s: %s", d.Kind, d.Message)
+}
+
+// Error is the error returned by the KCL APIs when the KCL program fails to
+// compile or evaluate. Use errors.As to get the diagnostics:
+//
+//	var kerr *kcl.Error
+//	if errors.As(err, &kerr) {
+//		for _, d := range kerr.Diagnostics {
+//			fmt.Println(d.Pos, d.Message)
+//		}
+//	}
+type Error struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	message     string
+	// unredacted is the error without redaction, see WithRedaction.
+	unredacted *Error
+}
+
+// Error returns the original KCL error message.
+func (e *Error) Error() string {
+	return e.message
+}
+
+// NewError parses a KCL error message such as ExecProgramResult.ErrMessage
+// into an *Error. The message is kept verbatim as the error text.
+func NewError(message string) *Error {
+	return &Error{
+		Diagnostics: parseDiagnostics(message),
+		message:     message,
+	}
+}
+
+// NewErrorFromSpec converts the errors returned by the KCL service, such as
+// ParseProgramResult.Errors, into an *Error. It returns nil if errs has no
+// messages.
+func NewErrorFromSpec(errs ...*gpyrpc.Error) *Error {
+	var e Error
+	var lines []string
+	for _, x := range errs {
+		if x == nil {
+			continue
+		}
+		for _, msg := range x.Messages {
+			d := Diagnostic{
+				Level:   x.Level,
+				Code:    x.Code,
+				Message: msg.Msg,
+			}
+			if msg.Pos != nil {
+				d.Pos = Pos{
+					Filename: msg.Pos.Filename,
+					Line:     int(msg.Pos.Line),
+					Column:   int(msg.Pos.Column),
+				}
+			}
+			d.Kind = classifyDiagnostic(d.Code, "", d.Message, "")
+			e.Diagnostics = append(e.Diagnostics, d)
+			lines = append(lines, d.String())
+		}
+	}
+	if len(e.Diagnostics) == 0 {
+		return nil
+	}
+	e.message = strings.Join(lines, "\n")
+	return &e
+}
+
+var (
+	diagHeaderRegexp = regexp.MustCompile(`^(error|warning)(?:\[(\w+)\])?: ?(.*)$`)
+	diagSourceRegexp = regexp.MustCompile(`^\s*(\d+)\s*\|(.*)$`)
+	diagGutterRegexp = regexp.MustCompile(`^\s*\|(.*)$`)
+)
+
+// parseDiagnostics parses the rendered KCL error format:
+//
+//	error[E2G22]: TypeError
+//	 --> main.k:1:1
+//	  |
+//	1 | a: int = "1"
+//	  | ^ expected int, got str("1")
+//	  |
+func parseDiagnostics(s string) []Diagnostic {
+	var diags []Diagnostic
+	var level, code, title string
+	var cur *Diagnostic
+	var messages, snippet []string
+	// pending is set after a header until a diagnostic is started for it.
+	pending := false
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		cur.Message = strings.Join(messages, "\n")
+		if cur.Message == "" {
+			cur.Message = title
+		}
+		cur.Snippet = strings.Join(snippet, "\n")
+		cur.Kind = classifyDiagnostic(cur.Code, title, cur.Message, cur.Snippet)
+		diags = append(diags, *cur)
+		cur, messages, snippet = nil, nil, nil
+	}
+	start := func() {
+		cur = &Diagnostic{Level: level, Code: code}
+		pending = false
+	}
+	flushHeader := func() {
+		flush()
+		if pending {
+			start()
+			flush()
+		}
+	}
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if m := diagHeaderRegexp.FindStringSubmatch(line); m != nil {
+			flushHeader()
+			level, code, title = m[1], m[2], strings.TrimSpace(m[3])
+			pending = true
+			continue
+		}
+		if level == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "--> ") {
+			flush()
+			start()
+			cur.Pos = parsePos(strings.TrimSpace(trimmed[len("--> "):]))
+			continue
+		}
+		if cur == nil {
+			if trimmed == "" {
+				continue
+			}
+			start()
+		}
+		if m := diagSourceRegexp.FindStringSubmatch(line); m != nil {
+			snippet = append(snippet, strings.TrimPrefix(m[2], " "))
+			continue
+		}
+		if m := diagGutterRegexp.FindStringSubmatch(line); m != nil {
+			if msg := strings.TrimSpace(strings.TrimLeft(m[1], " ^~-")); msg != "" {
+				messages = append(messages, msg)
+			}
+			continue
+		}
+		if trimmed != "" {
+			messages = append(messages, strings.TrimPrefix(trimmed, "= "))
+		}
+	}
+	flushHeader()
+
+	if len(diags) == 0 && strings.TrimSpace(s) != "" {
+		msg := strings.TrimSpace(s)
+		diags = append(diags, Diagnostic{
+			Level:   "error",
+			Kind:    classifyDiagnostic("", "", msg, ""),
+			Message: msg,
+		})
+	}
+	return diags
+}
+
+// parsePos parses "filename:line:column", where line and column are optional.
+func parsePos(s string) Pos {
+	var nums []int
+	for len(nums) < 2 {
+		idx := strings.LastIndex(s, ":")
+		if idx < 0 {
+			break
+		}
+		n, err := strconv.Atoi(s[idx+1:])
+		if err != nil {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		s = s[:idx]
+	}
+	pos := Pos{Filename: s}
+	if len(nums) > 0 {
+		pos.Line = nums[0]
+	}
+	if len(nums) > 1 {
+		pos.Column = nums[1]
+	}
+	return pos
+}
+
+func classifyDiagnostic(code, title, message, snippet string) ErrorKind {
+	lowerMsg := strings.ToLower(message)
+	switch {
+	case strings.Contains(title, "TypeError") || code == "E2G22":
+		return ErrorKindTypeError
+	case strings.Contains(lowerMsg, "check failed") || strings.Contains(title, "SchemaCheckFailure"):
+		return ErrorKindSchemaCheckFailure
+	case strings.Contains(title, "AssertionError") || strings.Contains(message, "AssertionError") ||
+		strings.HasPrefix(strings.TrimSpace(snippet), "assert "):
+		return ErrorKindAssertionError
+	case strings.HasPrefix(code, "E1") || strings.Contains(title, "Syntax") ||
+		strings.Contains(title, "TabError") || strings.Contains(title, "IndentationError"):
+		return ErrorKindSyntaxError
+	case strings.HasPrefix(code, "E2"):
+		return ErrorKindCompileError
+	case strings.HasPrefix(code, "E3") || strings.Contains(title, "EvaluationError") ||
+		strings.Contains(title, "RuntimeError") || strings.Contains(lowerMsg, "panic"):
+		return ErrorKindRuntimePanic
+	}
+	return ErrorKindUnknown
+}
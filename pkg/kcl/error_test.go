@@ -0,0 +1,97 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+func TestNewErrorTypeError(t *testing.T) {
+	const msg = `error[E2G22]: TypeError
+ --> /path/to/main.k:1:1
+  |
+1 | a: int = "1"
+  | ^ expected int, got str("1")
+  |
+`
+	var err error = NewError(msg)
+	var kerr *Error
+	tAssert(t, errors.As(fmt.Errorf("wrapped: %w", err), &kerr))
+	tAssert(t, kerr.Error() == msg, kerr.Error())
+	tAssert(t, len(kerr.Diagnostics) == 1, kerr.Diagnostics)
+
+	d := kerr.Diagnostics[0]
+	tAssert(t, d.Kind == ErrorKindTypeError, d.Kind)
+	tAssert(t, d.Code == "E2G22", d.Code)
+	tAssert(t, d.Pos == Pos{Filename: "/path/to/main.k", Line: 1, Column: 1}, d.Pos)
+	tAssert(t, d.Message == `expected int, got str("1")`, d.Message)
+	tAssert(t, d.Snippet == `a: int = "1"`, d.Snippet)
+}
+
+func TestNewErrorSchemaCheck(t *testing.T) {
+	const msg = `error[E3M38]: EvaluationError
+ --> main.k:7:1
+  |
+7 | p = Person {age = -1}
+  |  Instance check failed
+  |
+ --> main.k:4:1
+  |
+4 |         age > 0
+  |  Check failed on the condition
+  |
+`
+	kerr := NewError(msg)
+	tAssert(t, len(kerr.Diagnostics) == 2, kerr.Diagnostics)
+	for _, d := range kerr.Diagnostics {
+		tAssert(t, d.Kind == ErrorKindSchemaCheckFailure, d.Kind)
+	}
+	tAssert(t, kerr.Diagnostics[1].Pos.Line == 4, kerr.Diagnostics[1].Pos)
+}
+
+func TestNewErrorAssertion(t *testing.T) {
+	const msg = `error[E3M38]: EvaluationError
+ --> C:\work\main.k:2
+  |
+2 | assert False, "replicas must be positive"
+  |  replicas must be positive
+  |
+`
+	kerr := NewError(msg)
+	tAssert(t, len(kerr.Diagnostics) == 1, kerr.Diagnostics)
+	d := kerr.Diagnostics[0]
+	tAssert(t, d.Kind == ErrorKindAssertionError, d.Kind)
+	tAssert(t, d.Pos == Pos{Filename: `C:\work\main.k`, Line: 2}, d.Pos)
+	tAssert(t, d.Message == "replicas must be positive", d.Message)
+}
+
+func TestNewErrorPlainMessage(t *testing.T) {
+	kerr := NewError("Cannot find the module abc")
+	tAssert(t, len(kerr.Diagnostics) == 1, kerr.Diagnostics)
+	tAssert(t, kerr.Diagnostics[0].Kind == ErrorKindUnknown, kerr.Diagnostics[0].Kind)
+	tAssert(t, !kerr.Diagnostics[0].Pos.IsValid(), kerr.Diagnostics[0].Pos)
+
+	kerr = NewError("error[E3M38]: EvaluationError")
+	tAssert(t, len(kerr.Diagnostics) == 1, kerr.Diagnostics)
+	tAssert(t, kerr.Diagnostics[0].Kind == ErrorKindRuntimePanic, kerr.Diagnostics[0].Kind)
+}
+
+func TestNewErrorFromSpec(t *testing.T) {
+	tAssert(t, NewErrorFromSpec() == nil)
+
+	kerr := NewErrorFromSpec(&gpyrpc.Error{
+		Level: "error",
+		Code:  "E1001",
+		Messages: []*gpyrpc.Message{{
+			Msg: "unexpected token",
+			Pos: &gpyrpc.Position{Filename: "main.k", Line: 3, Column: 5},
+		}},
+	})
+	tAssert(t, len(kerr.Diagnostics) == 1, kerr.Diagnostics)
+	tAssert(t, kerr.Diagnostics[0].Kind == ErrorKindSyntaxError, kerr.Diagnostics[0].Kind)
+	tAssert(t, kerr.Error() == "main.k:3:5: SyntaxError: unexpected token", kerr.Error())
+}
@@ -0,0 +1,329 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kcl-lang.io/kcl-go/pkg/3rdparty/toml"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	tools_list "kcl-lang.io/kcl-go/pkg/tools/list"
+)
+
+// fsSource is the virtual file system set by WithFS.
+type fsSource struct {
+	fsys fs.FS
+	root string
+}
+
+// WithFS returns a Option which runs the KCL program stored in fsys, e.g. an
+// embed.FS. The root is the slash-separated path of the entry package (or
+// file) inside fsys. File names given to Run or WithKFilenames are relative
+// to root, and when there is none the entry package is run.
+//
+// The entry package, its local imports, the kcl.mod of the enclosing module
+// and the `path` dependencies listed in kcl.mod are read from fsys, other
+// dependencies are resolved by KCL as usual. Positions in error and log
+// messages use the slash-separated file names inside fsys. WithWorkDir is
+// ignored when WithFS is set.
+func WithFS(fsys fs.FS, root string) Option {
+	if fsys == nil {
+		return Option{Err: fmt.Errorf("kcl.WithFS: nil fs.FS")}
+	}
+	if root == "" {
+		root = "."
+	}
+	if !fs.ValidPath(root) {
+		return Option{Err: fmt.Errorf("kcl.WithFS(%q): invalid path", root)}
+	}
+	var opt = NewOption()
+	opt.fsys = &fsSource{fsys: fsys, root: root}
+	return *opt
+}
+
+// fsWorkspace is a copy of the files a program reads from a fsSource on the
+// local disk, which is where the KCL service loads them from.
+type fsWorkspace struct {
+	dir string
+}
+
+// fsProgram is the set of files a program reads from a fsSource.
+type fsProgram struct {
+	src      *fsSource
+	entries  []string
+	entryDir string
+	// files are the sorted slash-separated names of the files inside the
+	// fs.FS.
+	files []string
+	deps  []fsDependency
+}
+
+// loadFSProgram resolves the files the program of o.fsys reads.
+func loadFSProgram(o *Option) (*fsProgram, error) {
+	src := o.fsys
+
+	var entries []string
+	if len(o.KFilenameList) == 0 {
+		entries = []string{src.root}
+	}
+	for _, name := range o.KFilenameList {
+		name = pathpkg.Join(src.root, filepath.ToSlash(name))
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("kcl.WithFS: invalid file name %q", name)
+		}
+		entries = append(entries, name)
+	}
+
+	entryDir := src.root
+	if strings.HasSuffix(entryDir, ".k") {
+		entryDir = pathpkg.Dir(entryDir)
+	}
+	modRoot := findFSModRoot(src.fsys, entryDir)
+
+	files := make(map[string]struct{})
+	for _, name := range []string{"kcl.mod", "kcl.mod.lock"} {
+		if s := pathpkg.Join(modRoot, name); isFSFile(src.fsys, s) {
+			files[s] = struct{}{}
+		}
+	}
+	for _, entry := range entries {
+		if err := addFSAppFiles(src.fsys, modRoot, entry, files); err != nil {
+			return nil, err
+		}
+	}
+	deps, err := fsPathDependencies(src.fsys, modRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range deps {
+		if err := addFSDirFiles(src.fsys, dep.path, files); err != nil {
+			return nil, fmt.Errorf("kcl.WithFS: dependency %s: %w", dep.name, err)
+		}
+	}
+
+	p := &fsProgram{src: src, entries: entries, entryDir: entryDir, deps: deps}
+	for name := range files {
+		p.files = append(p.files, name)
+	}
+	sort.Strings(p.files)
+	return p, nil
+}
+
+// prepareFS copies the program files of o.fsys into a temporary directory and
+// rewrites the file names, work dir and external packages of o to point to
+// it. The returned workspace must be closed after the run.
+func prepareFS(o *Option) (*fsWorkspace, error) {
+	p, err := loadFSProgram(o)
+	if err != nil {
+		return nil, err
+	}
+	return p.prepare(o)
+}
+
+// prepare copies the files of p into a temporary directory, see prepareFS.
+func (p *fsProgram) prepare(o *Option) (*fsWorkspace, error) {
+	dir, err := os.MkdirTemp("", "kcl-fs-")
+	if err != nil {
+		return nil, err
+	}
+	// Use the canonical path, it is the one printed by KCL.
+	if s, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = s
+	}
+	w := &fsWorkspace{dir: dir}
+	for _, name := range p.files {
+		if err := w.copyFile(p.src.fsys, name); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	var filenames []string
+	for _, entry := range p.entries {
+		filenames = append(filenames, w.path(entry))
+	}
+	o.KFilenameList = filenames
+	o.WorkDir = w.path(p.entryDir)
+	for _, dep := range p.deps {
+		o.ExternalPkgs = append(o.ExternalPkgs, &gpyrpc.ExternalPkg{
+			PkgName: dep.name,
+			PkgPath: w.path(dep.path),
+		})
+	}
+	return w, nil
+}
+
+// path returns the local path of the slash-separated name inside the fs.FS.
+func (w *fsWorkspace) path(name string) string {
+	return filepath.Join(w.dir, filepath.FromSlash(name))
+}
+
+func (w *fsWorkspace) copyFile(fsys fs.FS, name string) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return fmt.Errorf("kcl.WithFS: %w", err)
+	}
+	path := w.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// rewriteResult replaces the local paths in the messages of resp with the
+// file names inside the fs.FS.
+func (w *fsWorkspace) rewriteResult(resp *gpyrpc.ExecProgramResult) {
+	if resp == nil {
+		return
+	}
+	resp.ErrMessage = w.rewrite(resp.ErrMessage)
+	resp.LogMessage = w.rewrite(resp.LogMessage)
+}
+
+func (w *fsWorkspace) rewrite(s string) string {
+	if s == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, w.dir+string(filepath.Separator), "")
+	if filepath.Separator != '/' {
+		s = strings.ReplaceAll(s, filepath.ToSlash(w.dir)+"/", "")
+	}
+	return s
+}
+
+// Close removes the local copy of the files.
+func (w *fsWorkspace) Close() error {
+	return os.RemoveAll(w.dir)
+}
+
+// findFSModRoot returns the nearest directory containing a kcl.mod from dir
+// upwards, or dir itself if there is none.
+func findFSModRoot(fsys fs.FS, dir string) string {
+	for d := dir; ; d = pathpkg.Dir(d) {
+		if isFSFile(fsys, pathpkg.Join(d, "kcl.mod")) {
+			return d
+		}
+		if d == "." {
+			return dir
+		}
+	}
+}
+
+// addFSAppFiles adds the files of the package or file entry and of its local
+// imports. When the imports can not be resolved, all the files of the module
+// are added and KCL reports the error.
+func addFSAppFiles(fsys fs.FS, modRoot, entry string, files map[string]struct{}) error {
+	if _, err := fs.Stat(fsys, entry); err != nil {
+		return fmt.Errorf("kcl.WithFS: %w", err)
+	}
+	rel := entry
+	if modRoot != "." {
+		rel = strings.TrimPrefix(strings.TrimPrefix(entry, modRoot), "/")
+		if rel == "" {
+			rel = "."
+		}
+	}
+	modFS, err := fs.Sub(fsys, modRoot)
+	if err != nil {
+		return err
+	}
+
+	parser := tools_list.NewSingleAppDepParserWithFS(modFS, tools_list.Option{
+		ExcludeExternalPackage: true,
+	})
+	appFiles, err := parser.GetAppFiles(rel, true)
+	if err != nil {
+		return addFSDirFiles(fsys, modRoot, files)
+	}
+	// Copy whole package directories, the dependency parser skips private
+	// `_xxx.k` files.
+	dirs := map[string]struct{}{pathpkg.Dir(entry): {}}
+	if fi, err := fs.Stat(fsys, entry); err == nil && fi.IsDir() {
+		dirs[entry] = struct{}{}
+	}
+	for _, s := range appFiles {
+		dirs[pathpkg.Dir(pathpkg.Join(modRoot, s))] = struct{}{}
+	}
+	for dir := range dirs {
+		dirEntries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return fmt.Errorf("kcl.WithFS: %w", err)
+		}
+		for _, d := range dirEntries {
+			if !d.IsDir() && strings.HasSuffix(d.Name(), ".k") {
+				files[pathpkg.Join(dir, d.Name())] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
+
+// addFSDirFiles adds the KCL files and module files under dir recursively.
+func addFSDirFiles(fsys fs.FS, dir string, files map[string]struct{}) error {
+	return fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".k") || d.Name() == "kcl.mod" || d.Name() == "kcl.mod.lock" {
+			files[path] = struct{}{}
+		}
+		return nil
+	})
+}
+
+type fsDependency struct {
+	name string
+	path string
+}
+
+// fsPathDependencies returns the `path` dependencies of the kcl.mod in
+// modRoot, e.g. `helper = { path = "../helper" }`, which must be inside fsys.
+func fsPathDependencies(fsys fs.FS, modRoot string) ([]fsDependency, error) {
+	modFile := pathpkg.Join(modRoot, "kcl.mod")
+	data, err := fs.ReadFile(fsys, modFile)
+	if err != nil {
+		return nil, nil
+	}
+	var mod struct {
+		Dependencies map[string]any `toml:"dependencies"`
+	}
+	if err := toml.Unmarshal(data, &mod); err != nil {
+		return nil, fmt.Errorf("kcl.WithFS: %s: %w", modFile, err)
+	}
+
+	var deps []fsDependency
+	for name, v := range mod.Dependencies {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		path, ok := m["path"].(string)
+		if !ok || path == "" {
+			continue
+		}
+		path = pathpkg.Join(modRoot, filepath.ToSlash(path))
+		if !fs.ValidPath(path) {
+			return nil, fmt.Errorf("kcl.WithFS: %s: dependency %s: path %q is outside of the fs.FS", modFile, name, m["path"])
+		}
+		deps = append(deps, fsDependency{name: name, path: path})
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].name < deps[j].name })
+	return deps, nil
+}
+
+func isFSFile(fsys fs.FS, name string) bool {
+	fi, err := fs.Stat(fsys, name)
+	return err == nil && !fi.IsDir()
+}
@@ -0,0 +1,94 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+func TestPrepareFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/kcl.mod":          {Data: []byte("[package]\nname = \"config\"\n\n[dependencies]\nhelper = { path = \"../helper\" }\nk8s = \"1.28\"\n")},
+		"config/app/main.k":       {Data: []byte("import lib.util\nimport helper\nimport k8s.api\n\na = util.name\n")},
+		"config/app/_private.k":   {Data: []byte("_b = 1\n")},
+		"config/lib/util.k":       {Data: []byte("name = \"app\"\n")},
+		"config/unused/unused.k":  {Data: []byte("c = 1\n")},
+		"helper/kcl.mod":          {Data: []byte("[package]\nname = \"helper\"\n")},
+		"helper/helper.k":         {Data: []byte("d = 1\n")},
+		"helper/sub/sub.k":        {Data: []byte("e = 1\n")},
+		"helper/.git/ignored.k":   {Data: []byte("f = 1\n")},
+		"unrelated/unrelated.txt": {Data: []byte("g")},
+	}
+
+	args, err := ParseArgs(nil, WithFS(fsys, "config/app"), WithWorkDir("ignored"))
+	tAssert(t, err == nil, err)
+	w, err := prepareFS(&args)
+	tAssert(t, err == nil, err)
+	defer w.Close()
+
+	var files []string
+	filepath.WalkDir(w.dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(w.dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	expect := []string{
+		"config/app/_private.k",
+		"config/app/main.k",
+		"config/kcl.mod",
+		"config/lib/util.k",
+		"helper/helper.k",
+		"helper/kcl.mod",
+		"helper/sub/sub.k",
+	}
+	tAssert(t, strings.Join(files, ",") == strings.Join(expect, ","), files)
+
+	tAssert(t, len(args.KFilenameList) == 1 && args.KFilenameList[0] == w.path("config/app"), args.KFilenameList)
+	tAssert(t, args.WorkDir == w.path("config/app"), args.WorkDir)
+	tAssert(t, len(args.ExternalPkgs) == 1, args.ExternalPkgs)
+	tAssert(t, args.ExternalPkgs[0].PkgName == "helper" && args.ExternalPkgs[0].PkgPath == w.path("helper"), args.ExternalPkgs[0])
+
+	resp := &gpyrpc.ExecProgramResult{
+		ErrMessage: "error[E2G22]: TypeError\n --> " + w.path("config/app/main.k") + ":5:1\n",
+	}
+	w.rewriteResult(resp)
+	err = NewError(resp.ErrMessage)
+	pos := err.(*Error).Diagnostics[0].Pos
+	tAssert(t, pos.Filename == "config/app/main.k" && pos.Line == 5, pos)
+}
+
+func TestPrepareFSFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.k":  {Data: []byte("a = 1\n")},
+		"other.k": {Data: []byte("b = 1\n")},
+	}
+	args, err := ParseArgs([]string{"other.k"}, WithFS(fsys, "."))
+	tAssert(t, err == nil, err)
+	w, err := prepareFS(&args)
+	tAssert(t, err == nil, err)
+	defer w.Close()
+	tAssert(t, len(args.KFilenameList) == 1 && args.KFilenameList[0] == w.path("other.k"), args.KFilenameList)
+
+	args, err = ParseArgs([]string{"missing.k"}, WithFS(fsys, "."))
+	tAssert(t, err == nil, err)
+	_, err = prepareFS(&args)
+	tAssert(t, err != nil, "expect an error for a missing file")
+}
+
+func TestWithFSInvalidPath(t *testing.T) {
+	opt := WithFS(fstest.MapFS{}, "../config")
+	tAssert(t, opt.Err != nil, "expect an error for a path outside of the fs.FS")
+
+	_, err := ParseArgs(nil, WithFS(nil, "."))
+	tAssert(t, err != nil, "expect an error for a nil fs.FS")
+}
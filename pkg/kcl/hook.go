@@ -14,12 +14,48 @@ var (
 	}
 )
 
+// Hook is called with the result of the KCL program before it is converted
+// into a KCLResultList. It may transform the result in place, or reject it
+// by returning an error, which aborts the run.
 type Hook interface {
 	Do(o *Option, r *gpyrpc.ExecProgramResult) error
 }
 
 type Hooks []Hook
 
+// PostResultHook is the hook kind which runs after the KCL program is executed.
+type PostResultHook = Hook
+
+// PreExecHook is called before the KCL program is executed. It may mutate the
+// arguments, e.g. inject options or add files, or reject them by returning an
+// error, which aborts the run.
+type PreExecHook interface {
+	PreExec(o *Option, args *gpyrpc.ExecProgramArgs) error
+}
+
+// PreExecHookFunc is an adapter to use an ordinary function as a PreExecHook.
+type PreExecHookFunc func(o *Option, args *gpyrpc.ExecProgramArgs) error
+
+func (f PreExecHookFunc) PreExec(o *Option, args *gpyrpc.ExecProgramArgs) error {
+	return f(o, args)
+}
+
+// PostResultHookFunc is an adapter to use an ordinary function as a PostResultHook.
+type PostResultHookFunc func(o *Option, r *gpyrpc.ExecProgramResult) error
+
+func (f PostResultHookFunc) Do(o *Option, r *gpyrpc.ExecProgramResult) error {
+	return f(o, r)
+}
+
+func runPreExecHooks(o *Option) error {
+	for _, hook := range o.preExecHooks {
+		if err := hook.PreExec(o, o.ExecProgramArgs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type typeAttributeHook struct{}
 
 func (t *typeAttributeHook) Do(o *Option, r *gpyrpc.ExecProgramResult) error {
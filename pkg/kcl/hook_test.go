@@ -0,0 +1,100 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+type labelHook struct{}
+
+func (labelHook) PreExec(o *Option, args *gpyrpc.ExecProgramArgs) error {
+	args.Args = append(args.Args, &gpyrpc.Argument{Name: "team", Value: "infra"})
+	return nil
+}
+
+func (labelHook) Do(o *Option, r *gpyrpc.ExecProgramResult) error {
+	r.YamlResult = strings.ReplaceAll(r.YamlResult, "app", "infra-app")
+	return nil
+}
+
+// hookService answers ExecProgram and counts the calls.
+type hookService struct {
+	api.ServiceClient
+	calls int
+}
+
+func (s *hookService) ExecProgram(*gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	s.calls++
+	return &gpyrpc.ExecProgramResult{JsonResult: `{"name": "app"}`, YamlResult: "name: app\n"}, nil
+}
+
+func TestWithHooks(t *testing.T) {
+	opt := WithHooks(labelHook{})
+	tAssert(t, opt.Err == nil, opt.Err)
+	tAssert(t, len(opt.preExecHooks) == 1 && len(opt.postResultHooks) == 1)
+
+	opt = WithHooks(struct{}{})
+	tAssert(t, opt.Err != nil, "expect error for an invalid hook")
+	opt = WithPreExecHooks(nil)
+	tAssert(t, opt.Err != nil, "expect error for a nil hook")
+
+	o := NewOption().Merge(WithPreExecHooks(labelHook{}), WithPostResultHooks(labelHook{}), WithPreExecHooks(labelHook{}))
+	tAssert(t, o.Err == nil, o.Err)
+	tAssert(t, len(o.preExecHooks) == 2 && len(o.postResultHooks) == 1)
+
+	svc := &hookService{}
+	result, err := Run("main.k", WithService(svc), WithHooks(labelHook{}))
+	tAssert(t, err == nil && result.First().Get("name") == "infra-app", result, err)
+
+	// A pre exec hook error aborts the run before the program is executed.
+	errForbidden := errors.New("forbidden")
+	svc = &hookService{}
+	_, err = Run("main.k", WithService(svc), WithHooks(PreExecHookFunc(func(o *Option, args *gpyrpc.ExecProgramArgs) error {
+		return errForbidden
+	})))
+	tAssert(t, errors.Is(err, errForbidden) && svc.calls == 0, svc.calls, err)
+
+	// A post result hook error aborts the run after it.
+	_, err = Run("main.k", WithService(svc), WithHooks(PostResultHookFunc(func(o *Option, r *gpyrpc.ExecProgramResult) error {
+		return errForbidden
+	})))
+	tAssert(t, errors.Is(err, errForbidden) && svc.calls == 1, svc.calls, err)
+}
+
+func TestPreExecHook(t *testing.T) {
+	args, err := ParseArgs([]string{"main.k"}, WithPreExecHooks(labelHook{}), WithOptions("env=prod"))
+	tAssert(t, err == nil, err)
+	tAssert(t, runPreExecHooks(&args) == nil)
+	tAssert(t, len(args.Args) == 2 && args.Args[1].Name == "team", args.Args)
+
+	errForbidden := errors.New("forbidden")
+	args, err = ParseArgs([]string{"main.k"}, WithPreExecHooks(PreExecHookFunc(func(o *Option, args *gpyrpc.ExecProgramArgs) error {
+		return errForbidden
+	})))
+	tAssert(t, err == nil, err)
+	tAssert(t, errors.Is(runPreExecHooks(&args), errForbidden))
+}
+
+func TestPostResultHook(t *testing.T) {
+	resp := &gpyrpc.ExecProgramResult{
+		JsonResult: `{"name": "app"}`,
+		YamlResult: "name: app",
+	}
+	o := NewOption().Merge(WithPostResultHooks(labelHook{}))
+	result, err := ExecResultToKCLResult(o, resp, nil, o.postResultHooks)
+	tAssert(t, err == nil, err)
+	tAssert(t, result.First().Get("name") == "infra-app", result.First().Get("name"))
+
+	errForbidden := errors.New("forbidden key")
+	reject := PostResultHookFunc(func(o *Option, r *gpyrpc.ExecProgramResult) error {
+		return errForbidden
+	})
+	_, err = ExecResultToKCLResult(o, resp, nil, Hooks{reject})
+	tAssert(t, errors.Is(err, errForbidden), err)
+}
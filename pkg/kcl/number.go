@@ -0,0 +1,309 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	goyaml "github.com/goccy/go-yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// NumberMode controls how the numbers of a result are decoded, see
+// WithNumberMode.
+type NumberMode int
+
+const (
+	// NumberModeDefault decodes integers as int and other numbers as float64.
+	NumberModeDefault NumberMode = iota
+	// NumberModeJSONNumber decodes every number as a json.Number holding
+	// the literal of the KCL output, e.g. "1.0" or "9007199254740993".
+	NumberModeJSONNumber
+	// NumberModeExact decodes integers as int64 and other numbers as a
+	// json.Number holding their literal, so integers are plain values and
+	// no float is rounded.
+	NumberModeExact
+)
+
+// WithNumberMode returns a Option which sets how the numbers of the result
+// are decoded from its JSON output. Get, GetValue, ToMap, Decode and the
+// Query helpers see the decoded numbers, and with a mode other than
+// NumberModeDefault, JSONString, YAMLString and Encode write the numbers as
+// the KCL output has them, so `1.0` stays a float and large integers and
+// precise floats survive the round trip.
+func WithNumberMode(mode NumberMode) Option {
+	if mode < NumberModeDefault || mode > NumberModeExact {
+		return Option{Err: fmt.Errorf("kcl.WithNumberMode(%d): unknown number mode", mode)}
+	}
+	var opt = NewOption()
+	opt.numberMode = mode
+	return *opt
+}
+
+// numberLiteral is a number of the KCL output kept as its literal, it is
+// written as is by all the encoders.
+type numberLiteral string
+
+func (n numberLiteral) MarshalJSON() ([]byte, error) { return []byte(n), nil }
+
+// MarshalYAML implements goyaml.BytesMarshaler.
+func (n numberLiteral) MarshalYAML() ([]byte, error) { return []byte(n), nil }
+
+func (n numberLiteral) MarshalTOML() ([]byte, error) { return []byte(n), nil }
+
+func (n numberLiteral) String() string { return string(n) }
+
+// numberLiteralOf returns the literal of a number node, if it is a valid
+// JSON number. Literals like `.inf` or `0x1F` are decoded as usual.
+func numberLiteralOf(node *yaml.Node) (string, bool) {
+	if node.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	if tag := node.ShortTag(); tag != "!!int" && tag != "!!float" {
+		return "", false
+	}
+	var n json.Number
+	if err := json.Unmarshal([]byte(node.Value), &n); err != nil {
+		return "", false
+	}
+	return node.Value, true
+}
+
+// jsonDocuments splits the JsonResult s into the JSON values of its n YAML
+// documents, written one after another or, for several documents, as one
+// array. ok is false otherwise and the numbers are then read from the YAML
+// documents, whose number literals KCL writes the same way.
+func jsonDocuments(s string, n int) ([]json.RawMessage, bool) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	var docs []json.RawMessage
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, false
+		}
+		docs = append(docs, raw)
+	}
+	if len(docs) == 1 && n > 1 {
+		var list []json.RawMessage
+		if json.Unmarshal(docs[0], &list) == nil {
+			docs = list
+		}
+	}
+	return docs, len(docs) == n
+}
+
+// decodeJSONNumbers decodes a JSON value into an any, with the numbers
+// decoded as mode says.
+func decodeJSONNumbers(data []byte, mode NumberMode) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if mode == NumberModeExact {
+		v = exactIntegers(v)
+	}
+	return v, nil
+}
+
+// exactIntegers replaces the integer literals of v with int64 values.
+func exactIntegers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			v[k] = exactIntegers(x)
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = exactIntegers(x)
+		}
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+		}
+	}
+	return v
+}
+
+// jsonLiteralValue decodes the next JSON value of dec with mappings as
+// goyaml.MapSlice in the key order of the input and the numbers as their
+// literal, dec must use json.Decoder.UseNumber.
+func jsonLiteralValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			list := []any{}
+			for dec.More() {
+				v, err := jsonLiteralValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		m := goyaml.MapSlice{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := jsonLiteralValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, goyaml.MapItem{Key: key, Value: v})
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Number:
+		return numberLiteral(tok), nil
+	}
+	return tok, nil
+}
+
+// decodeNumberNode decodes a YAML node like yaml.v3 does into an any, with
+// the numbers decoded as mode says.
+func decodeNumberNode(node *yaml.Node, mode NumberMode) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return decodeNumberNode(node.Content[0], mode)
+	case yaml.AliasNode:
+		return decodeNumberNode(node.Alias, mode)
+	case yaml.MappingNode:
+		m := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := decodeNumberNode(node.Content[i+1], mode)
+			if err != nil {
+				return nil, err
+			}
+			m[node.Content[i].Value] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			v, err := decodeNumberNode(item, mode)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	if lit, ok := numberLiteralOf(node); ok {
+		if mode == NumberModeExact && node.ShortTag() == "!!int" {
+			if i, err := strconv.ParseInt(lit, 10, 64); err == nil {
+				return i, nil
+			}
+		}
+		return json.Number(lit), nil
+	}
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// literalValue returns the result with mappings as goyaml.MapSlice and the
+// numbers as their literal, in sorted key order if sortKeys is set. The
+// value is read from the JSON document of the result if there is one.
+func (m *KCLResult) literalValue(sortKeys bool) (any, error) {
+	var v any
+	var err error
+	if m.jsonDocument != "" {
+		dec := json.NewDecoder(strings.NewReader(m.jsonDocument))
+		dec.UseNumber()
+		v, err = jsonLiteralValue(dec)
+	} else {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(m.document), &node); err != nil {
+			return nil, err
+		}
+		v, err = nodeValueMode(&node, NumberModeJSONNumber)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sortKeys {
+		v = sortedValue(v)
+	}
+	return v, nil
+}
+
+// literalJSONString is JSONString for the results with a number mode.
+func (m *KCLResult) literalJSONString() (string, bool) {
+	v, err := m.literalValue(true)
+	if err != nil {
+		return "", false
+	}
+	var buf bytes.Buffer
+	writeJSON(&buf, v, "    ", "")
+	return buf.String(), true
+}
+
+// literalYAMLString is YAMLString for the results with a number mode.
+func (m *KCLResult) literalYAMLString() (string, bool) {
+	v, err := m.literalValue(true)
+	if err != nil {
+		return "", false
+	}
+	out, err := goyaml.MarshalWithOptions(v, goyaml.Indent(4), goyaml.IndentSequence(true))
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+// numberInto stores the number v into target, one of *int, *int64,
+// *float64, *string or *json.Number.
+func numberInto(key string, v any, target any) (any, error) {
+	var n json.Number
+	switch v := v.(type) {
+	case int64:
+		n = json.Number(strconv.FormatInt(v, 10))
+	case json.Number:
+		n = v
+	}
+	var err error
+	switch target := target.(type) {
+	case *json.Number:
+		*target = n
+	case *string:
+		*target = n.String()
+	case *int64:
+		*target, err = n.Int64()
+	case *int:
+		var i int64
+		if i, err = n.Int64(); err == nil {
+			*target = int(i)
+		}
+	case *float64:
+		*target, err = n.Float64()
+	default:
+		return v, fmt.Errorf("%s expect *int, *int64, *float64, *string or *json.Number type: got = %T", key, target)
+	}
+	if err != nil {
+		return v, fmt.Errorf("%s: %w", key, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,132 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+const numberTestYAML = `id: 9007199254740993
+ratio: 1.0
+pi: 3.14159265358979323846
+size: 12
+name: app
+`
+
+const numberTestJSON = `{
+    "id": 9007199254740993,
+    "ratio": 1.0,
+    "pi": 3.14159265358979323846,
+    "size": 12,
+    "name": "app"
+}`
+
+func numberTestResult(t *testing.T, mode NumberMode) *KCLResult {
+	t.Helper()
+	o := NewOption().Merge(WithNumberMode(mode))
+	list, err := ExecResultToKCLResult(o, &gpyrpc.ExecProgramResult{
+		JsonResult: numberTestJSON,
+		YamlResult: numberTestYAML,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list.First()
+}
+
+func TestWithNumberMode(t *testing.T) {
+	r := numberTestResult(t, NumberModeJSONNumber)
+	m, err := r.ToMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, m["id"] == json.Number("9007199254740993"), m["id"])
+	tAssert(t, m["ratio"] == json.Number("1.0"), m["ratio"])
+	tAssert(t, m["name"] == "app", m["name"])
+
+	var id int64
+	_, err = r.GetValue("id", &id)
+	tAssert(t, err == nil && id == 9007199254740993, id, err)
+	var size int
+	_, err = r.GetValue("size", &size)
+	tAssert(t, err == nil && size == 12, size, err)
+	var pi json.Number
+	_, err = r.GetValue("pi", &pi)
+	tAssert(t, err == nil && pi == "3.14159265358979323846", pi, err)
+
+	var target struct {
+		ID    int64       `json:"id"`
+		Ratio float64     `json:"ratio"`
+		Pi    json.Number `json:"pi"`
+	}
+	if err := r.Decode(&target); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, target.ID == 9007199254740993 && target.Ratio == 1 && target.Pi == "3.14159265358979323846", target)
+
+	var buf bytes.Buffer
+	if err := r.Encode(&buf, EncodeJSON); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, strings.Contains(buf.String(), `"id": 9007199254740993,`), buf.String())
+	tAssert(t, strings.Contains(buf.String(), `"ratio": 1.0,`), buf.String())
+	buf.Reset()
+	if err := r.Encode(&buf, EncodeYAML); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, buf.String() == numberTestYAML, buf.String())
+	buf.Reset()
+	if err := r.Encode(&buf, EncodeTOML); err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, strings.Contains(buf.String(), "ratio = 1.0\n"), buf.String())
+
+	tAssert(t, strings.Contains(r.JSONString(), `"pi": 3.14159265358979323846`), r.JSONString())
+	tAssert(t, strings.Contains(r.YAMLString(), "ratio: 1.0\n"), r.YAMLString())
+
+	r = numberTestResult(t, NumberModeExact)
+	tAssert(t, r.Get("id") == int64(9007199254740993), r.Get("id"))
+	tAssert(t, r.Get("ratio") == json.Number("1.0"), r.Get("ratio"))
+
+	// The default mode is unchanged.
+	r = numberTestResult(t, NumberModeDefault)
+	tAssert(t, r.Get("size") == 12, r.Get("size"))
+
+	o := WithNumberMode(NumberMode(42))
+	tAssert(t, o.Err != nil, o.Err)
+}
+
+func TestNumberModeJSONResult(t *testing.T) {
+	o := NewOption().Merge(WithNumberMode(NumberModeExact))
+	list, err := ExecResultToKCLResult(o, &gpyrpc.ExecProgramResult{
+		JsonResult: `[{"id": 9007199254740993, "ratio": 1.0}, {"id": 2}]`,
+		// The YAML literals differ so the test tells which one is decoded.
+		YamlResult: "id: 1\nratio: 1\n---\nid: 1\n",
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := list.Slice()
+	tAssert(t, len(docs) == 2, docs)
+	tAssert(t, docs[0].Get("id") == int64(9007199254740993), docs[0].Get("id"))
+	tAssert(t, docs[0].Get("ratio") == json.Number("1.0"), docs[0].Get("ratio"))
+	tAssert(t, docs[1].Get("id") == int64(2), docs[1].Get("id"))
+	tAssert(t, docs[0].JSONString() == "{\n    \"id\": 9007199254740993,\n    \"ratio\": 1.0\n}", docs[0].JSONString())
+
+	// The numbers are read from the YAML when the JSON does not hold one
+	// value per document.
+	list, err = ExecResultToKCLResult(o, &gpyrpc.ExecProgramResult{
+		JsonResult: `{"id": 1}`,
+		YamlResult: "id: 3\n---\nid: 4\n",
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs = list.Slice()
+	tAssert(t, len(docs) == 2 && docs[0].Get("id") == int64(3) && docs[1].Get("id") == int64(4), docs)
+}
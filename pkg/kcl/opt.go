@@ -6,17 +6,38 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"kcl-lang.io/kcl-go/pkg/settings"
 	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
 )
 
 type Option struct {
 	*gpyrpc.ExecProgramArgs
 	logger       io.Writer
 	fullTypePath bool
-	Err          error
+	timeout      time.Duration
+	// Per-call hooks, run after DefaultHooks.
+	preExecHooks    []PreExecHook
+	postResultHooks Hooks
+	cache           Cache
+	fsys            *fsSource
+	strictOptions   bool
+	// settingsFiles are the files loaded by WithSettings, reloaded when a
+	// profile is selected. They are not merged, merged options keep the
+	// settings without the profile.
+	settingsFiles   []string
+	settingsProfile string
+	settingsOutput  string
+	redaction       *RedactOptions
+	numberMode      NumberMode
+	service         api.ServiceClient
+	Err             error
 }
 
 // NewOption returns a new Option.
@@ -38,18 +59,37 @@ func (p *Option) GetLogger() io.Writer {
 	return p.logger
 }
 
+// GetTimeout returns the per-run timeout set by WithTimeout, zero means no timeout.
+func (p *Option) GetTimeout() time.Duration {
+	return p.timeout
+}
+
 func ParseArgs(pathList []string, opts ...Option) (Option, error) {
 	var tmpOptList []Option
 	for _, s := range pathList {
 		tmpOptList = append(tmpOptList, WithKFilenames(s))
 	}
 
+	var profile string
+	for _, opt := range opts {
+		if opt.settingsProfile != "" {
+			profile = opt.settingsProfile
+		}
+	}
+	if profile != "" {
+		var err error
+		if opts, err = applySettingsProfile(opts, profile); err != nil {
+			return Option{}, err
+		}
+	}
+
 	args := NewOption().Merge(opts...).Merge(tmpOptList...)
 	if err := args.Err; err != nil {
 		return Option{}, err
 	}
 
-	if len(args.KFilenameList) == 0 {
+	// WithFS runs its entry package when no file is given.
+	if len(args.KFilenameList) == 0 && args.fsys == nil {
 		return Option{}, fmt.Errorf("kcl.Run: no kcl file")
 	}
 
@@ -62,6 +102,61 @@ func WithLogger(l io.Writer) Option {
 	return *opt
 }
 
+// WithTimeout returns a Option which hold a per-run timeout. When the timeout
+// elapses, the run returns a *CancelError wrapping context.DeadlineExceeded.
+func WithTimeout(timeout time.Duration) Option {
+	var opt = NewOption()
+	opt.timeout = timeout
+	return *opt
+}
+
+// WithHooks returns a Option which hold a per-call hook list. Each hook must
+// implement PreExecHook, PostResultHook or both, the pre exec hooks run
+// before the KCL program is executed and the post result hooks on its
+// result, after the DefaultHooks. Hooks run in the given order and an error
+// returned by any hook aborts the run.
+func WithHooks(hooks ...any) Option {
+	var opt = NewOption()
+	for i, hook := range hooks {
+		pre, isPre := hook.(PreExecHook)
+		post, isPost := hook.(PostResultHook)
+		if !isPre && !isPost {
+			return Option{Err: fmt.Errorf("kcl.WithHooks: hook %d (%T) is neither a PreExecHook nor a PostResultHook", i, hook)}
+		}
+		if isPre {
+			opt.preExecHooks = append(opt.preExecHooks, pre)
+		}
+		if isPost {
+			opt.postResultHooks = append(opt.postResultHooks, post)
+		}
+	}
+	return *opt
+}
+
+// WithPreExecHooks is like WithHooks with hooks which only run before the
+// KCL program is executed, even if they also implement PostResultHook.
+func WithPreExecHooks(hooks ...PreExecHook) Option {
+	list := make([]any, len(hooks))
+	for i, hook := range hooks {
+		if hook != nil {
+			list[i] = PreExecHookFunc(hook.PreExec)
+		}
+	}
+	return WithHooks(list...)
+}
+
+// WithPostResultHooks is like WithHooks with hooks which only run on the
+// result of the KCL program, even if they also implement PreExecHook.
+func WithPostResultHooks(hooks ...PostResultHook) Option {
+	list := make([]any, len(hooks))
+	for i, hook := range hooks {
+		if hook != nil {
+			list[i] = PostResultHookFunc(hook.Do)
+		}
+	}
+	return WithHooks(list...)
+}
+
 func WithWorkDir(s string) Option {
 	var opt = NewOption()
 	opt.WorkDir = s
@@ -126,6 +221,72 @@ func WithOptions(key_value_list ...string) Option {
 	return *opt
 }
 
+// WithOptionValue returns a Option which hold a top level argument whose
+// value is the JSON encoding of v, e.g. a string, a number, a map, a slice
+// or a struct with json tags.
+//
+//	kcl -D name='{"replicas": 3}' main.k
+func WithOptionValue(name string, v any) Option {
+	if name == "" {
+		return Option{Err: fmt.Errorf("kcl.WithOptionValue: empty option name")}
+	}
+	value, err := settings.EncodeOptionValue(v)
+	if err != nil {
+		return Option{Err: fmt.Errorf("kcl.WithOptionValue(%q): %v", name, err)}
+	}
+	var opt = NewOption()
+	opt.Args = []*gpyrpc.Argument{{Name: name, Value: value}}
+	return *opt
+}
+
+// WithOptionsFile returns a Option which hold the top level arguments loaded
+// from a JSON, YAML or TOML file. Each top level key of the file is an
+// argument name, and its value is encoded like WithOptionValue.
+func WithOptionsFile(filename string) Option {
+	args, err := settings.LoadOptionsFile(filename)
+	if err != nil {
+		return Option{Err: fmt.Errorf("kcl.WithOptionsFile(%q): %v", filename, err)}
+	}
+	var opt = NewOption()
+	opt.Args = args
+	return *opt
+}
+
+// WithOptionsFromEnv returns a Option which hold a top level argument for
+// each environment variable starting with prefix. The argument name is the
+// variable name without prefix, e.g. APP_env=prod with the prefix "APP_" is
+// the argument env. Values which are valid JSON are used as JSON, e.g. 3 or
+// [1, 2], the other ones as strings, and both are encoded like
+// WithOptionValue. Arguments are sorted by name.
+func WithOptionsFromEnv(prefix string) Option {
+	if prefix == "" {
+		return Option{Err: fmt.Errorf("kcl.WithOptionsFromEnv: empty prefix")}
+	}
+	var args []*gpyrpc.Argument
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		var v any = value
+		if json.Valid([]byte(value)) {
+			v = json.RawMessage(value)
+		}
+		encoded, err := settings.EncodeOptionValue(v)
+		if err != nil {
+			return Option{Err: fmt.Errorf("kcl.WithOptionsFromEnv: %s: %v", name, err)}
+		}
+		args = append(args, &gpyrpc.Argument{
+			Name:  strings.TrimPrefix(name, prefix),
+			Value: encoded,
+		})
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i].Name < args[j].Name })
+	var opt = NewOption()
+	opt.Args = args
+	return *opt
+}
+
 // kcl -O pkgpath:path.to.field=field_value
 // kcl -O pkgpath.path.to.field-
 func WithOverrides(overrides ...string) Option {
@@ -148,19 +309,71 @@ func WithPrintOverridesAST(printOverrideAst bool) Option {
 }
 
 // kcl -Y settings.yaml
-func WithSettings(filename string) Option {
-	if filename == "" {
+//
+// WithSettings returns a Option which holds the settings files. Several
+// files are merged in order: later files override the scalars of earlier
+// ones and append to their lists, see settings.LoadFiles. Empty file names
+// are ignored.
+func WithSettings(filenames ...string) Option {
+	var files []string
+	for _, filename := range filenames {
+		if filename != "" {
+			files = append(files, filename)
+		}
+	}
+	if len(files) == 0 {
 		return Option{}
 	}
-	f, err := settings.LoadFile(filename, nil)
+	return loadSettings(files, "")
+}
+
+func loadSettings(files []string, profile string) Option {
+	f, err := settings.LoadFiles(files, profile)
 	if err != nil {
-		return Option{Err: fmt.Errorf("kcl.WithSettings(%q): %v", filename, err)}
+		var names []string
+		for _, file := range files {
+			names = append(names, strconv.Quote(file))
+		}
+		return Option{Err: fmt.Errorf("kcl.WithSettings(%s): %v", strings.Join(names, ", "), err)}
 	}
 	var opt = NewOption()
 	opt.ExecProgramArgs = f.To_ExecProgramArgs()
+	opt.settingsFiles = files
+	opt.settingsOutput = f.Config.Output
+	return *opt
+}
+
+// WithSettingsProfile returns a Option which selects a profile of the
+// settings files given by WithSettings, i.e. the `profiles.<name>` section
+// merged over the rest of the settings. The profile is applied by the run
+// APIs, regardless of the order of the options.
+func WithSettingsProfile(name string) Option {
+	var opt = NewOption()
+	opt.settingsProfile = name
 	return *opt
 }
 
+// applySettingsProfile reloads the settings files of every WithSettings
+// option in opts with the profile.
+func applySettingsProfile(opts []Option, profile string) ([]Option, error) {
+	opts = append([]Option(nil), opts...)
+	found := false
+	for i, opt := range opts {
+		if len(opt.settingsFiles) == 0 {
+			continue
+		}
+		found = true
+		opts[i] = loadSettings(opt.settingsFiles, profile)
+		if opts[i].Err != nil {
+			return nil, opts[i].Err
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kcl.WithSettingsProfile(%q): no settings file", profile)
+	}
+	return opts, nil
+}
+
 // kcl -n --disable_none
 func WithDisableNone(disableNone bool) Option {
 	var opt = NewOption()
@@ -278,6 +491,39 @@ func (p *Option) Merge(opts ...Option) *Option {
 		if opt.logger != nil {
 			p.logger = opt.logger
 		}
+		if opt.timeout > 0 {
+			p.timeout = opt.timeout
+		}
+		if opt.cache != nil {
+			p.cache = opt.cache
+		}
+		if opt.fsys != nil {
+			p.fsys = opt.fsys
+		}
+		if opt.strictOptions {
+			p.strictOptions = opt.strictOptions
+		}
+		if opt.settingsProfile != "" {
+			p.settingsProfile = opt.settingsProfile
+		}
+		if opt.settingsOutput != "" {
+			p.settingsOutput = opt.settingsOutput
+		}
+		if opt.redaction != nil {
+			p.redaction = p.redaction.merge(opt.redaction)
+		}
+		if opt.numberMode != NumberModeDefault {
+			p.numberMode = opt.numberMode
+		}
+		if opt.service != nil {
+			p.service = opt.service
+		}
+		if len(opt.preExecHooks) > 0 {
+			p.preExecHooks = append(p.preExecHooks, opt.preExecHooks...)
+		}
+		if len(opt.postResultHooks) > 0 {
+			p.postResultHooks = append(p.postResultHooks, opt.postResultHooks...)
+		}
 	}
 	return p
 }
@@ -4,6 +4,7 @@ package kcl
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -93,3 +94,101 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestWithOptionValue(t *testing.T) {
+	opt := NewOption().Merge(
+		WithOptionValue("name", "a=b"),
+		WithOptionValue("labels", map[string]string{"app": "kcl"}),
+	)
+	tAssert(t, opt.Err == nil, opt.Err)
+	tAssert(t, len(opt.Args) == 2, opt.Args)
+	tAssert(t, opt.Args[0].Name == "name" && opt.Args[0].Value == `"a=b"`, opt.Args[0])
+	tAssert(t, opt.Args[1].Name == "labels" && opt.Args[1].Value == `{"app":"kcl"}`, opt.Args[1])
+
+	tAssert(t, WithOptionValue("fn", func() {}).Err != nil, "expect an error for a func value")
+	tAssert(t, WithOptionValue("", 1).Err != nil, "expect an error for an empty name")
+}
+
+func TestWithOptionsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "options.yaml")
+	tAssert(t, os.WriteFile(filename, []byte("env: prod\nreplicas: 2\n"), 0o644) == nil)
+
+	opt := WithOptionsFile(filename)
+	tAssert(t, opt.Err == nil, opt.Err)
+	tAssert(t, len(opt.Args) == 2, opt.Args)
+	tAssert(t, opt.Args[0].Name == "env" && opt.Args[0].Value == `"prod"`, opt.Args[0])
+	tAssert(t, opt.Args[1].Name == "replicas" && opt.Args[1].Value == `2`, opt.Args[1])
+
+	tAssert(t, WithOptionsFile(filename+".missing").Err != nil, "expect an error for a missing file")
+}
+
+func TestWithOptionsFromEnv(t *testing.T) {
+	t.Setenv("KCL_GO_TEST_OPT_replicas", "3")
+	t.Setenv("KCL_GO_TEST_OPT_env", "prod")
+	t.Setenv("KCL_GO_TEST_OPT_ports", "[80, 443]")
+	t.Setenv("KCL_GO_TEST_OPT_motd", `say "hi"`)
+	t.Setenv("KCL_GO_TEST_OPT_", "ignored")
+
+	opt := WithOptionsFromEnv("KCL_GO_TEST_OPT_")
+	tAssert(t, opt.Err == nil, opt.Err)
+	tAssert(t, len(opt.Args) == 4, opt.Args)
+	tAssert(t, opt.Args[0].Name == "env" && opt.Args[0].Value == `"prod"`, opt.Args[0])
+	tAssert(t, opt.Args[1].Name == "motd" && opt.Args[1].Value == `"say \"hi\""`, opt.Args[1])
+	tAssert(t, opt.Args[2].Name == "ports" && opt.Args[2].Value == "[80,443]", opt.Args[2])
+	tAssert(t, opt.Args[3].Name == "replicas" && opt.Args[3].Value == "3", opt.Args[3])
+
+	tAssert(t, WithOptionsFromEnv("").Err != nil, "expect an error for an empty prefix")
+}
+
+func TestWithSettingsProfile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "kcl.yaml")
+	if err := os.WriteFile(filename, []byte(`
+kcl_cli_configs:
+  file: [main.k]
+  output: out.yaml
+profiles:
+  prod:
+    kcl_cli_configs:
+      output: prod.json
+    kcl_options:
+      - key: env
+        value: prod
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := ParseArgs(nil, WithSettingsProfile("prod"), WithSettings(filename), WithOptions("a=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, args.settingsOutput == "prod.json", args.settingsOutput)
+	tAssert(t, len(args.Args) == 2 && args.Args[0].Name == "env" && args.Args[1].Name == "a", args.Args)
+
+	args, err = ParseArgs(nil, WithSettings(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, args.settingsOutput == "out.yaml" && len(args.Args) == 0, args.settingsOutput)
+
+	_, err = ParseArgs(nil, WithSettings(filename), WithSettingsProfile("dev"))
+	tAssert(t, err != nil)
+	_, err = ParseArgs([]string{"main.k"}, WithSettingsProfile("prod"))
+	tAssert(t, err != nil)
+}
+
+func TestOutputFormatOf(t *testing.T) {
+	for output, format := range map[string]EncodeFormat{
+		"out/a.yml":        EncodeYAML,
+		"a.JSON":           EncodeJSON,
+		"main.tfvars.json": EncodeTFVarsJSON,
+		"main.tfvars":      EncodeTFVars,
+		".env":             EncodeDotenv,
+		"app.properties":   EncodeProperties,
+	} {
+		got, err := outputFormatOf(output)
+		tAssert(t, err == nil && got == format, output, got, err)
+	}
+	_, err := outputFormatOf("a.txt")
+	tAssert(t, err != nil)
+}
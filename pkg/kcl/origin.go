@@ -0,0 +1,486 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	goyaml "github.com/goccy/go-yaml"
+
+	"kcl-lang.io/kcl-go/pkg/ast"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// resultSource is the program a result was produced by, parsed on demand to
+// find the origins of output values.
+type resultSource struct {
+	workDir string
+	files   []string
+	codes   []string
+	fsys    *fsSource
+	service api.ServiceClient
+
+	once    sync.Once
+	entries []originEntry
+	err     error
+}
+
+func newResultSource(o *Option) *resultSource {
+	if o == nil || o.ExecProgramArgs == nil {
+		return nil
+	}
+	return &resultSource{
+		workDir: o.WorkDir,
+		files:   append([]string(nil), o.KFilenameList...),
+		codes:   append([]string(nil), o.KCodeList...),
+		fsys:    o.fsys,
+		service: o.GetService(),
+	}
+}
+
+// Origin returns the positions of the statements and config entries which
+// contributed to the output value at path, e.g. `spec.replicas` or
+// `spec.containers[0].image`. These are the assignments of the value
+// itself or of the values nested in it, or if there are none, the closest
+// assignment of an enclosing value, e.g. `spec = make_spec()`.
+//
+// The positions are found in the files of the main package of the run,
+// including schema attribute defaults, with later `=` overrides hiding
+// earlier assignments. Values computed by expressions, e.g. lambdas,
+// comprehensions or imported packages, are attributed to the expression.
+// The origin is only available for results returned by the run APIs, it is
+// nil otherwise or if path is invalid, see LookupOrigin for the error.
+func (m *KCLResult) Origin(path string) []ast.Pos {
+	positions, _ := m.LookupOrigin(path)
+	return positions
+}
+
+// LookupOrigin is like Origin but returns the error of an invalid path, of a
+// result which is not returned by a run API or of the parsing of the files.
+func (m *KCLResult) LookupOrigin(path string) ([]ast.Pos, error) {
+	keys, err := originKeysOf(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := m.originEntries()
+	if err != nil {
+		return nil, err
+	}
+	return findOrigin(entries, keys), nil
+}
+
+// Explain writes every leaf value of the result with its path and origin,
+// one per line, e.g.
+//
+//	spec.replicas: 3  # main.k:4:5
+func (m *KCLResult) Explain(w io.Writer) error {
+	entries, err := m.originEntries()
+	if err != nil {
+		return err
+	}
+	v, err := m.orderedValue(false)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	var walk func(path string, keys []originKey, v any)
+	walk = func(path string, keys []originKey, v any) {
+		switch v := v.(type) {
+		case goyaml.MapSlice:
+			for _, item := range v {
+				k := fmt.Sprint(item.Key)
+				walk(diffPathKey(path, k), append(keys[:len(keys):len(keys)], originKey{name: k}), item.Value)
+			}
+			return
+		case []any:
+			for i, item := range v {
+				walk(path+"["+strconv.Itoa(i)+"]", append(keys[:len(keys):len(keys)], originKey{index: i, isIndex: true}), item)
+			}
+			return
+		}
+		var origins []string
+		for _, pos := range findOrigin(entries, keys) {
+			origins = append(origins, fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column))
+		}
+		if len(origins) == 0 {
+			origins = []string{"unknown"}
+		}
+		fmt.Fprintf(&buf, "%s: %s  # %s\n", path, diffValueString(v), strings.Join(origins, ", "))
+	}
+	walk("", nil, v)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (m *KCLResult) originEntries() ([]originEntry, error) {
+	if m.source == nil {
+		return nil, errors.New("kcl: the origin is only available for results of a run")
+	}
+	src := m.source
+	src.once.Do(func() {
+		src.entries, src.err = src.load()
+	})
+	return src.entries, src.err
+}
+
+func findOrigin(entries []originEntry, keys []originKey) []ast.Pos {
+	var inner, outer []ast.Pos
+	longest := -1
+	for _, e := range entries {
+		switch {
+		case hasKeyPrefix(e.path, keys):
+			inner = append(inner, e.pos)
+		case hasKeyPrefix(keys, e.path):
+			if len(e.path) > longest {
+				longest, outer = len(e.path), nil
+			}
+			if len(e.path) == longest {
+				outer = append(outer, e.pos)
+			}
+		}
+	}
+	if len(inner) > 0 {
+		return inner
+	}
+	return outer
+}
+
+// originKey is a key of a mapping or an index of a list in an output path.
+type originKey struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// originEntry is a statement or config entry which assigns a value at path.
+type originEntry struct {
+	path []originKey
+	pos  ast.Pos
+	// isDefault reports a schema attribute default, hidden by any later
+	// assignment of the attribute.
+	isDefault bool
+}
+
+func hasKeyPrefix(path, prefix []originKey) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func originKeysOf(path string) ([]originKey, error) {
+	q, err := parseQuery(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []originKey
+	for _, step := range q.steps {
+		switch {
+		case step.kind == stepField:
+			keys = append(keys, originKey{name: step.name})
+		case step.kind == stepIndex && step.index >= 0:
+			keys = append(keys, originKey{index: step.index, isIndex: true})
+		default:
+			return nil, &QueryError{Expr: path, Offset: -1, Msg: "expect a path of names and non-negative indexes"}
+		}
+	}
+	return keys, nil
+}
+
+// load parses the files of the main package and collects the origins.
+func (s *resultSource) load() ([]originEntry, error) {
+	names := s.files
+	readFile, readDir, join := os.ReadFile, os.ReadDir, filepath.Join
+	if s.fsys != nil {
+		fsys := s.fsys.fsys
+		readFile = func(name string) ([]byte, error) { return fs.ReadFile(fsys, name) }
+		readDir = func(name string) ([]fs.DirEntry, error) { return fs.ReadDir(fsys, name) }
+		join = pathpkg.Join
+		if len(names) == 0 {
+			names = []string{""}
+		}
+	}
+
+	type file struct{ name, code string }
+	var files []file
+	for i, name := range names {
+		if s.fsys != nil {
+			name = pathpkg.Join(s.fsys.root, filepath.ToSlash(name))
+		} else if s.workDir != "" && !filepath.IsAbs(name) {
+			name = filepath.Join(s.workDir, name)
+		}
+		if i < len(s.codes) {
+			files = append(files, file{name: name, code: s.codes[i]})
+			continue
+		}
+		// A directory is a package, its files are the ones run.
+		if entries, err := readDir(name); err == nil {
+			for _, e := range entries {
+				if e.IsDir() || !strings.HasSuffix(e.Name(), ".k") || strings.HasSuffix(e.Name(), "_test.k") {
+					continue
+				}
+				data, err := readFile(join(name, e.Name()))
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, file{name: join(name, e.Name()), code: string(data)})
+			}
+			continue
+		}
+		data, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{name: name, code: string(data)})
+	}
+
+	svc := s.service
+	if svc == nil {
+		svc = Service()
+	}
+	var modules []*ast.Module
+	for _, f := range files {
+		resp, err := svc.ParseFile(&gpyrpc.ParseFileArgs{Path: f.name, Source: f.code})
+		if err != nil {
+			return nil, err
+		}
+		m := ast.NewModule()
+		if err := json.Unmarshal([]byte(resp.AstJson), m); err != nil {
+			return nil, fmt.Errorf("kcl: parse %s: %w", f.name, err)
+		}
+		modules = append(modules, m)
+	}
+	return collectOrigins(modules), nil
+}
+
+// collectOrigins returns the assignments of the modules in program order.
+func collectOrigins(modules []*ast.Module) []originEntry {
+	c := &originCollector{schemas: make(map[string]*ast.SchemaStmt)}
+	for _, m := range modules {
+		for _, n := range m.Body {
+			if s, ok := n.Node.(*ast.SchemaStmt); ok && s.Name != nil {
+				c.schemas[s.Name.Node] = s
+			}
+		}
+	}
+	for _, m := range modules {
+		c.stmts(m.Body)
+	}
+	return c.entries
+}
+
+type originCollector struct {
+	schemas   map[string]*ast.SchemaStmt
+	entries   []originEntry
+	inDefault bool
+}
+
+// add records an assignment at path. An override hides the earlier
+// assignments of the value and of the values nested in it.
+func (c *originCollector) add(path []originKey, pos ast.Pos, override bool) {
+	entries := c.entries[:0]
+	for _, e := range c.entries {
+		if hasKeyPrefix(e.path, path) && (override || e.isDefault && len(e.path) == len(path)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	c.entries = append(entries, originEntry{
+		path:      append([]originKey(nil), path...),
+		pos:       pos,
+		isDefault: c.inDefault,
+	})
+}
+
+func (c *originCollector) stmts(body []*ast.Node[ast.Stmt]) {
+	for _, n := range body {
+		switch s := n.Node.(type) {
+		case *ast.AssignStmt:
+			for _, t := range s.Targets {
+				if path, ok := targetKeys(&t.Node); ok {
+					c.add(path, n.Pos, true)
+					c.expr(path, s.Value)
+				}
+			}
+		case *ast.AugAssignStmt:
+			if s.Target != nil {
+				if path, ok := targetKeys(&s.Target.Node); ok {
+					c.add(path, n.Pos, false)
+				}
+			}
+		case *ast.UnificationStmt:
+			if s.Target == nil || s.Value == nil {
+				continue
+			}
+			path := identifierKeys(&s.Target.Node)
+			c.add(path, n.Pos, false)
+			if s.Value.Node.Name != nil {
+				c.schemaDefaults(path, identifierName(&s.Value.Node.Name.Node), 0)
+			}
+			c.expr(path, s.Value.Node.Config)
+		case *ast.IfStmt:
+			c.stmts(s.Body)
+			c.stmts(s.Orelse)
+		}
+	}
+}
+
+func (c *originCollector) expr(path []originKey, n *ast.Node[ast.Expr]) {
+	if n == nil {
+		return
+	}
+	switch e := n.Node.(type) {
+	case *ast.ParenExpr:
+		c.expr(path, e.Expr)
+	case *ast.SchemaExpr:
+		if e.Name != nil {
+			c.schemaDefaults(path, identifierName(&e.Name.Node), 0)
+		}
+		c.expr(path, e.Config)
+	case *ast.ConfigExpr:
+		c.configEntries(path, e.Items)
+	case *ast.ConfigIfEntryExpr:
+		c.configEntries(path, e.Items)
+		c.expr(path, e.Orelse)
+	case *ast.ListExpr:
+		for i, elt := range e.Elts {
+			switch elt.Node.(type) {
+			case *ast.ListIfItemExpr, *ast.StarredExpr:
+				// The indexes of the following items are not known.
+				return
+			}
+			p := append(path[:len(path):len(path)], originKey{index: i, isIndex: true})
+			c.add(p, elt.Pos, false)
+			c.expr(p, elt)
+		}
+	}
+}
+
+func (c *originCollector) configEntries(path []originKey, items []*ast.Node[ast.ConfigEntry]) {
+	for _, item := range items {
+		entry := &item.Node
+		if entry.Key == nil {
+			// An if entry or a `**config` unpacking.
+			if _, ok := entry.Value.Node.(*ast.ConfigIfEntryExpr); ok {
+				c.expr(path, entry.Value)
+			} else {
+				c.add(path, item.Pos, false)
+			}
+			continue
+		}
+		keys, ok := configKeyKeys(entry.Key)
+		if !ok {
+			continue
+		}
+		p := append(path[:len(path):len(path)], keys...)
+		c.add(p, item.Pos, entry.Operation == ast.ConfigEntryOperationOverride)
+		if entry.Operation != ast.ConfigEntryOperationInsert {
+			c.expr(p, entry.Value)
+		}
+	}
+}
+
+// schemaDefaults records the attribute defaults of the schema name, and of
+// its parent schemas, at path.
+func (c *originCollector) schemaDefaults(path []originKey, name string, depth int) {
+	s, ok := c.schemas[name]
+	if !ok || depth > 16 {
+		return
+	}
+	if s.ParentName != nil {
+		c.schemaDefaults(path, identifierName(&s.ParentName.Node), depth+1)
+	}
+	inDefault := c.inDefault
+	c.inDefault = true
+	defer func() { c.inDefault = inDefault }()
+	for _, n := range s.Body {
+		attr, ok := n.Node.(*ast.SchemaAttr)
+		if !ok || attr.Name == nil || attr.Value == nil {
+			continue
+		}
+		p := append(path[:len(path):len(path)], originKey{name: attr.Name.Node})
+		c.add(p, n.Pos, false)
+		c.expr(p, attr.Value)
+	}
+}
+
+func targetKeys(t *ast.Target) ([]originKey, bool) {
+	if t.Name == nil || t.Pkgpath != "" {
+		return nil, false
+	}
+	keys := []originKey{{name: t.Name.Node}}
+	for _, p := range t.Paths {
+		if p == nil {
+			return nil, false
+		}
+		switch p := (*p).(type) {
+		case *ast.Member:
+			keys = append(keys, originKey{name: p.Value.Node})
+		case *ast.Index:
+			key, ok := literalKey(p.Value)
+			if !ok {
+				return keys, true
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, true
+}
+
+func identifierKeys(id *ast.Identifier) []originKey {
+	var keys []originKey
+	for _, name := range id.Names {
+		keys = append(keys, originKey{name: name.Node})
+	}
+	return keys
+}
+
+func identifierName(id *ast.Identifier) string {
+	var names []string
+	for _, name := range id.Names {
+		names = append(names, name.Node)
+	}
+	return strings.Join(names, ".")
+}
+
+func configKeyKeys(key *ast.Node[ast.Expr]) ([]originKey, bool) {
+	switch k := key.Node.(type) {
+	case *ast.IdentifierExpr:
+		return identifierKeys(&k.Identifier), len(k.Names) > 0
+	case *ast.StringLit:
+		return []originKey{{name: k.Value}}, true
+	}
+	return nil, false
+}
+
+func literalKey(n *ast.Node[ast.Expr]) (originKey, bool) {
+	if n == nil {
+		return originKey{}, false
+	}
+	switch v := n.Node.(type) {
+	case *ast.StringLit:
+		return originKey{name: v.Value}, true
+	case *ast.NumberLit:
+		if i, ok := v.Value.(*ast.IntNumberLitValue); ok && i.Value >= 0 {
+			return originKey{index: int(i.Value), isIndex: true}, true
+		}
+	}
+	return originKey{}, false
+}
@@ -0,0 +1,152 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/ast"
+)
+
+func originNode[T any](line int64, v T) *ast.Node[T] {
+	return &ast.Node[T]{Node: v, Pos: ast.Pos{Filename: "main.k", Line: line, Column: 1}}
+}
+
+func originIdent(names ...string) *ast.IdentifierExpr {
+	e := &ast.IdentifierExpr{}
+	for _, name := range names {
+		e.Names = append(e.Names, &ast.Node[string]{Node: name})
+	}
+	return e
+}
+
+func originEntryNode(line int64, key string, op ast.ConfigEntryOperation, value ast.Expr) *ast.Node[ast.ConfigEntry] {
+	return originNode(line, ast.ConfigEntry{
+		Key:       originNode[ast.Expr](line, originIdent(key)),
+		Value:     originNode(line, value),
+		Operation: op,
+	})
+}
+
+func originAssign(line int64, name string, value ast.Expr) *ast.Node[ast.Stmt] {
+	return originNode[ast.Stmt](line, &ast.AssignStmt{
+		Targets: []*ast.Node[ast.Target]{originNode(line, ast.Target{Name: &ast.Node[string]{Node: name}})},
+		Value:   originNode(line, value),
+	})
+}
+
+// testOriginModule is
+//
+//	schema Person:
+//	    name: str = "kcl"
+//	    age: int = 1
+//
+//	x0 = Person {}
+//	x1 = Person {
+//	    age = 101
+//	}
+//	spec = {
+//	    replicas = 1
+//	    containers = [{image = "a"}]
+//	    if True: replicas = 3
+//	}
+func testOriginModule() *ast.Module {
+	str := &ast.StringLit{Value: "kcl"}
+	num := &ast.NumberLit{Value: &ast.IntNumberLitValue{Value: 1}}
+	m := ast.NewModule()
+	m.Body = []*ast.Node[ast.Stmt]{
+		originNode[ast.Stmt](1, &ast.SchemaStmt{
+			Name: &ast.Node[string]{Node: "Person"},
+			Body: []*ast.Node[ast.Stmt]{
+				originNode[ast.Stmt](2, &ast.SchemaAttr{Name: &ast.Node[string]{Node: "name"}, Value: originNode[ast.Expr](2, str)}),
+				originNode[ast.Stmt](3, &ast.SchemaAttr{Name: &ast.Node[string]{Node: "age"}, Value: originNode[ast.Expr](3, num)}),
+			},
+		}),
+		originAssign(5, "x0", &ast.SchemaExpr{
+			Name:   originNode(5, originIdent("Person").Identifier),
+			Config: originNode[ast.Expr](5, &ast.ConfigExpr{}),
+		}),
+		originAssign(6, "x1", &ast.SchemaExpr{
+			Name: originNode(6, originIdent("Person").Identifier),
+			Config: originNode[ast.Expr](6, &ast.ConfigExpr{Items: []*ast.Node[ast.ConfigEntry]{
+				originEntryNode(7, "age", ast.ConfigEntryOperationOverride, num),
+			}}),
+		}),
+		originAssign(9, "spec", &ast.ConfigExpr{Items: []*ast.Node[ast.ConfigEntry]{
+			originEntryNode(10, "replicas", ast.ConfigEntryOperationOverride, num),
+			originEntryNode(11, "containers", ast.ConfigEntryOperationOverride, &ast.ListExpr{Elts: []*ast.Node[ast.Expr]{
+				originNode[ast.Expr](11, &ast.ConfigExpr{Items: []*ast.Node[ast.ConfigEntry]{
+					originEntryNode(11, "image", ast.ConfigEntryOperationOverride, str),
+				}}),
+			}}),
+			originNode(12, ast.ConfigEntry{Value: originNode[ast.Expr](12, &ast.ConfigIfEntryExpr{
+				Items: []*ast.Node[ast.ConfigEntry]{
+					originEntryNode(12, "replicas", ast.ConfigEntryOperationOverride, num),
+				},
+			})}),
+		}}),
+	}
+	return m
+}
+
+func TestOrigin(t *testing.T) {
+	entries := collectOrigins([]*ast.Module{testOriginModule()})
+	lines := func(path string) []int64 {
+		keys, err := originKeysOf(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines []int64
+		for _, pos := range findOrigin(entries, keys) {
+			lines = append(lines, pos.Line)
+		}
+		return lines
+	}
+
+	tests := []struct {
+		path   string
+		expect []int64
+	}{
+		{"x0.name", []int64{2}},
+		{"x1.age", []int64{7}},
+		{"x1", []int64{6, 2, 7}},
+		{"spec.replicas", []int64{12}},
+		{"spec.containers[0].image", []int64{11}},
+		{"spec.containers[0].image.x", []int64{11}},
+		{"nope", nil},
+	}
+	for _, tt := range tests {
+		got := lines(tt.path)
+		tAssert(t, reflect.DeepEqual(got, tt.expect), tt.path, got)
+	}
+
+	_, err := originKeysOf("spec.containers[*]")
+	tAssert(t, err != nil)
+}
+
+func TestExplain(t *testing.T) {
+	source := &resultSource{}
+	source.once.Do(func() {
+		source.entries = collectOrigins([]*ast.Module{testOriginModule()})
+	})
+	r := NewResult(nil)
+	r.document = "spec:\n  replicas: 3\n  containers:\n  - image: a\nother: 1\n"
+	r.source = source
+
+	var buf bytes.Buffer
+	if err := r.Explain(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := `spec.replicas: 3  # main.k:12:1
+spec.containers[0].image: "a"  # main.k:11:1
+other: 1  # unknown
+`
+	tAssert(t, buf.String() == expect, buf.String())
+
+	r = NewResult(map[string]any{})
+	_, err := r.LookupOrigin("a")
+	tAssert(t, err != nil)
+	tAssert(t, r.Origin("a") == nil)
+}
@@ -0,0 +1,853 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrQueryNoMatch is returned by Query and its typed variants when the query
+// matches no value.
+var ErrQueryNoMatch = errors.New("query has no match")
+
+// QueryError is returned when a query expression can not be parsed or when
+// its result has an unexpected type.
+type QueryError struct {
+	Expr   string
+	Offset int
+	Msg    string
+}
+
+func (e *QueryError) Error() string {
+	if e.Offset >= 0 {
+		return fmt.Sprintf("kcl: query %q: offset %d: %s", e.Expr, e.Offset, e.Msg)
+	}
+	return fmt.Sprintf("kcl: query %q: %s", e.Expr, e.Msg)
+}
+
+// Query evaluates the JSONPath-style expression expr against the result.
+// An expression without wildcards, slices, filters or recursive descent
+// selects at most one value, which is returned as is. Other expressions
+// return a []any of all the matches. ErrQueryNoMatch is returned when
+// nothing matches.
+//
+// The grammar is:
+//
+//	query     = [ "$" ] [ name ] { segment }
+//	segment   = "." name | "." "*" | ".." name | ".." "*" | "[" selector "]"
+//	selector  = index | "*" | string | [ index ] ":" [ index ] | "?" filter
+//	filter    = "(" expr ")" | expr
+//	expr      = and { "||" and }
+//	and       = unary { "&&" unary }
+//	unary     = "!" unary | "(" expr ")" | operand [ op operand ]
+//	operand   = "@" { segment } | string | number | "true" | "false" | "null"
+//	op        = "==" | "!=" | "<" | "<=" | ">" | ">=" | "=~"
+//
+// A name is any run of characters except '.', '[' and ']'; keys containing
+// those characters use the quoted form, e.g. ["app.kubernetes.io/name"].
+// Strings are single or double quoted. Negative indexes count from the end of
+// a list. A filter selects the list elements (or map values) for which the
+// expression holds; an operand `@path` alone tests that the path exists, and
+// `=~` matches a string against a regular expression. For example:
+//
+//	spec.containers[0].image
+//	items[*].metadata.name
+//	metadata.labels["app.kubernetes.io/name"]
+//	spec.containers[?(@.name == "app" && @.ports)].image
+//	..image
+//
+// Map keys are visited in sorted order by wildcards and recursive descent.
+func (m *KCLResult) Query(expr string) (any, error) {
+	q, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	values := q.eval(m.result)
+	if len(values) == 0 {
+		return nil, fmt.Errorf("kcl: query %q: %w", expr, ErrQueryNoMatch)
+	}
+	if q.single {
+		return values[0], nil
+	}
+	return values, nil
+}
+
+// QueryAll is like Query but always returns the list of matches, which is
+// empty when nothing matches.
+func (m *KCLResult) QueryAll(expr string) ([]any, error) {
+	q, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.eval(m.result), nil
+}
+
+// QueryString returns the first value matched by expr, which must be a string.
+func (m *KCLResult) QueryString(expr string) (string, error) {
+	return queryAs(m.QueryAll, expr, queryString)
+}
+
+// QueryInt returns the first value matched by expr, which must be an integer.
+func (m *KCLResult) QueryInt(expr string) (int, error) {
+	return queryAs(m.QueryAll, expr, queryInt)
+}
+
+// QueryFloat64 returns the first value matched by expr, which must be a number.
+func (m *KCLResult) QueryFloat64(expr string) (float64, error) {
+	return queryAs(m.QueryAll, expr, queryFloat64)
+}
+
+// QueryBool returns the first value matched by expr, which must be a bool.
+func (m *KCLResult) QueryBool(expr string) (bool, error) {
+	return queryAs(m.QueryAll, expr, queryBool)
+}
+
+// Query evaluates expr against each result of the list in order and returns
+// the first match, see KCLResult.Query. For expressions returning all
+// matches, the matches of all the results are returned.
+func (p *KCLResultList) Query(expr string) (any, error) {
+	q, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	values := p.queryAll(q)
+	if len(values) == 0 {
+		return nil, fmt.Errorf("kcl: query %q: %w", expr, ErrQueryNoMatch)
+	}
+	if q.single {
+		return values[0], nil
+	}
+	return values, nil
+}
+
+// QueryAll returns the matches of expr in all the results of the list.
+func (p *KCLResultList) QueryAll(expr string) ([]any, error) {
+	q, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.queryAll(q), nil
+}
+
+// QueryString returns the first value matched by expr in the results of the
+// list, which must be a string.
+func (p *KCLResultList) QueryString(expr string) (string, error) {
+	return queryAs(p.QueryAll, expr, queryString)
+}
+
+// QueryInt returns the first value matched by expr in the results of the
+// list, which must be an integer.
+func (p *KCLResultList) QueryInt(expr string) (int, error) {
+	return queryAs(p.QueryAll, expr, queryInt)
+}
+
+// QueryFloat64 returns the first value matched by expr in the results of the
+// list, which must be a number.
+func (p *KCLResultList) QueryFloat64(expr string) (float64, error) {
+	return queryAs(p.QueryAll, expr, queryFloat64)
+}
+
+// QueryBool returns the first value matched by expr in the results of the
+// list, which must be a bool.
+func (p *KCLResultList) QueryBool(expr string) (bool, error) {
+	return queryAs(p.QueryAll, expr, queryBool)
+}
+
+func (p *KCLResultList) queryAll(q *query) []any {
+	values := []any{}
+	for _, r := range p.list {
+		values = append(values, q.eval(r.result)...)
+	}
+	return values
+}
+
+// queryAs converts the first match of expr with conv.
+func queryAs[T any](queryAll func(string) ([]any, error), expr string, conv func(any) (T, bool)) (T, error) {
+	var zero T
+	values, err := queryAll(expr)
+	if err != nil {
+		return zero, err
+	}
+	if len(values) == 0 {
+		return zero, fmt.Errorf("kcl: query %q: %w", expr, ErrQueryNoMatch)
+	}
+	v, ok := conv(values[0])
+	if !ok {
+		return zero, &QueryError{Expr: expr, Offset: -1, Msg: fmt.Sprintf("expect %T, got %T", zero, values[0])}
+	}
+	return v, nil
+}
+
+func queryString(v any) (string, bool) {
+	s, ok := v.(string)
+	return s, ok
+}
+
+func queryInt(v any) (int, bool) {
+	if i, ok := queryInteger(v); ok {
+		return int(i), int64(int(i)) == i
+	}
+	f, ok := queryNumber(v)
+	// Integral floats like 1.0 up to the int64 range.
+	if !ok || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func queryFloat64(v any) (float64, bool) {
+	return queryNumber(v)
+}
+
+func queryBool(v any) (bool, bool) {
+	b, ok := v.(bool)
+	return b, ok
+}
+
+// queryInteger returns the integer v exactly, the numbers decoded with a
+// NumberMode are json.Number or int64 values beyond the float64 precision.
+func queryInteger(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func queryNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+type queryStepKind int
+
+const (
+	stepField queryStepKind = iota
+	stepIndex
+	stepWildcard
+	stepSlice
+	stepFilter
+	stepRecursive
+)
+
+type queryStep struct {
+	kind       queryStepKind
+	name       string
+	index      int
+	start, end *int
+	filter     queryExpr
+}
+
+type query struct {
+	steps []queryStep
+	// single reports that the query selects at most one value.
+	single bool
+}
+
+func (q *query) eval(root any) []any {
+	return evalSteps([]any{root}, q.steps)
+}
+
+func evalSteps(values []any, steps []queryStep) []any {
+	for _, step := range steps {
+		var next []any
+		for _, v := range values {
+			next = append(next, step.apply(v)...)
+		}
+		values = next
+		if len(values) == 0 {
+			break
+		}
+	}
+	if values == nil {
+		values = []any{}
+	}
+	return values
+}
+
+func (s *queryStep) apply(v any) []any {
+	switch s.kind {
+	case stepField:
+		if m, ok := v.(map[string]any); ok {
+			if x, ok := m[s.name]; ok {
+				return []any{x}
+			}
+		}
+	case stepIndex:
+		if list, ok := v.([]any); ok {
+			i := s.index
+			if i < 0 {
+				i += len(list)
+			}
+			if i >= 0 && i < len(list) {
+				return []any{list[i]}
+			}
+		}
+	case stepWildcard:
+		return queryChildren(v)
+	case stepSlice:
+		if list, ok := v.([]any); ok {
+			start, end := 0, len(list)
+			if s.start != nil {
+				start = clampIndex(*s.start, len(list))
+			}
+			if s.end != nil {
+				end = clampIndex(*s.end, len(list))
+			}
+			if start < end {
+				return list[start:end]
+			}
+		}
+	case stepFilter:
+		var out []any
+		for _, x := range queryChildren(v) {
+			if truthy(s.filter.eval(x)) {
+				out = append(out, x)
+			}
+		}
+		return out
+	case stepRecursive:
+		var out []any
+		var walk func(x any)
+		walk = func(x any) {
+			out = append(out, x)
+			for _, c := range queryChildren(x) {
+				walk(c)
+			}
+		}
+		walk(v)
+		return out
+	}
+	return nil
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return max(0, min(i, n))
+}
+
+// queryChildren returns the list elements or map values of v, map values in
+// key order.
+func queryChildren(v any) []any {
+	switch v := v.(type) {
+	case []any:
+		return v
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]any, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, v[k])
+		}
+		return out
+	}
+	return nil
+}
+
+// Filter expressions.
+
+type queryExpr interface {
+	eval(current any) any
+}
+
+type (
+	queryLiteral struct{ value any }
+	queryPath    struct{ steps []queryStep }
+	queryNot     struct{ x queryExpr }
+	queryBinary  struct {
+		op   string
+		x, y queryExpr
+	}
+)
+
+// queryMissing is the value of a path operand which does not exist.
+type queryMissing struct{}
+
+func (e *queryLiteral) eval(any) any { return e.value }
+
+func (e *queryPath) eval(current any) any {
+	values := evalSteps([]any{current}, e.steps)
+	if len(values) == 0 {
+		return queryMissing{}
+	}
+	return values[0]
+}
+
+func (e *queryNot) eval(current any) any { return !truthy(e.x.eval(current)) }
+
+func (e *queryBinary) eval(current any) any {
+	switch e.op {
+	case "&&":
+		return truthy(e.x.eval(current)) && truthy(e.y.eval(current))
+	case "||":
+		return truthy(e.x.eval(current)) || truthy(e.y.eval(current))
+	}
+	x, y := e.x.eval(current), e.y.eval(current)
+	if _, ok := x.(queryMissing); ok {
+		return false
+	}
+	if _, ok := y.(queryMissing); ok {
+		return false
+	}
+	switch e.op {
+	case "==":
+		return queryEqual(x, y)
+	case "!=":
+		return !queryEqual(x, y)
+	case "=~":
+		s, ok1 := x.(string)
+		pattern, ok2 := y.(string)
+		if !ok1 || !ok2 {
+			return false
+		}
+		matched, _ := regexp.MatchString(pattern, s)
+		return matched
+	}
+	c, ok := queryCompare(x, y)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case queryMissing:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+func queryEqual(x, y any) bool {
+	if c, ok := queryCompareIntegers(x, y); ok {
+		return c == 0
+	}
+	if fx, ok := queryNumber(x); ok {
+		fy, ok := queryNumber(y)
+		return ok && fx == fy
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func queryCompare(x, y any) (int, bool) {
+	if c, ok := queryCompareIntegers(x, y); ok {
+		return c, true
+	}
+	if fx, ok := queryNumber(x); ok {
+		fy, ok := queryNumber(y)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case fx < fy:
+			return -1, true
+		case fx > fy:
+			return 1, true
+		}
+		return 0, true
+	}
+	sx, ok1 := x.(string)
+	sy, ok2 := y.(string)
+	if ok1 && ok2 {
+		return strings.Compare(sx, sy), true
+	}
+	return 0, false
+}
+
+// queryCompareIntegers compares x and y exactly if both are integers.
+func queryCompareIntegers(x, y any) (int, bool) {
+	ix, ok := queryInteger(x)
+	if !ok {
+		return 0, false
+	}
+	iy, ok := queryInteger(y)
+	if !ok {
+		return 0, false
+	}
+	return cmp.Compare(ix, iy), true
+}
+
+// Parser.
+
+type queryParser struct {
+	expr string
+	pos  int
+}
+
+func parseQuery(expr string) (*query, error) {
+	p := &queryParser{expr: strings.TrimSpace(expr)}
+	if p.expr == "" {
+		return nil, &QueryError{Expr: expr, Offset: -1, Msg: "empty expression"}
+	}
+	q := &query{single: true}
+	if p.peek() == '$' {
+		p.pos++
+	}
+	// A query may start with a bare name, e.g. `spec.replicas`.
+	if !p.eof() && p.peek() != '.' && p.peek() != '[' {
+		name := p.name(false)
+		if name == "*" {
+			q.steps = append(q.steps, queryStep{kind: stepWildcard})
+		} else {
+			q.steps = append(q.steps, queryStep{kind: stepField, name: name})
+		}
+	}
+	steps, err := p.segments(false)
+	if err != nil {
+		return nil, err
+	}
+	q.steps = append(q.steps, steps...)
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+	}
+	for _, s := range q.steps {
+		if s.kind != stepField && s.kind != stepIndex {
+			q.single = false
+		}
+	}
+	return q, nil
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return &QueryError{Expr: p.expr, Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) eof() bool { return p.pos >= len(p.expr) }
+
+func (p *queryParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.expr[p.pos]
+}
+
+func (p *queryParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// name reads a field name. In filters, names also end at spaces and
+// operator characters.
+func (p *queryParser) name(inFilter bool) string {
+	stop := ".[]"
+	if inFilter {
+		stop += " \t()=!<>&|~,"
+	}
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(stop, rune(p.peek())) {
+		p.pos++
+	}
+	return p.expr[start:p.pos]
+}
+
+func (p *queryParser) segments(inFilter bool) ([]queryStep, error) {
+	var steps []queryStep
+	for !p.eof() {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			if p.peek() == '.' {
+				p.pos++
+				steps = append(steps, queryStep{kind: stepRecursive})
+				if p.peek() == '[' {
+					continue
+				}
+			}
+			name := p.name(inFilter)
+			switch name {
+			case "":
+				return nil, p.errorf("expect a name")
+			case "*":
+				steps = append(steps, queryStep{kind: stepWildcard})
+			default:
+				steps = append(steps, queryStep{kind: stepField, name: name})
+			}
+		case '[':
+			p.pos++
+			step, err := p.selector()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		default:
+			if inFilter {
+				return steps, nil
+			}
+			return nil, p.errorf("expect '.' or '['")
+		}
+	}
+	return steps, nil
+}
+
+func (p *queryParser) selector() (queryStep, error) {
+	p.skipSpaces()
+	var step queryStep
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		step = queryStep{kind: stepWildcard}
+	case c == '"' || c == '\'':
+		s, err := p.quoted()
+		if err != nil {
+			return step, err
+		}
+		step = queryStep{kind: stepField, name: s}
+	case c == '?':
+		p.pos++
+		x, err := p.orExpr()
+		if err != nil {
+			return step, err
+		}
+		step = queryStep{kind: stepFilter, filter: x}
+	default:
+		start, hasStart, err := p.optionalInt()
+		if err != nil {
+			return step, err
+		}
+		p.skipSpaces()
+		if p.peek() != ':' {
+			if !hasStart {
+				return step, p.errorf("expect an index, '*', a string, a slice or a filter")
+			}
+			step = queryStep{kind: stepIndex, index: start}
+			break
+		}
+		p.pos++
+		end, hasEnd, err := p.optionalInt()
+		if err != nil {
+			return step, err
+		}
+		step = queryStep{kind: stepSlice}
+		if hasStart {
+			step.start = &start
+		}
+		if hasEnd {
+			step.end = &end
+		}
+	}
+	p.skipSpaces()
+	if p.peek() != ']' {
+		return step, p.errorf("expect ']'")
+	}
+	p.pos++
+	return step, nil
+}
+
+func (p *queryParser) optionalInt() (int, bool, error) {
+	p.skipSpaces()
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for !p.eof() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(p.expr[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false, p.errorf("invalid index %q", p.expr[start:p.pos])
+	}
+	return n, true, nil
+}
+
+func (p *queryParser) quoted() (string, error) {
+	quote := p.peek()
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && !p.eof():
+			b.WriteByte(p.peek())
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *queryParser) orExpr() (queryExpr, error) {
+	x, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !strings.HasPrefix(p.expr[p.pos:], "||") {
+			return x, nil
+		}
+		p.pos += 2
+		y, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		x = &queryBinary{op: "||", x: x, y: y}
+	}
+}
+
+func (p *queryParser) andExpr() (queryExpr, error) {
+	x, err := p.unaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !strings.HasPrefix(p.expr[p.pos:], "&&") {
+			return x, nil
+		}
+		p.pos += 2
+		y, err := p.unaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		x = &queryBinary{op: "&&", x: x, y: y}
+	}
+}
+
+func (p *queryParser) unaryExpr() (queryExpr, error) {
+	p.skipSpaces()
+	switch {
+	case p.peek() == '!' && !strings.HasPrefix(p.expr[p.pos:], "!="):
+		p.pos++
+		x, err := p.unaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{x: x}, nil
+	case p.peek() == '(':
+		p.pos++
+		x, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.peek() != ')' {
+			return nil, p.errorf("expect ')'")
+		}
+		p.pos++
+		return x, nil
+	}
+
+	x, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if strings.HasPrefix(p.expr[p.pos:], op) {
+			p.pos += len(op)
+			y, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			if op == "=~" {
+				if lit, ok := y.(*queryLiteral); ok {
+					if s, ok := lit.value.(string); ok {
+						if _, err := regexp.Compile(s); err != nil {
+							return nil, p.errorf("invalid regular expression: %v", err)
+						}
+					}
+				}
+			}
+			return &queryBinary{op: op, x: x, y: y}, nil
+		}
+	}
+	return x, nil
+}
+
+func (p *queryParser) operand() (queryExpr, error) {
+	p.skipSpaces()
+	switch c := p.peek(); {
+	case c == '@':
+		p.pos++
+		steps, err := p.segments(true)
+		if err != nil {
+			return nil, err
+		}
+		return &queryPath{steps: steps}, nil
+	case c == '"' || c == '\'':
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return &queryLiteral{value: s}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for !p.eof() && strings.ContainsRune("0123456789.eE+-", rune(p.peek())) {
+			p.pos++
+		}
+		lit := p.expr[start:p.pos]
+		if _, err := strconv.ParseFloat(lit, 64); err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number")
+		}
+		// Integer literals are compared exactly, see queryCompareIntegers.
+		return &queryLiteral{value: json.Number(lit)}, nil
+	}
+	for _, kw := range []struct {
+		s string
+		v any
+	}{{"true", true}, {"false", false}, {"null", nil}} {
+		if strings.HasPrefix(p.expr[p.pos:], kw.s) {
+			p.pos += len(kw.s)
+			return &queryLiteral{value: kw.v}, nil
+		}
+	}
+	return nil, p.errorf("expect an operand")
+}
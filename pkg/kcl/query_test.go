@@ -0,0 +1,156 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const tQueryManifest = `
+apiVersion: v1
+kind: List
+metadata:
+  labels:
+    app.kubernetes.io/name: web
+items:
+  - metadata:
+      name: web
+    spec:
+      replicas: 3
+      paused: false
+      containers:
+        - name: app
+          image: app:v1
+          ports: [80]
+        - name: sidecar
+          image: proxy:v2
+  - metadata:
+      name: db
+    spec:
+      replicas: 1
+      containers:
+        - name: db
+          image: postgres:16
+`
+
+func tQueryResult(t *testing.T, s string) *KCLResult {
+	var v any
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	r := NewResult(v)
+	return &r
+}
+
+func TestQuery(t *testing.T) {
+	r := tQueryResult(t, tQueryManifest)
+	for _, tt := range []struct {
+		expr   string
+		expect any
+	}{
+		{"kind", "List"},
+		{"$.kind", "List"},
+		{"items[0].spec.containers[0].image", "app:v1"},
+		{"items[-1].metadata.name", "db"},
+		{`metadata.labels["app.kubernetes.io/name"]`, "web"},
+		{`metadata['labels']['app.kubernetes.io/name']`, "web"},
+		{"items[*].metadata.name", []any{"web", "db"}},
+		{"items[0:1].metadata.name", []any{"web"}},
+		{"items[1:].metadata.name", []any{"db"}},
+		{"items[*].spec.containers[*].name", []any{"app", "sidecar", "db"}},
+		{"..image", []any{"app:v1", "proxy:v2", "postgres:16"}},
+		{"items[?(@.spec.replicas > 1)].metadata.name", []any{"web"}},
+		{"items[?@.spec.replicas == 1].metadata.name", []any{"db"}},
+		{`items[*].spec.containers[?(@.name == "app" && @.ports)].image`, []any{"app:v1"}},
+		{`items[*].spec.containers[?(@.name != "app" || @.ports)].name`, []any{"app", "sidecar", "db"}},
+		{`items[*].spec.containers[?(!@.ports)].name`, []any{"sidecar", "db"}},
+		{`items[*].spec.containers[?(@.image =~ "^p")].name`, []any{"sidecar", "db"}},
+		{`items[?(@.spec.paused == false)].metadata.name`, []any{"web"}},
+	} {
+		got, err := r.Query(tt.expr)
+		tAssert(t, err == nil, tt.expr, err)
+		tAssert(t, reflect.DeepEqual(got, tt.expect), tt.expr, got)
+	}
+
+	_, err := r.Query("items[5].metadata.name")
+	tAssert(t, errors.Is(err, ErrQueryNoMatch), err)
+	values, err := r.QueryAll("items[*].missing")
+	tAssert(t, err == nil && len(values) == 0, values, err)
+
+	for _, expr := range []string{"", "items[", "items[0", "items[?(@.a ==)]", `items["a]`, "items.", "items[?(@.a =~ \"(\")]"} {
+		_, err := r.Query(expr)
+		var qerr *QueryError
+		tAssert(t, errors.As(err, &qerr), expr, err)
+	}
+}
+
+func TestQueryTyped(t *testing.T) {
+	r := tQueryResult(t, tQueryManifest)
+
+	s, err := r.QueryString("items[0].spec.containers[1].image")
+	tAssert(t, err == nil && s == "proxy:v2", s, err)
+	n, err := r.QueryInt("items[0].spec.replicas")
+	tAssert(t, err == nil && n == 3, n, err)
+	f, err := r.QueryFloat64("items[1].spec.replicas")
+	tAssert(t, err == nil && f == 1, f, err)
+	b, err := r.QueryBool("items[0].spec.paused")
+	tAssert(t, err == nil && !b, b, err)
+	s, err = r.QueryString("items[*].metadata.name")
+	tAssert(t, err == nil && s == "web", s, err)
+
+	_, err = r.QueryString("items[0].spec.replicas")
+	var qerr *QueryError
+	tAssert(t, errors.As(err, &qerr), err)
+	_, err = r.QueryInt("items[0].missing")
+	tAssert(t, errors.Is(err, ErrQueryNoMatch), err)
+}
+
+func TestQueryLargeIntegers(t *testing.T) {
+	r := NewResult(map[string]any{
+		"ids": []any{
+			json.Number("9007199254740993"),
+			int64(9007199254740995),
+			uint64(math.MaxInt64),
+			json.Number("2.5"),
+		},
+	})
+
+	n, err := r.QueryInt("ids[0]")
+	tAssert(t, err == nil && n == 9007199254740993, n, err)
+	n, err = r.QueryInt("ids[1]")
+	tAssert(t, err == nil && n == 9007199254740995, n, err)
+	n, err = r.QueryInt("ids[2]")
+	tAssert(t, err == nil && n == math.MaxInt64, n, err)
+	_, err = r.QueryInt("ids[3]")
+	var qerr *QueryError
+	tAssert(t, errors.As(err, &qerr), err)
+
+	// The integers differing beyond the float64 precision are told apart.
+	v, err := r.QueryAll("ids[?(@ == 9007199254740993)]")
+	tAssert(t, err == nil && len(v) == 1 && v[0] == json.Number("9007199254740993"), v, err)
+	v, err = r.QueryAll("ids[?(@ > 9007199254740994)]")
+	tAssert(t, err == nil && len(v) == 2, v, err)
+}
+
+func TestResultListQuery(t *testing.T) {
+	list := &KCLResultList{list: []KCLResult{
+		*tQueryResult(t, "kind: Deployment\nmetadata: {name: web}\n"),
+		*tQueryResult(t, "kind: Service\nmetadata: {name: web-svc}\n"),
+	}}
+	v, err := list.Query("kind")
+	tAssert(t, err == nil && v == "Deployment", v, err)
+	v, err = list.Query("metadata.name")
+	tAssert(t, err == nil && v == "web", v, err)
+	values, err := list.QueryAll("metadata.name")
+	tAssert(t, err == nil && reflect.DeepEqual(values, []any{"web", "web-svc"}), values, err)
+	s, err := list.QueryString(`$[?(@ == "Service")]`)
+	tAssert(t, err == nil && s == "Service", s, err)
+	s, err = list.QueryString(`$.*[?(@ == "web-svc")]`)
+	tAssert(t, err == nil && s == "web-svc", s, err)
+}
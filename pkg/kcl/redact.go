@@ -0,0 +1,422 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+// RedactedValue replaces the redacted values and text.
+const RedactedValue = "<redacted>"
+
+// RedactOptions marks the secrets of a run, see WithRedaction.
+type RedactOptions struct {
+	// Paths are query expressions, see KCLResult.Query, of the output
+	// values to redact, e.g. "db.password" or "..token".
+	Paths []string
+	// OptionNames are the names of the options, e.g. `-D token=...`, whose
+	// values are redacted wherever they appear.
+	OptionNames []string
+	// Patterns are regular expressions whose matches are redacted.
+	Patterns []string
+}
+
+func (o *RedactOptions) merge(x *RedactOptions) *RedactOptions {
+	if o == nil {
+		return x
+	}
+	return &RedactOptions{
+		Paths:       append(slices.Clip(o.Paths), x.Paths...),
+		OptionNames: append(slices.Clip(o.OptionNames), x.OptionNames...),
+		Patterns:    append(slices.Clip(o.Patterns), x.Patterns...),
+	}
+}
+
+// WithRedaction returns a Option which redacts the secrets marked by opts
+// in the result of the run: the values at opts.Paths are replaced with
+// RedactedValue, and so are the values of the options named by
+// opts.OptionNames and the matches of opts.Patterns in the output strings,
+// the LogMessage written to the logger and the error text.
+//
+// All the methods of the result, e.g. JSONString, ToMap and Encode, see the
+// redacted values. The raw values are only returned by
+// KCLResultList.Unredacted, KCLResult.Unredacted and Error.Unredacted.
+func WithRedaction(opts RedactOptions) Option {
+	if _, err := newRedactor(&opts, nil); err != nil {
+		return Option{Err: fmt.Errorf("kcl.WithRedaction(...): %v", err)}
+	}
+	var opt = NewOption()
+	opt.redaction = &RedactOptions{
+		Paths:       slices.Clone(opts.Paths),
+		OptionNames: slices.Clone(opts.OptionNames),
+		Patterns:    slices.Clone(opts.Patterns),
+	}
+	return *opt
+}
+
+// GetRedaction returns the redaction set by WithRedaction, nil means no
+// redaction.
+func (p *Option) GetRedaction() *RedactOptions {
+	return p.redaction
+}
+
+// RedactExecResult returns a copy of resp with the secrets marked by opts
+// redacted, as WithRedaction does. args are the arguments of the run, the
+// values of the options named by opts.OptionNames are taken from them.
+func RedactExecResult(opts RedactOptions, args *gpyrpc.ExecProgramArgs, resp *gpyrpc.ExecProgramResult) (*gpyrpc.ExecProgramResult, error) {
+	r, err := newRedactor(&opts, args)
+	if err != nil {
+		return nil, err
+	}
+	return r.execResult(resp), nil
+}
+
+// RedactError returns err with the secrets marked by opts redacted from its
+// text, see RedactExecResult.
+func RedactError(opts RedactOptions, args *gpyrpc.ExecProgramArgs, err error) error {
+	r, rerr := newRedactor(&opts, args)
+	if rerr != nil {
+		return rerr
+	}
+	return r.error(err)
+}
+
+// Unredacted returns the result list without redaction, see WithRedaction.
+func (p *KCLResultList) Unredacted() *KCLResultList {
+	if p.unredacted != nil {
+		return p.unredacted
+	}
+	return p
+}
+
+// Unredacted returns the result without redaction, see WithRedaction.
+func (m *KCLResult) Unredacted() *KCLResult {
+	if m.unredacted != nil {
+		return m.unredacted
+	}
+	return m
+}
+
+// Unredacted returns the error without redaction, see WithRedaction.
+func (e *Error) Unredacted() *Error {
+	if e.unredacted != nil {
+		return e.unredacted
+	}
+	return e
+}
+
+// redactedError is an error other than *Error whose text is redacted.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+// Unredacted returns the error without redaction.
+func (e *redactedError) Unredacted() error { return e.err }
+
+type redactor struct {
+	paths    []*query
+	secrets  []string
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(opts *RedactOptions, args *gpyrpc.ExecProgramArgs) (*redactor, error) {
+	r := &redactor{}
+	for _, path := range opts.Paths {
+		q, err := parseQuery(path)
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %v", path, err)
+		}
+		if len(q.steps) == 0 {
+			return nil, fmt.Errorf("path %q: cannot redact the root", path)
+		}
+		r.paths = append(r.paths, q)
+	}
+	for _, pattern := range opts.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %v", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	if args != nil {
+		for _, arg := range args.Args {
+			if !slices.Contains(opts.OptionNames, arg.Name) {
+				continue
+			}
+			r.secrets = append(r.secrets, arg.Value)
+			// Settings files pass the options as JSON.
+			var s string
+			if json.Unmarshal([]byte(arg.Value), &s) == nil {
+				r.secrets = append(r.secrets, s)
+			}
+		}
+	}
+	// Replace the longest secrets first, they may contain shorter ones.
+	r.secrets = slices.DeleteFunc(r.secrets, func(s string) bool { return s == "" })
+	sort.SliceStable(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	r.secrets = slices.Compact(r.secrets)
+	return r, nil
+}
+
+// text redacts the option values and the pattern matches in s.
+func (r *redactor) text(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, RedactedValue)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, RedactedValue)
+	}
+	return s
+}
+
+func (r *redactor) error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		redacted := NewError(r.text(e.message))
+		redacted.unredacted = e
+		return redacted
+	}
+	if msg := r.text(err.Error()); msg != err.Error() {
+		return &redactedError{msg: msg, err: err}
+	}
+	return err
+}
+
+// execResult returns a redacted copy of resp. The documents are rewritten
+// only when something is redacted, so the result keeps its formatting
+// otherwise.
+func (r *redactor) execResult(resp *gpyrpc.ExecProgramResult) *gpyrpc.ExecProgramResult {
+	out := &gpyrpc.ExecProgramResult{
+		JsonResult: resp.JsonResult,
+		YamlResult: resp.YamlResult,
+		LogMessage: r.text(resp.LogMessage),
+		ErrMessage: r.text(resp.ErrMessage),
+	}
+	if strings.TrimSpace(resp.YamlResult) == "" {
+		if node, changed := r.document(resp.JsonResult); changed {
+			out.JsonResult = redactedJSON(node)
+		}
+		return out
+	}
+	docs, err := SplitDocuments(resp.YamlResult)
+	if err != nil {
+		out.JsonResult = r.text(resp.JsonResult)
+		out.YamlResult = r.text(resp.YamlResult)
+		return out
+	}
+	var nodes []*yaml.Node
+	anyChanged := false
+	for i, doc := range docs {
+		node, changed := r.document(doc)
+		if changed {
+			var buf bytes.Buffer
+			enc := yaml.NewEncoder(&buf)
+			enc.SetIndent(2)
+			if enc.Encode(node) != nil || enc.Close() != nil {
+				// Never leak a value which can not be encoded.
+				node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: RedactedValue}
+				docs[i] = RedactedValue + "\n"
+			} else {
+				docs[i] = buf.String()
+			}
+			anyChanged = true
+		} else if !strings.HasSuffix(doc, "\n") {
+			docs[i] = doc + "\n"
+		}
+		if node == nil {
+			node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: RedactedValue}
+		}
+		nodes = append(nodes, node)
+	}
+	if !anyChanged {
+		return out
+	}
+	out.YamlResult = strings.Join(docs, "---\n")
+	if len(nodes) == 1 {
+		out.JsonResult = redactedJSON(nodes[0])
+	} else {
+		out.JsonResult = redactedJSON(&yaml.Node{Kind: yaml.SequenceNode, Content: nodes})
+	}
+	return out
+}
+
+// redactedJSON encodes the redacted YAML node as JSON in the key order of
+// the node.
+func redactedJSON(node *yaml.Node) string {
+	v, err := nodeValueMode(node, NumberModeJSONNumber)
+	if err != nil {
+		return `"` + RedactedValue + `"`
+	}
+	var buf bytes.Buffer
+	writeJSON(&buf, v, "    ", "")
+	return buf.String()
+}
+
+// document redacts a YAML or JSON document. It returns the root node of the
+// redacted document and whether anything is redacted.
+func (r *redactor) document(doc string) (*yaml.Node, bool) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(doc), &root); err != nil || len(root.Content) == 0 {
+		return nil, false
+	}
+	node := root.Content[0]
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return nil, false
+	}
+	for _, q := range r.paths {
+		parents := evalSteps([]any{v}, q.steps[:len(q.steps)-1])
+		for _, parent := range parents {
+			redactChildren(parent, &q.steps[len(q.steps)-1])
+		}
+	}
+	v = r.value(v)
+	return node, redactNode(node, v)
+}
+
+// value redacts the text of the strings in v in place, and the numbers and
+// bools equal to an option value, e.g. the 123456 of `-D pin=123456`.
+func (r *redactor) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		if v == RedactedValue {
+			return v
+		}
+		return r.text(v)
+	case int, int64, uint64, float64, bool:
+		if slices.Contains(r.secrets, scalarString(v)) {
+			return RedactedValue
+		}
+	case map[string]any:
+		for k, x := range v {
+			v[k] = r.value(x)
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = r.value(x)
+		}
+	}
+	return v
+}
+
+// scalarString returns the option value form of the number or bool v.
+func scalarString(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// redactChildren replaces the children of parent selected by step.
+func redactChildren(parent any, step *queryStep) {
+	switch parent := parent.(type) {
+	case map[string]any:
+		for k, x := range parent {
+			if step.kind == stepField && k == step.name ||
+				step.kind == stepWildcard ||
+				step.kind == stepFilter && truthy(step.filter.eval(x)) {
+				parent[k] = RedactedValue
+			}
+		}
+	case []any:
+		for i, x := range parent {
+			selected := false
+			switch step.kind {
+			case stepIndex:
+				selected = i == step.index || i == step.index+len(parent)
+			case stepWildcard:
+				selected = true
+			case stepSlice:
+				start, end := 0, len(parent)
+				if step.start != nil {
+					start = clampIndex(*step.start, len(parent))
+				}
+				if step.end != nil {
+					end = clampIndex(*step.end, len(parent))
+				}
+				selected = i >= start && i < end
+			case stepFilter:
+				selected = truthy(step.filter.eval(x))
+			}
+			if selected {
+				parent[i] = RedactedValue
+			}
+		}
+	}
+}
+
+// redactNode updates node to the redacted value v decoded from it. It
+// reports whether node is changed.
+func redactNode(node *yaml.Node, v any) bool {
+	changed := false
+	switch node.Kind {
+	case yaml.MappingNode:
+		if m, ok := v.(map[string]any); ok {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if redactNode(node.Content[i+1], m[node.Content[i].Value]) {
+					changed = true
+				}
+			}
+			return changed
+		}
+	case yaml.SequenceNode:
+		if list, ok := v.([]any); ok && len(list) == len(node.Content) {
+			for i, n := range node.Content {
+				if redactNode(n, list[i]) {
+					changed = true
+				}
+			}
+			return changed
+		}
+	case yaml.ScalarNode:
+		if s, ok := v.(string); !ok || node.ShortTag() == "!!str" && node.Value == s {
+			return false
+		}
+	}
+	// Only a redacted value replaces a whole mapping or sequence.
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+	return true
+}
+
+// redactRun converts resp into the redacted result of the run, linked to
+// the unredacted one. The hooks see the unredacted result.
+func (r *redactor) redactRun(o *Option, resp *gpyrpc.ExecProgramResult, hooks Hooks) (*KCLResultList, error) {
+	raw, err := ExecResultToKCLResult(o, resp, nil, hooks)
+	if _, ok := err.(*Error); err != nil && !ok {
+		return nil, r.error(err)
+	}
+	result, rerr := ExecResultToKCLResult(o, r.execResult(resp), o.GetLogger(), nil)
+	if rerr != nil {
+		if kerr, ok := rerr.(*Error); ok {
+			kerr.unredacted, _ = err.(*Error)
+		}
+		return nil, rerr
+	}
+	if raw != nil && len(raw.list) == len(result.list) {
+		result.unredacted = raw
+		for i := range result.list {
+			result.list[i].unredacted = &raw.list[i]
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RunSettings runs the program given by the settings files of WithSettings,
+// like `kcl -Y settings.yaml`, and writes the result to the output path of
+// the settings, `kcl_cli_configs.output`, in the format implied by its
+// extension, see EncodeFormat. Without an output path, nothing is written
+// and the result is only returned.
+func RunSettings(opts ...Option) (*KCLResultList, error) {
+	return RunSettingsContext(context.Background(), opts...)
+}
+
+// RunSettingsContext is like RunSettings but returns a *CancelError when ctx
+// is cancelled or its deadline passes before the KCL program finishes.
+func RunSettingsContext(ctx context.Context, opts ...Option) (*KCLResultList, error) {
+	args, err := ParseArgs(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	output := args.settingsOutput
+	if output == "" {
+		return runArgs(ctx, args, DefaultHooks)
+	}
+	format, err := outputFormatOf(output)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := runArgs(ctx, args, DefaultHooks)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if format == EncodeYAML {
+		buf.WriteString(result.GetRawYamlResult())
+	} else if err := result.Encode(&buf, format); err != nil {
+		return nil, fmt.Errorf("kcl.RunSettings: %s: %w", output, err)
+	}
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// outputFormatOf returns the format of an output file by its extension.
+func outputFormatOf(output string) (EncodeFormat, error) {
+	name := strings.ToLower(filepath.Base(output))
+	if strings.HasSuffix(name, ".tfvars.json") {
+		return EncodeTFVarsJSON, nil
+	}
+	switch ext := filepath.Ext(name); ext {
+	case ".yaml", ".yml":
+		return EncodeYAML, nil
+	case ".json":
+		return EncodeJSON, nil
+	case ".jsonl", ".ndjson":
+		return EncodeJSONLines, nil
+	case ".toml":
+		return EncodeTOML, nil
+	case ".env":
+		return EncodeDotenv, nil
+	case ".properties":
+		return EncodeProperties, nil
+	case ".tfvars":
+		return EncodeTFVars, nil
+	case ".xml":
+		return EncodeXML, nil
+	default:
+		return "", fmt.Errorf("kcl.RunSettings: unsupported output format %q of %s", ext, output)
+	}
+}
@@ -1,11 +1,58 @@
 package kcl
 
 import (
+	"sync/atomic"
+
 	"kcl-lang.io/lib/go/api"
 	"kcl-lang.io/lib/go/native"
 )
 
+// defaultService holds the service set by SetDefaultService.
+var defaultService atomic.Pointer[api.ServiceClient]
+
 // Service returns the interaction interface between KCL Go SDK and KCL Rust core.
+// It is the service set by SetDefaultService, or the native service.
 func Service() api.ServiceClient {
+	if svc := defaultService.Load(); svc != nil {
+		return *svc
+	}
 	return native.NewNativeServiceClient()
 }
+
+// SetDefaultService sets the service returned by Service and used by all
+// the calls without a WithService option, e.g. a remote protorpc or gRPC
+// client, a subprocess pool or a test double. A nil svc restores the native
+// service. It is safe for concurrent use.
+func SetDefaultService(svc api.ServiceClient) {
+	if svc == nil {
+		defaultService.Store(nil)
+		return
+	}
+	defaultService.Store(&svc)
+}
+
+// WithService returns a Option which runs the call with svc instead of the
+// default service, see SetDefaultService.
+func WithService(svc api.ServiceClient) Option {
+	var opt = NewOption()
+	opt.service = svc
+	return *opt
+}
+
+// GetService returns the service set by WithService, or Service.
+func (p *Option) GetService() api.ServiceClient {
+	if p.service != nil {
+		return p.service
+	}
+	return Service()
+}
+
+// ServiceOf returns the service of the options, see Option.GetService.
+func ServiceOf(opts ...Option) api.ServiceClient {
+	for i := len(opts) - 1; i >= 0; i-- {
+		if opts[i].service != nil {
+			return opts[i].service
+		}
+	}
+	return Service()
+}
@@ -0,0 +1,52 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// fakeService answers GetVersion and ExecProgram, the other methods panic.
+type fakeService struct {
+	api.ServiceClient
+	version string
+	calls   int
+}
+
+func (s *fakeService) GetVersion(*gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+	s.calls++
+	return &gpyrpc.GetVersionResult{Version: s.version}, nil
+}
+
+func (s *fakeService) ExecProgram(args *gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	s.calls++
+	return &gpyrpc.ExecProgramResult{JsonResult: `{"a": 1}`, YamlResult: "a: 1\n"}, nil
+}
+
+func TestSetDefaultService(t *testing.T) {
+	def := &fakeService{version: "default"}
+	SetDefaultService(def)
+	defer SetDefaultService(nil)
+
+	v, err := GetVersion()
+	tAssert(t, err == nil && v.Version == "default", v, err)
+
+	call := &fakeService{version: "call"}
+	v, err = GetVersion(WithService(call))
+	tAssert(t, err == nil && v.Version == "call", v, err)
+
+	result, err := Run("main.k", WithService(call))
+	tAssert(t, err == nil && result.First().Get("a") == 1, result, err)
+	tAssert(t, def.calls == 1 && call.calls == 2, def.calls, call.calls)
+
+	o := NewOption().Merge(WithService(call))
+	tAssert(t, o.GetService() == call)
+	tAssert(t, ServiceOf() == def)
+
+	SetDefaultService(nil)
+	_, isFake := Service().(*fakeService)
+	tAssert(t, !isFake)
+}
@@ -0,0 +1,271 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+// OptionProblemKind classifies an OptionProblem.
+type OptionProblemKind string
+
+const (
+	// OptionUnknown is an option passed to the run which the program never reads.
+	OptionUnknown OptionProblemKind = "unknown"
+	// OptionMissing is a required option which is not passed to the run.
+	OptionMissing OptionProblemKind = "missing"
+	// OptionTypeMismatch is an option whose value does not match the declared type.
+	OptionTypeMismatch OptionProblemKind = "type mismatch"
+)
+
+// OptionProblem is a single problem found by WithStrictOptions.
+type OptionProblem struct {
+	Kind OptionProblemKind
+	// Name is the option name.
+	Name string
+	// Type is the type declared by the program, e.g. "int", if any.
+	Type string
+	// Value is the value passed to the run, if any.
+	Value string
+	// Suggestion is the name of a declared option close to an unknown one.
+	Suggestion string
+}
+
+func (p *OptionProblem) String() string {
+	switch p.Kind {
+	case OptionUnknown:
+		if p.Suggestion != "" {
+			return fmt.Sprintf("unknown option %q, did you mean %q?", p.Name, p.Suggestion)
+		}
+		return fmt.Sprintf("unknown option %q", p.Name)
+	case OptionMissing:
+		return fmt.Sprintf("missing required option %q", p.Name)
+	case OptionTypeMismatch:
+		return fmt.Sprintf("option %q expects type %s, got %s", p.Name, p.Type, p.Value)
+	}
+	return fmt.Sprintf("option %q: %s", p.Name, p.Kind)
+}
+
+// OptionsError is returned by the run APIs when WithStrictOptions is set and
+// the options passed to the run do not match the ones the program declares.
+// It holds all the problems found.
+type OptionsError struct {
+	Problems []OptionProblem
+}
+
+func (e *OptionsError) Error() string {
+	var lines []string
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return fmt.Sprintf("kcl: invalid options:\n%s", strings.Join(lines, "\n"))
+}
+
+// WithStrictOptions returns a Option which checks the top level arguments,
+// `kcl -D name=value`, against the option() calls of the program before it
+// runs. Unknown names, missing required options and values not matching the
+// declared type are all reported by an *OptionsError.
+func WithStrictOptions(strict bool) Option {
+	var opt = NewOption()
+	opt.strictOptions = strict
+	return *opt
+}
+
+// checkStrictOptions lists the options declared by the program of o and
+// checks the arguments of o against them.
+func checkStrictOptions(ctx context.Context, o *Option) error {
+	in := &gpyrpc.ParseProgramArgs{
+		ExternalPkgs: o.ExternalPkgs,
+	}
+	for i, name := range o.KFilenameList {
+		if i < len(o.KCodeList) {
+			in.Sources = append(in.Sources, o.KCodeList[i])
+		} else if !filepath.IsAbs(name) && o.WorkDir != "" {
+			name = filepath.Join(o.WorkDir, name)
+		}
+		in.Paths = append(in.Paths, name)
+	}
+
+	svc := ContextService(ctx, o.GetService())
+	resp, err := CallContext(ctx, "Run", func() (*gpyrpc.ListOptionsResult, error) {
+		return svc.ListOptions(in)
+	})
+	if err != nil {
+		return err
+	}
+	if problems := checkOptions(resp.Options, o.Args); len(problems) > 0 {
+		return &OptionsError{Problems: problems}
+	}
+	return nil
+}
+
+// checkOptions checks the arguments of a run against the declared options.
+// Problems are ordered by kind, then by argument order or option name.
+func checkOptions(helps []*gpyrpc.OptionHelp, args []*gpyrpc.Argument) []OptionProblem {
+	// An option may be read by several option() calls.
+	type declared struct {
+		required bool
+		types    []string
+	}
+	decls := make(map[string]*declared)
+	var names []string
+	for _, h := range helps {
+		if h == nil || h.Name == "" {
+			continue
+		}
+		d, ok := decls[h.Name]
+		if !ok {
+			d = &declared{}
+			decls[h.Name] = d
+			names = append(names, h.Name)
+		}
+		d.required = d.required || h.Required
+		if h.Type != "" {
+			d.types = append(d.types, h.Type)
+		}
+	}
+	sort.Strings(names)
+
+	var unknown, missing, mismatch []OptionProblem
+	passed := make(map[string]bool)
+	for _, arg := range args {
+		passed[arg.Name] = true
+		d, ok := decls[arg.Name]
+		if !ok {
+			unknown = append(unknown, OptionProblem{
+				Kind:       OptionUnknown,
+				Name:       arg.Name,
+				Value:      arg.Value,
+				Suggestion: closestName(arg.Name, names),
+			})
+			continue
+		}
+		for _, typ := range d.types {
+			if !optionValueHasType(arg.Value, typ) {
+				mismatch = append(mismatch, OptionProblem{
+					Kind:  OptionTypeMismatch,
+					Name:  arg.Name,
+					Type:  typ,
+					Value: arg.Value,
+				})
+				break
+			}
+		}
+	}
+	for _, name := range names {
+		if decls[name].required && !passed[name] {
+			missing = append(missing, OptionProblem{Kind: OptionMissing, Name: name})
+		}
+	}
+
+	var problems []OptionProblem
+	problems = append(problems, unknown...)
+	problems = append(problems, missing...)
+	problems = append(problems, mismatch...)
+	return problems
+}
+
+// optionValueHasType reports whether the argument value can be converted to
+// the option type typ by KCL. Like KCL, the value is decoded as JSON when it
+// is valid JSON and used as a string otherwise. Unknown types always match.
+func optionValueHasType(value, typ string) bool {
+	var v any = value
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+	var x any
+	if err := dec.Decode(&x); err == nil && !dec.More() {
+		v = x
+	}
+
+	switch typ {
+	case "str":
+		switch v.(type) {
+		case string, json.Number, bool:
+			return true
+		}
+		return false
+	case "int":
+		switch v := v.(type) {
+		case json.Number:
+			_, err := v.Int64()
+			return err == nil
+		case string:
+			_, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return err == nil
+		}
+		return false
+	case "float":
+		switch v := v.(type) {
+		case json.Number:
+			return true
+		case string:
+			_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return err == nil
+		}
+		return false
+	case "bool":
+		switch v := v.(type) {
+		case bool:
+			return true
+		case string:
+			switch v {
+			case "True", "False", "true", "false":
+				return true
+			}
+		}
+		return false
+	case "list":
+		_, ok := v.([]any)
+		return ok
+	case "dict":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+// closestName returns the name in names closest to s, if it is close enough
+// to be a likely typo.
+func closestName(s string, names []string) string {
+	best, bestDist := "", 0
+	for _, name := range names {
+		d := editDistance(strings.ToLower(s), strings.ToLower(name))
+		if best == "" || d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	if best == "" || bestDist > 2 || bestDist >= len(s) {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,87 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcl
+
+import (
+	"strings"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+func TestCheckOptions(t *testing.T) {
+	helps := []*gpyrpc.OptionHelp{
+		{Name: "env", Type: "str", Required: true},
+		{Name: "replicas", Type: "int"},
+		{Name: "replicas"},
+		{Name: "debug", Type: "bool"},
+		{Name: "labels", Type: "dict"},
+		{Name: "region", Required: true},
+	}
+	args := []*gpyrpc.Argument{
+		{Name: "envv", Value: "prod"},
+		{Name: "replicas", Value: "3.5"},
+		{Name: "debug", Value: "True"},
+		{Name: "labels", Value: `["a"]`},
+		{Name: "zzz", Value: "1"},
+	}
+	problems := checkOptions(helps, args)
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	expect := []string{
+		`unknown option "envv", did you mean "env"?`,
+		`unknown option "zzz"`,
+		`missing required option "env"`,
+		`missing required option "region"`,
+		`option "replicas" expects type int, got 3.5`,
+		`option "labels" expects type dict, got ["a"]`,
+	}
+	tAssert(t, strings.Join(got, "\n") == strings.Join(expect, "\n"), strings.Join(got, "\n"))
+
+	err := &OptionsError{Problems: problems}
+	tAssert(t, strings.Contains(err.Error(), `did you mean "env"?`), err)
+
+	problems = checkOptions(helps, []*gpyrpc.Argument{
+		{Name: "env", Value: "prod"},
+		{Name: "region", Value: `"us"`},
+		{Name: "replicas", Value: "3"},
+		{Name: "labels", Value: `{"app": "kcl"}`},
+	})
+	tAssert(t, len(problems) == 0, problems)
+}
+
+func TestOptionValueHasType(t *testing.T) {
+	for _, tt := range []struct {
+		value, typ string
+		ok         bool
+	}{
+		{"prod", "str", true},
+		{`"prod"`, "str", true},
+		{"123", "str", true},
+		{"[1]", "str", false},
+		{"3", "int", true},
+		{`"3"`, "int", true},
+		{"3.5", "int", false},
+		{"x", "int", false},
+		{"3.5", "float", true},
+		{"3", "float", true},
+		{"true", "bool", true},
+		{"False", "bool", true},
+		{"1", "bool", false},
+		{"[1, 2]", "list", true},
+		{`{"a": 1}`, "list", false},
+		{`{"a": 1}`, "dict", true},
+		{"anything", "", true},
+		{"anything", "any", true},
+	} {
+		tAssert(t, optionValueHasType(tt.value, tt.typ) == tt.ok, tt.value, tt.typ)
+	}
+}
+
+func TestWithStrictOptions(t *testing.T) {
+	opt := NewOption().Merge(WithStrictOptions(true), WithWorkDir("."))
+	tAssert(t, opt.strictOptions, "expect strict options to be set")
+}
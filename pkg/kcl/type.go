@@ -1,6 +1,8 @@
 package kcl
 
 import (
+	"context"
+
 	"kcl-lang.io/kcl-go/pkg/source"
 	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
 )
@@ -16,8 +18,8 @@ import (
 // Returns:
 //   - A slice of pointers to KclType representing the schema types.
 //   - An error if there is any failure in the process.
-func GetSchemaType(filename string, src any, schemaName string) ([]*gpyrpc.KclType, error) {
-	mapping, err := GetSchemaTypeMapping(filename, src, schemaName)
+func GetSchemaType(filename string, src any, schemaName string, opts ...Option) ([]*gpyrpc.KclType, error) {
+	mapping, err := GetSchemaTypeMapping(filename, src, schemaName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +63,7 @@ func GetFullSchemaTypeMapping(pathList []string, schemaName string, opts ...Opti
 		return nil, err
 	}
 
-	svc := Service()
+	svc := args.GetService()
 	resp, err := svc.GetSchemaTypeMapping(&gpyrpc.GetSchemaTypeMappingArgs{
 		ExecArgs:   args.ExecProgramArgs,
 		SchemaName: schemaName,
@@ -85,18 +87,26 @@ func GetFullSchemaTypeMapping(pathList []string, schemaName string, opts ...Opti
 // Returns:
 //   - A map where the key is the schema name and the value is a pointer to KclType representing the schema type.
 //   - An error if there is any failure in the process.
-func GetSchemaTypeMapping(filename string, src any, schemaName string) (map[string]*gpyrpc.KclType, error) {
+func GetSchemaTypeMapping(filename string, src any, schemaName string, opts ...Option) (map[string]*gpyrpc.KclType, error) {
+	return GetSchemaTypeMappingContext(context.Background(), filename, src, schemaName, opts...)
+}
+
+// GetSchemaTypeMappingContext is like GetSchemaTypeMapping but returns a
+// *CancelError when ctx is cancelled or its deadline passes first.
+func GetSchemaTypeMappingContext(ctx context.Context, filename string, src any, schemaName string, opts ...Option) (map[string]*gpyrpc.KclType, error) {
 	source, err := source.ReadSource(filename, src)
 	if err != nil {
 		return nil, err
 	}
-	svc := Service()
-	resp, err := svc.GetSchemaTypeMapping(&gpyrpc.GetSchemaTypeMappingArgs{
-		ExecArgs: &gpyrpc.ExecProgramArgs{
-			KFilenameList: []string{filename},
-			KCodeList:     []string{string(source)},
-		},
-		SchemaName: schemaName,
+	svc := ContextService(ctx, ServiceOf(opts...))
+	resp, err := CallContext(ctx, "GetSchemaTypeMapping", func() (*gpyrpc.GetSchemaTypeMappingResult, error) {
+		return svc.GetSchemaTypeMapping(&gpyrpc.GetSchemaTypeMappingArgs{
+			ExecArgs: &gpyrpc.ExecProgramArgs{
+				KFilenameList: []string{filename},
+				KCodeList:     []string{string(source)},
+			},
+			SchemaName: schemaName,
+		})
 	})
 	if err != nil {
 		return nil, err
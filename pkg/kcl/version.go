@@ -7,8 +7,8 @@ import (
 type VersionResult = gpyrpc.GetVersionResult
 
 // GetVersion returns the KCL service version information.
-func GetVersion() (*VersionResult, error) {
-	svc := Service()
+func GetVersion(opts ...Option) (*VersionResult, error) {
+	svc := ServiceOf(opts...)
 	resp, err := svc.GetVersion(&gpyrpc.GetVersionArgs{})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,383 @@
+// Copyright The KCL Authors. All rights reserved.
+
+// Package fakeservice provides a fake KCL service of the tests which do not
+// want the native library, see kcl.WithService and kcl.SetDefaultService.
+//
+//	func TestApp(t *testing.T) {
+//		svc := fakeservice.New(fakeservice.Options{
+//			Dir:  "testdata/fixtures",
+//			Mode: fakeservice.ModeFromEnv(),
+//		})
+//		result, err := kcl.Run("app/main.k", kcl.WithService(svc))
+//		...
+//	}
+//
+// The fixtures are recorded by running the tests once with the real service,
+// e.g. `KCLTEST_RECORD=1 go test ./...`, and replayed offline afterwards.
+// Scripted responses and errors, see Service.Respond and Service.Fail, are
+// served before the fixtures in both modes.
+package fakeservice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// RecordEnv is the environment variable which, set to a true value, makes
+// ModeFromEnv return ModeRecord.
+const RecordEnv = "KCLTEST_RECORD"
+
+// rootVar replaces the root directory in the recorded fixtures.
+const rootVar = "$ROOT"
+
+// Mode is the way a Service answers the calls which are not scripted.
+type Mode int
+
+const (
+	// ModeReplay answers with the recorded fixtures and fails the calls
+	// without one.
+	ModeReplay Mode = iota
+	// ModeRecord answers with the real service and records its answers.
+	ModeRecord
+)
+
+// ModeFromEnv returns ModeRecord if RecordEnv is set to a true value, and
+// ModeReplay otherwise.
+func ModeFromEnv() Mode {
+	if ok, _ := strconv.ParseBool(os.Getenv(RecordEnv)); ok {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Options configures a Service.
+type Options struct {
+	// Dir is the fixture directory, e.g. "testdata/fixtures".
+	Dir string
+	// Mode is ModeReplay by default.
+	Mode Mode
+	// Client is the real service called in ModeRecord, e.g. kcl.Service().
+	Client api.ServiceClient
+	// Root is replaced by "$ROOT" in the recorded requests and responses,
+	// so the fixtures do not depend on the checkout path. It is the working
+	// directory by default.
+	Root string
+}
+
+// Call is a call received by a Service.
+type Call struct {
+	Method  string
+	Request any
+}
+
+// Handler answers the calls of a method, req and the returned result have
+// the argument and result types of the method, e.g. *gpyrpc.ExecProgramArgs
+// and *gpyrpc.ExecProgramResult.
+type Handler func(req any) (any, error)
+
+// fixture is the JSON file of a recorded call.
+type fixture struct {
+	Method  string          `json:"method"`
+	Request json.RawMessage `json:"request"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Service is a fake api.ServiceClient which records, replays and scripts
+// the calls. It is safe for concurrent use.
+type Service struct {
+	opts Options
+
+	mu       sync.Mutex
+	scripted map[string][]Handler
+	handlers map[string]Handler
+	calls    []Call
+}
+
+var _ api.ServiceClient = (*Service)(nil)
+
+// New returns a Service with opts.
+func New(opts Options) *Service {
+	if opts.Root == "" {
+		opts.Root, _ = os.Getwd()
+	}
+	return &Service{
+		opts:     opts,
+		scripted: make(map[string][]Handler),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Respond scripts the result of the next call of method, e.g.
+//
+//	svc.Respond("ExecProgram", &gpyrpc.ExecProgramResult{ErrMessage: "EvaluationError"})
+//
+// The scripted answers of a method are served once each, in order.
+func (s *Service) Respond(method string, result any) {
+	s.script(method, func(any) (any, error) { return result, nil })
+}
+
+// Fail scripts the error of the next call of method, see Respond.
+func (s *Service) Fail(method string, err error) {
+	s.script(method, func(any) (any, error) { return nil, err })
+}
+
+// Handle answers all the calls of method, which are not scripted by Respond
+// or Fail, with h instead of the fixtures. A nil h removes the handler.
+func (s *Service) Handle(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h == nil {
+		delete(s.handlers, method)
+		return
+	}
+	s.handlers[method] = h
+}
+
+// Calls returns the calls received so far.
+func (s *Service) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+func (s *Service) script(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripted[method] = append(s.scripted[method], h)
+}
+
+// handler returns the scripted answer or the handler of method and records
+// the call.
+func (s *Service) handler(method string, req any) Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Method: method, Request: req})
+	if hs := s.scripted[method]; len(hs) > 0 {
+		s.scripted[method] = hs[1:]
+		return hs[0]
+	}
+	return s.handlers[method]
+}
+
+// call answers a call of method with a scripted answer, a handler, the real
+// service or a fixture.
+func call[Args, Result any](s *Service, method string, in *Args, real func(api.ServiceClient, *Args) (*Result, error)) (*Result, error) {
+	if h := s.handler(method, in); h != nil {
+		out, err := h(in)
+		if err != nil || out == nil {
+			return nil, err
+		}
+		result, ok := out.(*Result)
+		if !ok {
+			return nil, fmt.Errorf("fakeservice: %s: unexpected result type %T, want %T", method, out, (*Result)(nil))
+		}
+		return result, nil
+	}
+
+	request, err := s.normalize(in)
+	if err != nil {
+		return nil, fmt.Errorf("fakeservice: %s: %w", method, err)
+	}
+	file := s.fixtureFile(method, request)
+	if s.opts.Mode == ModeRecord {
+		if s.opts.Client == nil {
+			return nil, fmt.Errorf("fakeservice: %s: no client to record with", method)
+		}
+		result, callErr := real(s.opts.Client, in)
+		if err := s.record(file, method, request, result, callErr); err != nil {
+			return nil, fmt.Errorf("fakeservice: %s: %w", method, err)
+		}
+		return result, callErr
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("fakeservice: %s: no fixture %s for the request %s, record it with %s=1", method, file, request, RecordEnv)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fakeservice: %s: %w", method, err)
+	}
+	var f fixture
+	if err := json.Unmarshal([]byte(strings.ReplaceAll(string(data), rootVar, s.root())), &f); err != nil {
+		return nil, fmt.Errorf("fakeservice: %s: %w", file, err)
+	}
+	if f.Error != "" {
+		return nil, errors.New(f.Error)
+	}
+	result := new(Result)
+	if len(f.Result) != 0 {
+		if err := json.Unmarshal(f.Result, result); err != nil {
+			return nil, fmt.Errorf("fakeservice: %s: %w", file, err)
+		}
+	}
+	return result, nil
+}
+
+// normalize returns the canonical JSON of a request: the object keys are
+// sorted and the root directory is replaced by "$ROOT".
+func (s *Service) normalize(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(s.replaceRoot(value))
+}
+
+func (s *Service) replaceRoot(v any) any {
+	switch v := v.(type) {
+	case string:
+		if root := s.root(); root != "" {
+			return strings.ReplaceAll(v, root, rootVar)
+		}
+	case []any:
+		for i := range v {
+			v[i] = s.replaceRoot(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = s.replaceRoot(v[k])
+		}
+	}
+	return v
+}
+
+// root returns the root directory with forward slashes, as it appears in
+// the KCL paths.
+func (s *Service) root() string {
+	return filepath.ToSlash(s.opts.Root)
+}
+
+// fixtureFile returns <dir>/<method>-<hash>.json, hash is a digest of the
+// normalized request.
+func (s *Service) fixtureFile(method string, request []byte) string {
+	sum := sha256.Sum256(append([]byte(method+"\n"), request...))
+	return filepath.Join(s.opts.Dir, method+"-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+func (s *Service) record(file, method string, request []byte, result any, callErr error) error {
+	f := fixture{Method: method, Request: request}
+	if callErr != nil {
+		f.Error = callErr.Error()
+		if root := s.root(); root != "" {
+			f.Error = strings.ReplaceAll(f.Error, root, rootVar)
+		}
+	} else {
+		data, err := s.normalize(result)
+		if err != nil {
+			return err
+		}
+		f.Result = data
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o644)
+}
+
+func (s *Service) Ping(in *gpyrpc.PingArgs) (*gpyrpc.PingResult, error) {
+	return call(s, "Ping", in, api.ServiceClient.Ping)
+}
+
+func (s *Service) GetVersion(in *gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+	return call(s, "GetVersion", in, api.ServiceClient.GetVersion)
+}
+
+func (s *Service) ParseFile(in *gpyrpc.ParseFileArgs) (*gpyrpc.ParseFileResult, error) {
+	return call(s, "ParseFile", in, api.ServiceClient.ParseFile)
+}
+
+func (s *Service) ParseProgram(in *gpyrpc.ParseProgramArgs) (*gpyrpc.ParseProgramResult, error) {
+	return call(s, "ParseProgram", in, api.ServiceClient.ParseProgram)
+}
+
+func (s *Service) ListOptions(in *gpyrpc.ParseProgramArgs) (*gpyrpc.ListOptionsResult, error) {
+	return call(s, "ListOptions", in, api.ServiceClient.ListOptions)
+}
+
+func (s *Service) ListVariables(in *gpyrpc.ListVariablesArgs) (*gpyrpc.ListVariablesResult, error) {
+	return call(s, "ListVariables", in, api.ServiceClient.ListVariables)
+}
+
+func (s *Service) LoadPackage(in *gpyrpc.LoadPackageArgs) (*gpyrpc.LoadPackageResult, error) {
+	return call(s, "LoadPackage", in, api.ServiceClient.LoadPackage)
+}
+
+func (s *Service) ExecProgram(in *gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	return call(s, "ExecProgram", in, api.ServiceClient.ExecProgram)
+}
+
+func (s *Service) BuildProgram(in *gpyrpc.BuildProgramArgs) (*gpyrpc.BuildProgramResult, error) {
+	return call(s, "BuildProgram", in, api.ServiceClient.BuildProgram)
+}
+
+func (s *Service) ExecArtifact(in *gpyrpc.ExecArtifactArgs) (*gpyrpc.ExecProgramResult, error) {
+	return call(s, "ExecArtifact", in, api.ServiceClient.ExecArtifact)
+}
+
+func (s *Service) OverrideFile(in *gpyrpc.OverrideFileArgs) (*gpyrpc.OverrideFileResult, error) {
+	return call(s, "OverrideFile", in, api.ServiceClient.OverrideFile)
+}
+
+func (s *Service) GetSchemaTypeMapping(in *gpyrpc.GetSchemaTypeMappingArgs) (*gpyrpc.GetSchemaTypeMappingResult, error) {
+	return call(s, "GetSchemaTypeMapping", in, api.ServiceClient.GetSchemaTypeMapping)
+}
+
+func (s *Service) FormatCode(in *gpyrpc.FormatCodeArgs) (*gpyrpc.FormatCodeResult, error) {
+	return call(s, "FormatCode", in, api.ServiceClient.FormatCode)
+}
+
+func (s *Service) FormatPath(in *gpyrpc.FormatPathArgs) (*gpyrpc.FormatPathResult, error) {
+	return call(s, "FormatPath", in, api.ServiceClient.FormatPath)
+}
+
+func (s *Service) LintPath(in *gpyrpc.LintPathArgs) (*gpyrpc.LintPathResult, error) {
+	return call(s, "LintPath", in, api.ServiceClient.LintPath)
+}
+
+func (s *Service) ValidateCode(in *gpyrpc.ValidateCodeArgs) (*gpyrpc.ValidateCodeResult, error) {
+	return call(s, "ValidateCode", in, api.ServiceClient.ValidateCode)
+}
+
+func (s *Service) ListDepFiles(in *gpyrpc.ListDepFilesArgs) (*gpyrpc.ListDepFilesResult, error) {
+	return call(s, "ListDepFiles", in, api.ServiceClient.ListDepFiles)
+}
+
+func (s *Service) LoadSettingsFiles(in *gpyrpc.LoadSettingsFilesArgs) (*gpyrpc.LoadSettingsFilesResult, error) {
+	return call(s, "LoadSettingsFiles", in, api.ServiceClient.LoadSettingsFiles)
+}
+
+func (s *Service) Rename(in *gpyrpc.RenameArgs) (*gpyrpc.RenameResult, error) {
+	return call(s, "Rename", in, api.ServiceClient.Rename)
+}
+
+func (s *Service) RenameCode(in *gpyrpc.RenameCodeArgs) (*gpyrpc.RenameCodeResult, error) {
+	return call(s, "RenameCode", in, api.ServiceClient.RenameCode)
+}
+
+func (s *Service) Test(in *gpyrpc.TestArgs) (*gpyrpc.TestResult, error) {
+	return call(s, "Test", in, api.ServiceClient.Test)
+}
+
+func (s *Service) UpdateDependencies(in *gpyrpc.UpdateDependenciesArgs) (*gpyrpc.UpdateDependenciesResult, error) {
+	return call(s, "UpdateDependencies", in, api.ServiceClient.UpdateDependencies)
+}
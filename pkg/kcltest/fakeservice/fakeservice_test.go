@@ -0,0 +1,108 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package fakeservice
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// realService answers ExecProgram with the work dir of the request.
+type realService struct {
+	api.ServiceClient
+	calls int
+}
+
+func (s *realService) ExecProgram(args *gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	s.calls++
+	return &gpyrpc.ExecProgramResult{
+		JsonResult: `{"dir": "` + args.WorkDir + `"}`,
+		YamlResult: "dir: " + args.WorkDir + "\n",
+	}, nil
+}
+
+func (s *realService) GetVersion(*gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+	s.calls++
+	return nil, errors.New("unavailable")
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "checkout")
+	real := &realService{}
+
+	svc := New(Options{Dir: dir, Mode: ModeRecord, Client: real, Root: root})
+	result, err := kcl.Run("main.k", kcl.WithService(svc), kcl.WithWorkDir(root+"/app"))
+	if err != nil || result.First().Get("dir") != root+"/app" {
+		t.Fatal(result, err)
+	}
+	if _, err := svc.GetVersion(&gpyrpc.GetVersionArgs{}); err == nil || err.Error() != "unavailable" {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "ExecProgram-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expect one ExecProgram fixture, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if strings.Contains(string(data), root) || !strings.Contains(string(data), "$ROOT/app") {
+		t.Fatalf("expect the root to be replaced in %s", data)
+	}
+
+	// The replay of another checkout uses its own root.
+	other := filepath.Join(dir, "other")
+	svc = New(Options{Dir: dir, Root: other})
+	result, err = kcl.Run("main.k", kcl.WithService(svc), kcl.WithWorkDir(other+"/app"))
+	if err != nil || result.First().Get("dir") != other+"/app" {
+		t.Fatal(result, err)
+	}
+	if _, err := svc.GetVersion(&gpyrpc.GetVersionArgs{}); err == nil || err.Error() != "unavailable" {
+		t.Fatal(err)
+	}
+	if real.calls != 2 {
+		t.Fatalf("expect 2 real calls, got %d", real.calls)
+	}
+
+	_, err = kcl.Run("main.k", kcl.WithService(svc), kcl.WithWorkDir(other+"/missing"))
+	if err == nil || !strings.Contains(err.Error(), "no fixture") {
+		t.Fatalf("expect a missing fixture error, got %v", err)
+	}
+}
+
+func TestScript(t *testing.T) {
+	svc := New(Options{Dir: t.TempDir()})
+	svc.Respond("ExecProgram", &gpyrpc.ExecProgramResult{ErrMessage: "EvaluationError: boom"})
+	svc.Fail("ExecProgram", errors.New("connection reset"))
+	svc.Handle("ExecProgram", func(req any) (any, error) {
+		return &gpyrpc.ExecProgramResult{JsonResult: `{"a": 1}`, YamlResult: "a: 1\n"}, nil
+	})
+
+	_, err := kcl.Run("main.k", kcl.WithService(svc))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expect the scripted error message, got %v", err)
+	}
+	_, err = kcl.Run("main.k", kcl.WithService(svc))
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("expect the injected error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		result, err := kcl.Run("main.k", kcl.WithService(svc))
+		if err != nil || result.First().Get("a") != 1 {
+			t.Fatal(result, err)
+		}
+	}
+	if calls := svc.Calls(); len(calls) != 4 || calls[0].Method != "ExecProgram" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+
+	svc.Respond("GetVersion", &gpyrpc.ExecProgramResult{})
+	if _, err := svc.GetVersion(&gpyrpc.GetVersionArgs{}); err == nil || !strings.Contains(err.Error(), "unexpected result type") {
+		t.Fatalf("expect a result type error, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright The KCL Authors. All rights reserved.
+
+// Package kcltest provides golden file tests of KCL programs.
+//
+//	func TestApp(t *testing.T) {
+//		kcltest.SnapshotCases(t, "app/main.k",
+//			kcltest.Case{Name: "dev", Options: []kcl.Option{kcl.WithOptions("env=dev")}},
+//			kcltest.Case{Name: "prod", Options: []kcl.Option{kcl.WithOptions("env=prod")}},
+//		)
+//	}
+//
+// The golden files are written by running the tests with the -kcltest.update
+// flag, e.g. `go test ./app -run TestApp -kcltest.update`, or with the
+// KCLTEST_UPDATE environment variable set to a true value. The flag is
+// namespaced so the tested packages can still define their own -update.
+package kcltest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+// UpdateEnv is the environment variable which, set to a true value, makes
+// the snapshots rewrite their golden files like the -kcltest.update flag.
+const UpdateEnv = "KCLTEST_UPDATE"
+
+var update = flag.Bool("kcltest.update", false, "kcltest: update the golden files")
+
+// Case is a named option set of SnapshotCases.
+type Case struct {
+	Name    string
+	Options []kcl.Option
+}
+
+// Update reports whether the golden files are rewritten, see UpdateEnv.
+func Update() bool {
+	if *update {
+		return true
+	}
+	ok, _ := strconv.ParseBool(os.Getenv(UpdateEnv))
+	return ok
+}
+
+var goldenNameInvalidRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// GoldenFile returns the golden file of the test, testdata/<name>.golden.yaml
+// where name is the test name, e.g. "TestApp_prod" for the subtest "prod".
+func GoldenFile(t testing.TB) string {
+	name := goldenNameInvalidRegexp.ReplaceAllString(t.Name(), "_")
+	return filepath.Join("testdata", name+".golden.yaml")
+}
+
+// Snapshot runs the KCL program at path with opts and compares its YAML
+// output with the golden file of the test, see GoldenFile. The documents
+// are compared by value, so formatting and key order do not matter, and a
+// mismatch fails the test with a structural diff, see kcl.WriteDiff.
+func Snapshot(t testing.TB, path string, opts ...kcl.Option) {
+	t.Helper()
+	result, err := kcl.Run(path, opts...)
+	if err != nil {
+		t.Fatalf("kcltest: run %s: %v", path, err)
+	}
+	checkGolden(t, GoldenFile(t), result, opts)
+}
+
+// SnapshotCases runs Snapshot with the options of every case in a subtest
+// named by the case, so one call covers all the environments of a program.
+func SnapshotCases(t *testing.T, path string, cases ...Case) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			t.Helper()
+			Snapshot(t, path, c.Options...)
+		})
+	}
+}
+
+func checkGolden(t testing.TB, golden string, result *kcl.KCLResultList, opts []kcl.Option) {
+	t.Helper()
+	var actual bytes.Buffer
+	if err := result.Encode(&actual, kcl.EncodeYAML); err != nil {
+		t.Fatalf("kcltest: encode result: %v", err)
+	}
+	if Update() {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatalf("kcltest: %v", err)
+		}
+		if err := os.WriteFile(golden, actual.Bytes(), 0o644); err != nil {
+			t.Fatalf("kcltest: %v", err)
+		}
+		t.Logf("kcltest: updated %s", golden)
+		return
+	}
+
+	data, err := os.ReadFile(golden)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("kcltest: %s does not exist, run the test with -kcltest.update or %s=1 to create it", golden, UpdateEnv)
+	}
+	if err != nil {
+		t.Fatalf("kcltest: %v", err)
+	}
+	// The golden file is decoded like the result, e.g. with its number mode.
+	expected, err := kcl.ExecResultToKCLResult(kcl.NewOption().Merge(opts...), &gpyrpc.ExecProgramResult{
+		JsonResult: "{}",
+		YamlResult: string(data),
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("kcltest: %s: %v", golden, err)
+	}
+	changes := kcl.DiffResults(expected, result, kcl.DiffOptions{})
+	if len(changes) == 0 {
+		return
+	}
+	var diff bytes.Buffer
+	kcl.WriteDiff(&diff, changes)
+	t.Errorf("kcltest: the result differs from %s (-golden +actual), run the test with -kcltest.update or %s=1 to accept it:\n%s", golden, UpdateEnv, diff.String())
+}
@@ -0,0 +1,97 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package kcltest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+// The tested packages may define their own -update flag.
+var _ = flag.Bool("update", false, "update the test files")
+
+// recorder records the failures of checkGolden.
+type recorder struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Logf(format string, args ...any) {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	r.fatal = true
+}
+
+func testResult(t *testing.T, yamlResult string) *kcl.KCLResultList {
+	t.Helper()
+	result, err := kcl.ExecResultToKCLResult(kcl.NewOption(), &gpyrpc.ExecProgramResult{
+		JsonResult: "{}",
+		YamlResult: yamlResult,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestGoldenFile(t *testing.T) {
+	t.Run("prod env", func(t *testing.T) {
+		if got := GoldenFile(t); got != filepath.Join("testdata", "TestGoldenFile_prod_env.golden.yaml") {
+			t.Fatalf("unexpected golden file %s", got)
+		}
+	})
+}
+
+func TestCheckGolden(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "testdata", "app.golden.yaml")
+	result := testResult(t, "name: app\nreplicas: 2\n")
+
+	r := &recorder{TB: t}
+	checkGolden(r, golden, result, nil)
+	if !r.fatal || !strings.Contains(r.errors[0], "-kcltest.update") {
+		t.Fatalf("expect a missing golden file error, got %v", r.errors)
+	}
+
+	t.Setenv(UpdateEnv, "1")
+	r = &recorder{TB: t}
+	checkGolden(r, golden, result, nil)
+	if len(r.errors) != 0 {
+		t.Fatal(r.errors)
+	}
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name: app\nreplicas: 2\n" {
+		t.Fatalf("unexpected golden file %q", data)
+	}
+	t.Setenv(UpdateEnv, "")
+
+	// The formatting and the key order do not matter.
+	r = &recorder{TB: t}
+	checkGolden(r, golden, testResult(t, "replicas: 2\nname:   'app'\n"), nil)
+	if len(r.errors) != 0 {
+		t.Fatal(r.errors)
+	}
+
+	r = &recorder{TB: t}
+	checkGolden(r, golden, testResult(t, "name: app\nreplicas: 3\n"), nil)
+	if r.fatal || len(r.errors) != 1 || !strings.Contains(r.errors[0], "@@ #0 @@\n- replicas: 2\n+ replicas: 3\n") {
+		t.Fatalf("expect a diff, got %v", r.errors)
+	}
+}
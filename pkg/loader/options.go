@@ -11,8 +11,8 @@ type ListOptionsResult = gpyrpc.ListOptionsResult
 
 // ListFileOptions provides users with the ability to parse kcl program and get all option
 // calling information.
-func ListFileOptions(filename string) (OptionHelps, error) {
-	svc := kcl.Service()
+func ListFileOptions(filename string, opts ...kcl.Option) (OptionHelps, error) {
+	svc := kcl.ServiceOf(opts...)
 	resp, err := svc.ListOptions(&gpyrpc.ParseProgramArgs{
 		Paths: []string{filename},
 	})
@@ -24,7 +24,7 @@ func ListFileOptions(filename string) (OptionHelps, error) {
 
 // ListOptions provides users with the ability to parse kcl program and get all option
 // calling information.
-func ListOptions(args *ListOptionsArgs) (*ListOptionsResult, error) {
-	svc := kcl.Service()
+func ListOptions(args *ListOptionsArgs, opts ...kcl.Option) (*ListOptionsResult, error) {
+	svc := kcl.ServiceOf(opts...)
 	return svc.ListOptions(args)
 }
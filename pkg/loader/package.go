@@ -10,7 +10,7 @@ type LoadPackageResult = gpyrpc.LoadPackageResult
 
 // LoadPackage provides users with the ability to parse KCL program and semantic model
 // information including symbols, types, definitions, etc.
-func LoadPackage(args *LoadPackageArgs) (*LoadPackageResult, error) {
-	svc := kcl.Service()
+func LoadPackage(args *LoadPackageArgs, opts ...kcl.Option) (*LoadPackageResult, error) {
+	svc := kcl.ServiceOf(opts...)
 	return svc.LoadPackage(args)
 }
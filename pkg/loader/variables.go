@@ -9,7 +9,7 @@ type ListVariablesArgs = gpyrpc.ListVariablesArgs
 type ListVariablesResult = gpyrpc.ListVariablesResult
 
 // ListVariables provides users with the ability to parse KCL program and get all variables by specs.
-func ListVariables(args *ListVariablesArgs) (*ListVariablesResult, error) {
-	svc := kcl.Service()
+func ListVariables(args *ListVariablesArgs, opts ...kcl.Option) (*ListVariablesResult, error) {
+	svc := kcl.ServiceOf(opts...)
 	return svc.ListVariables(args)
 }
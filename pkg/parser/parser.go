@@ -18,7 +18,7 @@ type ParseProgramResult = gpyrpc.ParseProgramResult
 // The source code can be provided directly as a string or []byte,
 // or indirectly via a filename or an io.Reader.
 // If src is nil, the function reads the content from the provided filename.
-func ParseFileASTJson(filename string, src any) (result string, err error) {
+func ParseFileASTJson(filename string, src any, opts ...kcl.Option) (result string, err error) {
 	var code string
 	if src != nil {
 		switch src := src.(type) {
@@ -36,7 +36,7 @@ func ParseFileASTJson(filename string, src any) (result string, err error) {
 			return "", fmt.Errorf("unsupported src type: %T", src)
 		}
 	}
-	svc := kcl.Service()
+	svc := kcl.ServiceOf(opts...)
 	resp, err := svc.ParseFile(&gpyrpc.ParseFileArgs{
 		Path:   filename,
 		Source: code,
@@ -52,8 +52,8 @@ func ParseFileASTJson(filename string, src any) (result string, err error) {
 // Tree (AST). The source code can be provided directly as a string or
 // []byte, or indirectly via a filename or an io.Reader. If src is nil,
 // the function reads the content from the provided filename.
-func ParseFile(filename string, src any) (m *ast.Module, err error) {
-	astJson, err := ParseFileASTJson(filename, src)
+func ParseFile(filename string, src any, opts ...kcl.Option) (m *ast.Module, err error) {
+	astJson, err := ParseFileASTJson(filename, src, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +66,7 @@ func ParseFile(filename string, src any) (m *ast.Module, err error) {
 }
 
 // Parse KCL program with entry files and return the AST JSON string.
-func ParseProgram(args *ParseProgramArgs) (*ParseProgramResult, error) {
-	svc := kcl.Service()
+func ParseProgram(args *ParseProgramArgs, opts ...kcl.Option) (*ParseProgramResult, error) {
+	svc := kcl.ServiceOf(opts...)
 	return svc.ParseProgram(args)
 }
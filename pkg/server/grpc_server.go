@@ -0,0 +1,290 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/pkg/service/pool"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// GrpcServiceName is the name of the KCL service in the gRPC and health
+// services.
+const GrpcServiceName = "gpyrpc.KclService"
+
+// GrpcOptions configures RunGrpcServer.
+type GrpcOptions struct {
+	// Context stops the server gracefully when it is done, e.g. a context
+	// of signal.NotifyContext. The server runs until it fails by default.
+	Context context.Context
+	// ShutdownTimeout is the time the in-flight calls have to finish when
+	// the server stops, after which they are cancelled. Zero means no limit.
+	ShutdownTimeout time.Duration
+	// ServerOptions are passed to grpc.NewServer, e.g. the credentials or
+	// the interceptors.
+	ServerOptions []grpc.ServerOption
+	// Options configure the served calls, e.g. kcl.WithService sets the
+	// service behind the server and kcl.WithRedaction redacts the
+	// ExecProgram responses.
+	Options []kcl.Option
+}
+
+// RunGrpcServer serves the KCL service over gRPC at addr, a TCP address
+// like ":8081" or a unix socket like "unix:///run/kcl.sock". It also serves
+// the standard health and reflection services, and returns nil once it is
+// stopped by opts.Context.
+func RunGrpcServer(addr string, opts GrpcOptions) error {
+	opt := kcl.NewOption().Merge(opts.Options...)
+	if opt.Err != nil {
+		return opt.Err
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	lis, err := listenGrpc(addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(opts.ServerOptions...)
+	gpyrpc.RegisterKclServiceServer(srv, &grpcServer{
+		service:   opt.GetService(),
+		redaction: opt.GetRedaction(),
+	})
+	hs := health.NewServer()
+	hs.SetServingStatus(GrpcServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+
+	fmt.Printf("listen on grpc %s ...\n", lis.Addr())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(lis)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// The clients watching the health stop sending calls first.
+	hs.Shutdown()
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	if opts.ShutdownTimeout > 0 {
+		select {
+		case <-stopped:
+		case <-time.After(opts.ShutdownTimeout):
+			srv.Stop()
+			<-stopped
+		}
+	} else {
+		<-stopped
+	}
+	return <-errc
+}
+
+// listenGrpc listens at a TCP address or a "unix://" or "unix:" socket, a
+// stale socket file of a previous server is removed. The socket of a live
+// server is kept and reported as an address in use.
+func listenGrpc(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix://")
+	if !ok {
+		path, ok = strings.CutPrefix(addr, "unix:")
+	}
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen unix %s: %w", path, syscall.EADDRINUSE)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("listen unix %s: %w", path, err)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// grpcServer adapts an api.ServiceClient to gpyrpc.KclServiceServer.
+type grpcServer struct {
+	service api.ServiceClient
+	// redaction is applied to the ExecProgram and ExecArtifact responses,
+	// see kcl.WithRedaction.
+	redaction *kcl.RedactOptions
+}
+
+var _ gpyrpc.KclServiceServer = (*grpcServer)(nil)
+
+// serviceContext returns the service bound to the context of a call, see
+// kcl.ContextService.
+func (s *grpcServer) serviceContext(ctx context.Context) api.ServiceClient {
+	return kcl.ContextService(ctx, s.service)
+}
+
+// grpcCall calls fn until it returns or the call is cancelled, see
+// kcl.CallContext, and converts its error into a gRPC status.
+func grpcCall[Args, Result any](ctx context.Context, in *Args, fn func(*Args) (*Result, error)) (*Result, error) {
+	op := "grpc"
+	if method, ok := grpc.Method(ctx); ok {
+		op = path.Base(method)
+	}
+	result, err := kcl.CallContext(ctx, op, func() (*Result, error) {
+		return fn(in)
+	})
+	if err != nil {
+		return nil, status.Error(grpcErrorCode(err), err.Error())
+	}
+	return result, nil
+}
+
+// grpcErrorCode returns the status code of an error of the service, like
+// restErrorStatus.
+func grpcErrorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, pool.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, pool.ErrWorkerExited), errors.Is(err, pool.ErrClosed):
+		return codes.Unavailable
+	case len(restDiagnostics(err)) > 0:
+		return codes.InvalidArgument
+	}
+	return codes.Unknown
+}
+
+func (s *grpcServer) Ping(ctx context.Context, in *gpyrpc.PingArgs) (*gpyrpc.PingResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).Ping)
+}
+
+func (s *grpcServer) GetVersion(ctx context.Context, in *gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).GetVersion)
+}
+
+func (s *grpcServer) ParseProgram(ctx context.Context, in *gpyrpc.ParseProgramArgs) (*gpyrpc.ParseProgramResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).ParseProgram)
+}
+
+func (s *grpcServer) ParseFile(ctx context.Context, in *gpyrpc.ParseFileArgs) (*gpyrpc.ParseFileResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).ParseFile)
+}
+
+func (s *grpcServer) LoadPackage(ctx context.Context, in *gpyrpc.LoadPackageArgs) (*gpyrpc.LoadPackageResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).LoadPackage)
+}
+
+func (s *grpcServer) ListOptions(ctx context.Context, in *gpyrpc.ParseProgramArgs) (*gpyrpc.ListOptionsResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).ListOptions)
+}
+
+func (s *grpcServer) ListVariables(ctx context.Context, in *gpyrpc.ListVariablesArgs) (*gpyrpc.ListVariablesResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).ListVariables)
+}
+
+// redact applies the redaction of s to the result of a run with args.
+func (s *grpcServer) redact(args *gpyrpc.ExecProgramArgs, result *gpyrpc.ExecProgramResult, err error) (*gpyrpc.ExecProgramResult, error) {
+	if s.redaction == nil {
+		return result, err
+	}
+	if args == nil {
+		args = new(gpyrpc.ExecProgramArgs)
+	}
+	if err != nil {
+		return nil, kcl.RedactError(*s.redaction, args, err)
+	}
+	return kcl.RedactExecResult(*s.redaction, args, result)
+}
+
+func (s *grpcServer) ExecProgram(ctx context.Context, in *gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	return grpcCall(ctx, in, func(in *gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+		result, err := s.serviceContext(ctx).ExecProgram(in)
+		return s.redact(in, result, err)
+	})
+}
+
+func (s *grpcServer) BuildProgram(ctx context.Context, in *gpyrpc.BuildProgramArgs) (*gpyrpc.BuildProgramResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).BuildProgram)
+}
+
+func (s *grpcServer) ExecArtifact(ctx context.Context, in *gpyrpc.ExecArtifactArgs) (*gpyrpc.ExecProgramResult, error) {
+	return grpcCall(ctx, in, func(in *gpyrpc.ExecArtifactArgs) (*gpyrpc.ExecProgramResult, error) {
+		result, err := s.serviceContext(ctx).ExecArtifact(in)
+		return s.redact(in.ExecArgs, result, err)
+	})
+}
+
+func (s *grpcServer) OverrideFile(ctx context.Context, in *gpyrpc.OverrideFileArgs) (*gpyrpc.OverrideFileResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).OverrideFile)
+}
+
+func (s *grpcServer) GetSchemaTypeMapping(ctx context.Context, in *gpyrpc.GetSchemaTypeMappingArgs) (*gpyrpc.GetSchemaTypeMappingResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).GetSchemaTypeMapping)
+}
+
+func (s *grpcServer) FormatCode(ctx context.Context, in *gpyrpc.FormatCodeArgs) (*gpyrpc.FormatCodeResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).FormatCode)
+}
+
+func (s *grpcServer) FormatPath(ctx context.Context, in *gpyrpc.FormatPathArgs) (*gpyrpc.FormatPathResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).FormatPath)
+}
+
+func (s *grpcServer) LintPath(ctx context.Context, in *gpyrpc.LintPathArgs) (*gpyrpc.LintPathResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).LintPath)
+}
+
+func (s *grpcServer) ValidateCode(ctx context.Context, in *gpyrpc.ValidateCodeArgs) (*gpyrpc.ValidateCodeResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).ValidateCode)
+}
+
+func (s *grpcServer) ListDepFiles(ctx context.Context, in *gpyrpc.ListDepFilesArgs) (*gpyrpc.ListDepFilesResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).ListDepFiles)
+}
+
+func (s *grpcServer) LoadSettingsFiles(ctx context.Context, in *gpyrpc.LoadSettingsFilesArgs) (*gpyrpc.LoadSettingsFilesResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).LoadSettingsFiles)
+}
+
+func (s *grpcServer) Rename(ctx context.Context, in *gpyrpc.RenameArgs) (*gpyrpc.RenameResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).Rename)
+}
+
+func (s *grpcServer) RenameCode(ctx context.Context, in *gpyrpc.RenameCodeArgs) (*gpyrpc.RenameCodeResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).RenameCode)
+}
+
+func (s *grpcServer) Test(ctx context.Context, in *gpyrpc.TestArgs) (*gpyrpc.TestResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).Test)
+}
+
+func (s *grpcServer) UpdateDependencies(ctx context.Context, in *gpyrpc.UpdateDependenciesArgs) (*gpyrpc.UpdateDependenciesResult, error) {
+	return grpcCall(ctx, in, s.serviceContext(ctx).UpdateDependencies)
+}
@@ -0,0 +1,202 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/pkg/service/pool"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// versionService answers GetVersion.
+type versionService struct {
+	api.ServiceClient
+}
+
+func (versionService) GetVersion(*gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+	return &gpyrpc.GetVersionResult{Version: "test"}, nil
+}
+
+func TestRunGrpcServer(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "kcl.sock")
+	// A regular file is not removed.
+	if err := os.WriteFile(sock, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenGrpc("unix://" + sock); err == nil {
+		t.Fatal("expect a regular file to be kept")
+	}
+	os.Remove(sock)
+
+	// A stale socket left by a previous server is replaced.
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Stat(sock); err != nil {
+		t.Fatalf("expect a stale socket, got %v", err)
+	}
+	lis, err := listenGrpc("unix:" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- RunGrpcServer("unix://"+sock, GrpcOptions{
+			Context: ctx,
+			Options: []kcl.Option{kcl.WithService(versionService{})},
+		})
+	}()
+
+	conn, err := grpc.NewClient("unix://"+sock, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	callCtx, callCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer callCancel()
+	health, err := healthpb.NewHealthClient(conn).Check(callCtx, &healthpb.HealthCheckRequest{Service: GrpcServiceName}, grpc.WaitForReady(true))
+	if err != nil || health.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatal(health, err)
+	}
+
+	// The socket of the running server is not taken over.
+	if _, err := listenGrpc("unix://" + sock); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("expect the address to be in use, got %v", err)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(callCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	if !slices.Contains(services, GrpcServiceName) || !slices.Contains(services, "grpc.health.v1.Health") {
+		t.Fatalf("unexpected services %v", services)
+	}
+	stream.CloseSend()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the server did not stop")
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Fatalf("expect the socket to be removed, got %v", err)
+	}
+}
+
+func (versionService) ExecArtifact(args *gpyrpc.ExecArtifactArgs) (*gpyrpc.ExecProgramResult, error) {
+	token := args.ExecArgs.Args[0].Value
+	return &gpyrpc.ExecProgramResult{
+		JsonResult: `{"token": "` + token + `"}`,
+		YamlResult: "token: " + token + "\n",
+	}, nil
+}
+
+func TestGrpcServer(t *testing.T) {
+	s := &grpcServer{service: versionService{}}
+	v, err := s.GetVersion(context.Background(), &gpyrpc.GetVersionArgs{})
+	if err != nil || v.Version != "test" {
+		t.Fatal(v, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.GetVersion(ctx, &gpyrpc.GetVersionArgs{}); status.Code(err) != codes.Canceled {
+		t.Fatalf("expect a cancelled call, got %v", err)
+	}
+
+	// A call blocked in the service returns when its deadline expires.
+	block := make(chan struct{})
+	defer close(block)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = grpcCall(ctx, &gpyrpc.GetVersionArgs{}, func(*gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+		<-block
+		return nil, nil
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expect an expired call, got %v", err)
+	}
+
+	for _, tt := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{fmt.Errorf("%w: ExecProgram after 1s", pool.ErrTimeout), codes.DeadlineExceeded},
+		{pool.ErrWorkerExited, codes.Unavailable},
+		{pool.ErrClosed, codes.Unavailable},
+		{kcl.NewError(restTestErrMessage), codes.InvalidArgument},
+		{errors.New(restTestErrMessage), codes.InvalidArgument},
+		{errors.New("boom"), codes.Unknown},
+	} {
+		_, err := grpcCall(context.Background(), &gpyrpc.GetVersionArgs{}, func(*gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+			return nil, tt.err
+		})
+		if status.Code(err) != tt.code {
+			t.Fatalf("expect %v for %v, got %v", tt.code, tt.err, err)
+		}
+	}
+}
+
+func TestGrpcServerRedaction(t *testing.T) {
+	s := &grpcServer{
+		service:   versionService{},
+		redaction: &kcl.RedactOptions{OptionNames: []string{"token"}},
+	}
+	result, err := s.ExecArtifact(context.Background(), &gpyrpc.ExecArtifactArgs{
+		Path: "app.bin",
+		ExecArgs: &gpyrpc.ExecProgramArgs{
+			Args: []*gpyrpc.Argument{{Name: "token", Value: "s3cr3t"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(result.JsonResult, "s3cr3t") || strings.Contains(result.YamlResult, "s3cr3t") {
+		t.Fatalf("expect the token to be redacted, got %v", result)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/julienschmidt/httprouter"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/scripts"
+)
+
+// restErrorResponses are the error statuses of the methods, see handle.
+var restErrorResponses = []struct {
+	status      int
+	description string
+}{
+	{http.StatusBadRequest, "The arguments are invalid."},
+	{http.StatusUnprocessableEntity, "The KCL code has errors, see the diagnostics."},
+	{http.StatusInternalServerError, "The service failed."},
+	{http.StatusBadGateway, "The service worker exited."},
+	{http.StatusServiceUnavailable, "The service is closed."},
+	{http.StatusGatewayTimeout, "The call timed out."},
+}
+
+func (p *restServer) handle_OpenAPI(w http.ResponseWriter, r *http.Request, _ps httprouter.Params) {
+	data, err := json.MarshalIndent(p.openAPI(), "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// openAPI returns the OpenAPI 3 document of the REST API, the schemas are
+// generated from the JSON encoding of the message types.
+func (p *restServer) openAPI() *openapi3.T {
+	g := &openAPISchemas{
+		schemas: make(openapi3.Schemas),
+		names:   make(map[reflect.Type]string),
+	}
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "KCL API",
+			Version: string(scripts.KclAbiVersion),
+		},
+		Paths: openapi3.NewPaths(),
+	}
+	diagnostics := openapi3.NewArraySchema()
+	diagnostics.Items = g.schemaRef(reflect.TypeOf(kcl.Diagnostic{}))
+
+	for _, m := range p.methods() {
+		args := reflect.TypeOf(m.args)
+		result := openapi3.NewObjectSchema().
+			WithProperty("error", openapi3.NewStringSchema()).
+			WithProperty("diagnostics", diagnostics).
+			WithPropertyRef("result", g.schemaRef(reflect.TypeOf(m.result)))
+
+		post := openapi3.NewOperation()
+		post.OperationID = m.name
+		post.Summary = m.name
+		post.RequestBody = &openapi3.RequestBodyRef{
+			Value: openapi3.NewRequestBody().WithJSONSchemaRef(g.schemaRef(args)),
+		}
+		get := openapi3.NewOperation()
+		get.OperationID = m.name + ".get"
+		get.Summary = m.name + " with query parameters"
+		for _, f := range jsonFields(args.Elem()) {
+			if s := querySchema(f.Type); s != nil {
+				get.AddParameter(openapi3.NewQueryParameter(f.name).WithSchema(s))
+			}
+		}
+		for _, op := range []*openapi3.Operation{get, post} {
+			op.AddResponse(http.StatusOK, openapi3.NewResponse().
+				WithDescription("The result of the call.").
+				WithJSONSchema(result))
+			for _, e := range restErrorResponses {
+				op.AddResponse(e.status, openapi3.NewResponse().
+					WithDescription(e.description).
+					WithJSONSchema(result))
+			}
+		}
+		doc.Paths.Set("/api:protorpc/"+m.name, &openapi3.PathItem{Get: get, Post: post})
+	}
+	doc.Components = &openapi3.Components{Schemas: g.schemas}
+	return doc
+}
+
+// openAPISchemas generates the component schemas of the Go types.
+type openAPISchemas struct {
+	schemas openapi3.Schemas
+	names   map[reflect.Type]string
+}
+
+// schemaRef returns the schema of t, the structs are referenced components.
+func (g *openAPISchemas) schemaRef(t reflect.Type) *openapi3.SchemaRef {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaRef(t.Elem())
+	case reflect.Struct:
+		return openapi3.NewSchemaRef("#/components/schemas/"+g.define(t), nil)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return openapi3.NewBytesSchema().NewRef()
+		}
+		s := openapi3.NewArraySchema()
+		s.Items = g.schemaRef(t.Elem())
+		return s.NewRef()
+	case reflect.Map:
+		s := openapi3.NewObjectSchema()
+		s.AdditionalProperties = openapi3.AdditionalProperties{Schema: g.schemaRef(t.Elem())}
+		return s.NewRef()
+	}
+	if s := scalarSchema(t); s != nil {
+		return s.NewRef()
+	}
+	// Any value, e.g. a oneof field.
+	return openapi3.NewSchema().NewRef()
+}
+
+// define adds the schema of the struct t to the components and returns its
+// name, the package name is added to the names used by other types.
+func (g *openAPISchemas) define(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, ok := g.schemas[name]; ok {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	s := openapi3.NewObjectSchema()
+	// The name is set first for the recursive types.
+	g.names[t] = name
+	g.schemas[name] = s.NewRef()
+	for _, f := range jsonFields(t) {
+		s.Properties[f.name] = g.schemaRef(f.Type)
+	}
+	return name
+}
+
+// jsonField is a struct field with its JSON name.
+type jsonField struct {
+	reflect.StructField
+	name string
+}
+
+// jsonFields returns the fields of the struct t encoded by encoding/json.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{f, name})
+	}
+	return fields
+}
+
+// querySchema returns the schema of a query parameter of type t, or nil if
+// t is not a scalar or a list of scalars.
+func querySchema(t reflect.Type) *openapi3.Schema {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		if item := scalarSchema(t.Elem()); item != nil {
+			return openapi3.NewArraySchema().WithItems(item)
+		}
+		return nil
+	}
+	return scalarSchema(t)
+}
+
+func scalarSchema(t reflect.Type) *openapi3.Schema {
+	switch t.Kind() {
+	case reflect.String:
+		return openapi3.NewStringSchema()
+	case reflect.Bool:
+		return openapi3.NewBoolSchema()
+	case reflect.Int32, reflect.Uint32:
+		return openapi3.NewInt32Schema()
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return openapi3.NewInt64Schema()
+	case reflect.Float32:
+		return openapi3.NewFloat64Schema().WithFormat("float")
+	case reflect.Float64:
+		return openapi3.NewFloat64Schema()
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return openapi3.NewBytesSchema()
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 
 	"kcl-lang.io/kcl-go/pkg/3rdparty/grpc_gateway_util"
 	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/pkg/service/pool"
 	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
 	"kcl-lang.io/lib/go/api"
 )
@@ -22,18 +24,33 @@ var _ = fmt.Sprint
 
 // Client represents an restful method result.
 type RestfulResult struct {
-	Error  string        `json:"error"`
-	Result proto.Message `json:"result"`
+	Error string `json:"error"`
+	// Diagnostics are the structured KCL diagnostics of Error, if any.
+	Diagnostics []kcl.Diagnostic `json:"diagnostics,omitempty"`
+	Result      proto.Message    `json:"result"`
 }
 
 type restServer struct {
 	address string
 	router  *httprouter.Router
 	service api.ServiceClient
+	// redaction is applied to the ExecProgram responses, see kcl.WithRedaction.
+	redaction *kcl.RedactOptions
 }
 
-func RunRestServer(address string) error {
+// RunRestServer serves the KCL service at address, every method at
+// /api:protorpc/<service>.<method> and the OpenAPI document of the API at
+// /api:protorpc/openapi.json. The options configure the server, e.g.
+// kcl.WithService sets the service behind it and kcl.WithRedaction redacts
+// the ExecProgram responses.
+func RunRestServer(address string, opts ...kcl.Option) error {
+	opt := kcl.NewOption().Merge(opts...)
+	if opt.Err != nil {
+		return opt.Err
+	}
 	s := newRestServer(address)
+	s.service = opt.GetService()
+	s.redaction = opt.GetRedaction()
 	return s.Run()
 }
 
@@ -55,48 +72,56 @@ func (p *restServer) Run() error {
 	return http.ListenAndServe(p.address, p.router)
 }
 
+// restMethod is a method of the REST API, served at /api:protorpc/<name>
+// with GET query parameters or a POST JSON body.
+type restMethod struct {
+	name   string
+	args   proto.Message
+	result proto.Message
+	handle httprouter.Handle
+}
+
+func (p *restServer) methods() []restMethod {
+	return []restMethod{
+		{"BuiltinService.Ping", new(gpyrpc.PingArgs), new(gpyrpc.PingResult), p.handle_Ping},
+
+		{"KclService.Ping", new(gpyrpc.PingArgs), new(gpyrpc.PingResult), p.handle_Ping},
+		{"KclService.GetVersion", new(gpyrpc.GetVersionArgs), new(gpyrpc.GetVersionResult), p.handle_GetVersion},
+		{"KclService.ExecProgram", new(gpyrpc.ExecProgramArgs), new(gpyrpc.ExecProgramResult), p.handle_ExecProgram},
+		{"KclService.BuildProgram", new(gpyrpc.BuildProgramArgs), new(gpyrpc.BuildProgramResult), p.handle_BuildProgram},
+		{"KclService.ExecArtifact", new(gpyrpc.ExecArtifactArgs), new(gpyrpc.ExecProgramResult), p.handle_ExecArtifact},
+		{"KclService.ParseFile", new(gpyrpc.ParseFileArgs), new(gpyrpc.ParseFileResult), p.handle_ParseFile},
+		{"KclService.ParseProgram", new(gpyrpc.ParseProgramArgs), new(gpyrpc.ParseProgramResult), p.handle_ParseProgram},
+		{"KclService.ListOptions", new(gpyrpc.ParseProgramArgs), new(gpyrpc.ListOptionsResult), p.handle_ListOptions},
+		{"KclService.ListVariables", new(gpyrpc.ListVariablesArgs), new(gpyrpc.ListVariablesResult), p.handle_ListVariables},
+		{"KclService.LoadPackage", new(gpyrpc.LoadPackageArgs), new(gpyrpc.LoadPackageResult), p.handle_LoadPackage},
+		{"KclService.FormatCode", new(gpyrpc.FormatCodeArgs), new(gpyrpc.FormatCodeResult), p.handle_FormatCode},
+		{"KclService.FormatPath", new(gpyrpc.FormatPathArgs), new(gpyrpc.FormatPathResult), p.handle_FormatPath},
+		{"KclService.LintPath", new(gpyrpc.LintPathArgs), new(gpyrpc.LintPathResult), p.handle_LintPath},
+		{"KclService.OverrideFile", new(gpyrpc.OverrideFileArgs), new(gpyrpc.OverrideFileResult), p.handle_OverrideFile},
+		{"KclService.GetSchemaTypeMapping", new(gpyrpc.GetSchemaTypeMappingArgs), new(gpyrpc.GetSchemaTypeMappingResult), p.handle_GetSchemaTypeMapping},
+		{"KclService.ValidateCode", new(gpyrpc.ValidateCodeArgs), new(gpyrpc.ValidateCodeResult), p.handle_ValidateCode},
+		{"KclService.ListDepFiles", new(gpyrpc.ListDepFilesArgs), new(gpyrpc.ListDepFilesResult), p.handle_ListDepFiles},
+		{"KclService.LoadSettingsFiles", new(gpyrpc.LoadSettingsFilesArgs), new(gpyrpc.LoadSettingsFilesResult), p.handle_LoadSettingsFiles},
+		{"KclService.Rename", new(gpyrpc.RenameArgs), new(gpyrpc.RenameResult), p.handle_Rename},
+		{"KclService.RenameCode", new(gpyrpc.RenameCodeArgs), new(gpyrpc.RenameCodeResult), p.handle_RenameCode},
+		{"KclService.Test", new(gpyrpc.TestArgs), new(gpyrpc.TestResult), p.handle_Test},
+		{"KclService.UpdateDependencies", new(gpyrpc.UpdateDependenciesArgs), new(gpyrpc.UpdateDependenciesResult), p.handle_UpdateDependencies},
+	}
+}
+
 func (p *restServer) initHttpRrouter() {
-	p.router.GET("/api:protorpc/BuiltinService.Ping", p.handle_Ping)
-
-	p.router.GET("/api:protorpc/KclService.ExecProgram", p.handle_ExecProgram)
-	p.router.GET("/api:protorpc/KclService.ParseFile", p.handle_ParseFile)
-	p.router.GET("/api:protorpc/KclService.ParseProgram", p.handle_ParseProgram)
-	p.router.GET("/api:protorpc/KclService.ListOptions", p.handle_ListOptions)
-	p.router.GET("/api:protorpc/KclService.ListVariables", p.handle_ListVariables)
-	p.router.GET("/api:protorpc/KclService.LoadPackage", p.handle_LoadPackage)
-	p.router.GET("/api:protorpc/KclService.FormatCode", p.handle_FormatCode)
-	p.router.GET("/api:protorpc/KclService.FormatPath", p.handle_FormatPath)
-	p.router.GET("/api:protorpc/KclService.LintPath", p.handle_LintPath)
-	p.router.GET("/api:protorpc/KclService.OverrideFile", p.handle_OverrideFile)
-	p.router.GET("/api:protorpc/KclService.GetSchemaTypeMapping", p.handle_GetSchemaTypeMapping)
-	p.router.GET("/api:protorpc/KclService.ValidateCode", p.handle_ValidateCode)
-	p.router.GET("/api:protorpc/KclService.Rename", p.handle_Rename)
-	p.router.GET("/api:protorpc/KclService.RenameCode", p.handle_RenameCode)
-	p.router.GET("/api:protorpc/KclService.Test", p.handle_Test)
-	p.router.GET("/api:protorpc/KclService.UpdateDependencies", p.handle_UpdateDependencies)
-	p.router.GET("/api:protorpc/KclService.GetVersion", p.handle_GetVersion)
-
-	p.router.POST("/api:protorpc/BuiltinService.Ping", p.handle_Ping)
-
-	p.router.POST("/api:protorpc/KclService.ExecProgram", p.handle_ExecProgram)
-	p.router.POST("/api:protorpc/KclService.ParseFile", p.handle_ParseFile)
-	p.router.POST("/api:protorpc/KclService.ParseProgram", p.handle_ParseProgram)
-	p.router.POST("/api:protorpc/KclService.ListOptions", p.handle_ListOptions)
-	p.router.POST("/api:protorpc/KclService.ListVariables", p.handle_ListVariables)
-	p.router.POST("/api:protorpc/KclService.LoadPackage", p.handle_LoadPackage)
-	p.router.POST("/api:protorpc/KclService.FormatCode", p.handle_FormatCode)
-	p.router.POST("/api:protorpc/KclService.FormatPath", p.handle_FormatPath)
-	p.router.POST("/api:protorpc/KclService.LintPath", p.handle_LintPath)
-	p.router.POST("/api:protorpc/KclService.OverrideFile", p.handle_OverrideFile)
-	p.router.POST("/api:protorpc/KclService.GetSchemaTypeMapping", p.handle_GetSchemaTypeMapping)
-	p.router.POST("/api:protorpc/KclService.ValidateCode", p.handle_ValidateCode)
-	p.router.POST("/api:protorpc/KclService.Rename", p.handle_Rename)
-	p.router.POST("/api:protorpc/KclService.RenameCode", p.handle_RenameCode)
-	p.router.POST("/api:protorpc/KclService.Test", p.handle_Test)
-	p.router.POST("/api:protorpc/KclService.UpdateDependencies", p.handle_UpdateDependencies)
-	p.router.POST("/api:protorpc/KclService.GetVersion", p.handle_GetVersion)
+	for _, m := range p.methods() {
+		p.router.GET("/api:protorpc/"+m.name, m.handle)
+		p.router.POST("/api:protorpc/"+m.name, m.handle)
+	}
+	p.router.GET("/api:protorpc/openapi.json", p.handle_OpenAPI)
 }
 
+// handle decodes the arguments of a call, runs it and writes its result.
+// The failed calls get an error status with the diagnostics of the error:
+// 400 for invalid arguments, 422 for the KCL errors, including the results
+// with an error message or error diagnostics, 5xx for the service failures.
 func (p *restServer) handle(
 	w http.ResponseWriter, r *http.Request,
 	args proto.Message, fn func() (proto.Message, error),
@@ -104,32 +129,100 @@ func (p *restServer) handle(
 	switch r.Method {
 	case "GET":
 		if err := grpc_gateway_util.PopulateQueryParameters(args, r.URL.Query()); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeRestfulResult(w, http.StatusBadRequest, &RestfulResult{Error: err.Error()})
 			return
 		}
 	default:
 		if err := json.NewDecoder(r.Body).Decode(args); err != nil && err != io.EOF {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeRestfulResult(w, http.StatusBadRequest, &RestfulResult{Error: err.Error()})
 			return
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-
 	var result RestfulResult
+	status := http.StatusOK
 	if x, err := fn(); err != nil {
+		status = restErrorStatus(err)
 		result.Error = err.Error()
+		result.Diagnostics = restDiagnostics(err)
 	} else {
 		result.Result = x // OK
+		if kerr := resultError(x); kerr != nil {
+			status = http.StatusUnprocessableEntity
+			result.Error = kerr.Error()
+			result.Diagnostics = kerr.Diagnostics
+		}
 	}
+	writeRestfulResult(w, status, &result)
+}
 
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "\t")
-
-	if err := encoder.Encode(result); err != nil {
+func writeRestfulResult(w http.ResponseWriter, status int, result *RestfulResult) {
+	data, err := json.MarshalIndent(result, "", "\t")
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// restErrorStatus returns the HTTP status of an error of the service.
+func restErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pool.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, pool.ErrWorkerExited):
+		return http.StatusBadGateway
+	case errors.Is(err, pool.ErrClosed):
+		return http.StatusServiceUnavailable
+	case len(restDiagnostics(err)) > 0:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// restDiagnostics returns the KCL diagnostics of an error of the service,
+// or nil if it is not a KCL error.
+func restDiagnostics(err error) []kcl.Diagnostic {
+	var kerr *kcl.Error
+	if errors.As(err, &kerr) {
+		return kerr.Diagnostics
+	}
+	// A plain message is parsed into a single unknown diagnostic.
+	diags := kcl.NewError(err.Error()).Diagnostics
+	for _, d := range diags {
+		if d.Code != "" || d.Pos.IsValid() || d.Kind != kcl.ErrorKindUnknown {
+			return diags
+		}
+	}
+	return nil
+}
+
+// resultError returns the error reported by a result, e.g. the error message
+// of ExecProgramResult or the parse errors of ParseProgramResult. Warnings
+// alone are not an error.
+func resultError(x proto.Message) *kcl.Error {
+	if r, ok := x.(interface{ GetErrMessage() string }); ok && r.GetErrMessage() != "" {
+		return kcl.NewError(r.GetErrMessage())
+	}
+	var errs []*gpyrpc.Error
+	if r, ok := x.(interface{ GetErrors() []*gpyrpc.Error }); ok {
+		errs = append(errs, r.GetErrors()...)
+	}
+	if r, ok := x.(interface{ GetParseErrors() []*gpyrpc.Error }); ok {
+		errs = append(errs, r.GetParseErrors()...)
+	}
+	kerr := kcl.NewErrorFromSpec(errs...)
+	if kerr == nil {
+		return nil
+	}
+	for _, d := range kerr.Diagnostics {
+		if d.Level != "warning" {
+			return kerr
+		}
+	}
+	return nil
 }
 
 func (p *restServer) handle_Ping(w http.ResponseWriter, r *http.Request, _ps httprouter.Params) {
@@ -142,7 +235,39 @@ func (p *restServer) handle_Ping(w http.ResponseWriter, r *http.Request, _ps htt
 func (p *restServer) handle_ExecProgram(w http.ResponseWriter, r *http.Request, _ps httprouter.Params) {
 	var args = new(gpyrpc.ExecProgramArgs)
 	p.handle(w, r, args, func() (proto.Message, error) {
-		return p.service.ExecProgram(args)
+		result, err := p.service.ExecProgram(args)
+		if p.redaction == nil {
+			return result, err
+		}
+		if err != nil {
+			return nil, kcl.RedactError(*p.redaction, args, err)
+		}
+		return kcl.RedactExecResult(*p.redaction, args, result)
+	})
+}
+
+func (p *restServer) handle_BuildProgram(w http.ResponseWriter, r *http.Request, _ps httprouter.Params) {
+	var args = new(gpyrpc.BuildProgramArgs)
+	p.handle(w, r, args, func() (proto.Message, error) {
+		return p.service.BuildProgram(args)
+	})
+}
+
+func (p *restServer) handle_ExecArtifact(w http.ResponseWriter, r *http.Request, _ps httprouter.Params) {
+	var args = new(gpyrpc.ExecArtifactArgs)
+	p.handle(w, r, args, func() (proto.Message, error) {
+		result, err := p.service.ExecArtifact(args)
+		if p.redaction == nil {
+			return result, err
+		}
+		execArgs := args.ExecArgs
+		if execArgs == nil {
+			execArgs = new(gpyrpc.ExecProgramArgs)
+		}
+		if err != nil {
+			return nil, kcl.RedactError(*p.redaction, execArgs, err)
+		}
+		return kcl.RedactExecResult(*p.redaction, execArgs, result)
 	})
 }
 
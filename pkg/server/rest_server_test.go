@@ -0,0 +1,157 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"kcl-lang.io/kcl-go/pkg/kcl"
+	"kcl-lang.io/kcl-go/pkg/service/pool"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+const restTestErrMessage = `error[E2G22]: TypeError
+ --> main.k:1:1
+  |
+1 | a: int = "1"
+  | ^ expected int, got str("1")
+  |
+`
+
+// restService answers ExecProgram by file name, and ListDepFiles and
+// ParseFile.
+type restService struct {
+	api.ServiceClient
+}
+
+func (restService) ExecProgram(args *gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	switch args.KFilenameList[0] {
+	case "bad.k":
+		return &gpyrpc.ExecProgramResult{ErrMessage: restTestErrMessage}, nil
+	case "timeout.k":
+		return nil, fmt.Errorf("%w: ExecProgram after 1s", pool.ErrTimeout)
+	case "fail.k":
+		return nil, errors.New("boom")
+	}
+	return &gpyrpc.ExecProgramResult{JsonResult: `{"a": 1}`, YamlResult: "a: 1\n"}, nil
+}
+
+func (restService) ListDepFiles(*gpyrpc.ListDepFilesArgs) (*gpyrpc.ListDepFilesResult, error) {
+	return &gpyrpc.ListDepFilesResult{Pkgroot: "/app"}, nil
+}
+
+func (restService) ParseFile(*gpyrpc.ParseFileArgs) (*gpyrpc.ParseFileResult, error) {
+	return &gpyrpc.ParseFileResult{Errors: []*gpyrpc.Error{{
+		Level:    "warning",
+		Messages: []*gpyrpc.Message{{Msg: "unused import"}},
+	}}}, nil
+}
+
+// restResponse is a RestfulResult with a raw result.
+type restResponse struct {
+	Error       string           `json:"error"`
+	Diagnostics []kcl.Diagnostic `json:"diagnostics"`
+	Result      json.RawMessage  `json:"result"`
+}
+
+func serveRest(t *testing.T, method, url, body string) (int, *restResponse, []byte) {
+	t.Helper()
+	s := newRestServer(":0")
+	s.service = restService{}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+	var result restResponse
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, &result, w.Body.Bytes()
+}
+
+func TestRestServerStatus(t *testing.T) {
+	const url = "/api:protorpc/KclService.ExecProgram"
+	code, result, body := serveRest(t, "POST", url, `{"k_filename_list": ["main.k"]}`)
+	if code != http.StatusOK || result.Error != "" {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+
+	code, result, body = serveRest(t, "POST", url, `{"k_filename_list": ["bad.k"]}`)
+	if code != http.StatusUnprocessableEntity || result.Error != restTestErrMessage {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Code != "E2G22" || result.Diagnostics[0].Pos.Line != 1 {
+		t.Fatalf("unexpected diagnostics %+v", result.Diagnostics)
+	}
+	if !strings.Contains(string(body), `"err_message"`) {
+		t.Fatalf("expect the result with the error, got %s", body)
+	}
+
+	for file, status := range map[string]int{
+		"timeout.k": http.StatusGatewayTimeout,
+		"fail.k":    http.StatusInternalServerError,
+	} {
+		code, result, body = serveRest(t, "POST", url, `{"k_filename_list": ["`+file+`"]}`)
+		if code != status || result.Error == "" {
+			t.Fatalf("%s: unexpected response %d %s", file, code, body)
+		}
+	}
+
+	code, result, body = serveRest(t, "POST", url, `{"k_filename_list": 1}`)
+	if code != http.StatusBadRequest || result.Error == "" {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+
+	// The warnings alone do not fail the call.
+	code, _, body = serveRest(t, "POST", "/api:protorpc/KclService.ParseFile", `{}`)
+	if code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	code, _, body = serveRest(t, "GET", "/api:protorpc/KclService.ListDepFiles", "")
+	if code != http.StatusOK || !strings.Contains(string(body), `"/app"`) {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+}
+
+func TestRestServerOpenAPI(t *testing.T) {
+	code, _, body := serveRest(t, "GET", "/api:protorpc/openapi.json", "")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", code, body)
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newRestServer(":0")
+	if doc.Paths.Len() != len(s.methods()) {
+		t.Fatalf("expect %d paths, got %d", len(s.methods()), doc.Paths.Len())
+	}
+	item := doc.Paths.Value("/api:protorpc/KclService.ExecProgram")
+	if item == nil || item.Post == nil || item.Get == nil {
+		t.Fatal("expect the ExecProgram operations")
+	}
+	args := doc.Components.Schemas["ExecProgramArgs"]
+	if args == nil || args.Value.Properties["k_filename_list"] == nil {
+		t.Fatalf("expect the ExecProgramArgs schema, got %v", args)
+	}
+	if doc.Components.Schemas["Diagnostic"] == nil {
+		t.Fatal("expect the Diagnostic schema")
+	}
+	if item.Post.Responses.Value("422") == nil {
+		t.Fatal("expect the 422 response")
+	}
+}
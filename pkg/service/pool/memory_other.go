@@ -0,0 +1,12 @@
+// Copyright The KCL Authors. All rights reserved.
+
+//go:build !linux
+
+package pool
+
+import "errors"
+
+// processMemory is not supported on this platform.
+func processMemory(pid int) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,461 @@
+// Copyright The KCL Authors. All rights reserved.
+
+// Package pool provides a KCL service which runs the calls in worker
+// subprocesses, so that a panic or an abort of the native KCL runtime only
+// takes down a worker instead of the whole process.
+//
+//	p, err := pool.New(pool.Options{Workers: 4, Timeout: time.Minute})
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//	kcl.SetDefaultService(p)
+//
+// The calls of p.WithContext(ctx) kill their worker when ctx is done, the
+// context-aware APIs of kcl-lang.io/kcl-go/pkg/kcl bind the pool to their
+// context this way, see kcl.ContextService.
+//
+// The workers run the kcl-worker binary, see kcl-lang.io/kcl-go/cmd/kcl-worker,
+// or any binary which calls Serve, e.g. to register KCL plugins.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// DefaultWorker is the worker binary run by default, it is looked up in PATH.
+const DefaultWorker = "kcl-worker"
+
+// stopTimeout is the time a recycled worker has to exit before it is killed.
+const stopTimeout = 5 * time.Second
+
+var (
+	// ErrClosed is returned by the calls of a closed Pool.
+	ErrClosed = errors.New("pool: closed")
+	// ErrTimeout is returned by the calls which exceed Options.Timeout.
+	ErrTimeout = errors.New("pool: request timed out")
+	// ErrWorkerExited is returned by the calls whose worker crashed.
+	ErrWorkerExited = errors.New("worker exited")
+)
+
+// Options configures a Pool.
+type Options struct {
+	// Command returns the command of a new worker, by default DefaultWorker
+	// with the standard error of the current process.
+	Command func() *exec.Cmd
+	// Workers is the number of workers, runtime.NumCPU() by default.
+	Workers int
+	// Timeout is the maximum duration of a call, the worker of a call which
+	// exceeds it is killed. Zero means no timeout.
+	Timeout time.Duration
+	// MaxRequests is the number of calls after which a worker is replaced.
+	// Zero means no limit.
+	MaxRequests int
+	// MaxMemory is the resident memory in bytes above which a worker is
+	// replaced after a call. Zero means no limit. It is only supported on
+	// Linux.
+	MaxMemory uint64
+}
+
+// worker is a running worker process.
+type worker struct {
+	client   api.ServiceClient
+	pid      int
+	done     chan struct{} // closed when the process exits
+	err      error         // the exit error, set before done is closed
+	requests int
+	kill     func() // kills the process
+	stop     func() // asks the process to exit, then kills it after stopTimeout
+}
+
+func (w *worker) exited() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Pool is an api.ServiceClient which dispatches the calls to a set of worker
+// processes. Crashed workers are restarted, and the workers are replaced
+// after Options.MaxRequests calls or above Options.MaxMemory. It is safe
+// for concurrent use.
+type Pool struct {
+	*state
+	ctx context.Context // the context of the calls, see WithContext
+}
+
+// state is the state of a Pool shared with the pools returned by WithContext.
+type state struct {
+	opts   Options
+	start  func() (*worker, error)
+	memory func(pid int) (uint64, error)
+
+	slots  chan struct{} // a token per busy worker
+	closed chan struct{}
+
+	mu   sync.Mutex
+	idle []*worker
+	shut bool
+}
+
+var _ api.ServiceClient = (*Pool)(nil)
+
+// New starts a Pool of opts.Workers workers.
+func New(opts Options) (*Pool, error) {
+	p := newPool(opts, nil)
+	for i := 0; i < p.opts.Workers; i++ {
+		w, err := p.start()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.idle = append(p.idle, w)
+	}
+	return p, nil
+}
+
+// newPool returns a Pool without workers, start defaults to startProcess.
+func newPool(opts Options, start func() (*worker, error)) *Pool {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.Command == nil {
+		opts.Command = func() *exec.Cmd {
+			cmd := exec.Command(DefaultWorker)
+			cmd.Stderr = os.Stderr
+			return cmd
+		}
+	}
+	p := &Pool{state: &state{
+		opts:   opts,
+		start:  start,
+		memory: processMemory,
+		slots:  make(chan struct{}, opts.Workers),
+		closed: make(chan struct{}),
+	}}
+	if p.start == nil {
+		p.start = p.startProcess
+	}
+	return p
+}
+
+// WithContext returns the pool with the context of its calls: a call waiting
+// for a worker returns when ctx is done, and a running call kills its worker.
+// The error of such a call wraps ctx.Err(). The returned pool shares the
+// workers of p.
+func (p *Pool) WithContext(ctx context.Context) api.ServiceClient {
+	return &Pool{state: p.state, ctx: ctx}
+}
+
+// context returns the context of the calls, context.Background() by default.
+func (p *Pool) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// Close stops the idle workers, the busy ones are stopped at the end of
+// their call. The calls after Close return ErrClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.shut {
+		p.mu.Unlock()
+		return nil
+	}
+	p.shut = true
+	idle := p.idle
+	p.idle = nil
+	close(p.closed)
+	p.mu.Unlock()
+
+	for _, w := range idle {
+		w.stop()
+	}
+	return nil
+}
+
+// startProcess starts a worker process talking protorpc over its stdio.
+func (p *Pool) startProcess() (*worker, error) {
+	cmd := p.opts.Command()
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("pool: start worker: %w", err)
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, fmt.Errorf("pool: start worker: %w", err)
+	}
+	cmd.Stdin, cmd.Stdout = stdinR, stdoutW
+	err = cmd.Start()
+	stdinR.Close()
+	stdoutW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return nil, fmt.Errorf("pool: start worker: %w", err)
+	}
+
+	client := gpyrpc.PROTORPC_NewKclServiceClient(&pipeConn{r: stdoutR, w: stdinW})
+	w := &worker{
+		client: client,
+		pid:    cmd.Process.Pid,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		w.err = cmd.Wait()
+		client.Close()
+		close(w.done)
+	}()
+	w.kill = func() {
+		cmd.Process.Kill()
+	}
+	w.stop = func() {
+		// The worker exits at the end of its stdin.
+		client.Close()
+		time.AfterFunc(stopTimeout, func() {
+			if !w.exited() {
+				cmd.Process.Kill()
+			}
+		})
+	}
+	return w, nil
+}
+
+// acquire returns an idle worker, or starts one, when a slot is free.
+func (p *Pool) acquire() (*worker, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.closed:
+		return nil, ErrClosed
+	case <-p.context().Done():
+		return nil, p.context().Err()
+	}
+	p.mu.Lock()
+	if p.shut {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, ErrClosed
+	}
+	for len(p.idle) > 0 {
+		w := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if !w.exited() {
+			p.mu.Unlock()
+			return w, nil
+		}
+		// The worker crashed while idle, it is replaced below.
+	}
+	p.mu.Unlock()
+
+	w, err := p.start()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return w, nil
+}
+
+// release returns the worker of a call to the idle ones, or stops it if it
+// failed, is due for recycling or the pool is closed.
+func (p *Pool) release(w *worker, healthy bool) {
+	defer func() { <-p.slots }()
+	if !healthy {
+		w.kill()
+		return
+	}
+	w.requests++
+	if !p.recycle(w) {
+		p.mu.Lock()
+		if !p.shut {
+			p.idle = append(p.idle, w)
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+	}
+	w.stop()
+}
+
+// recycle reports whether the worker reached Options.MaxRequests or
+// Options.MaxMemory.
+func (p *Pool) recycle(w *worker) bool {
+	if p.opts.MaxRequests > 0 && w.requests >= p.opts.MaxRequests {
+		return true
+	}
+	if p.opts.MaxMemory > 0 {
+		if n, err := p.memory(w.pid); err == nil && n >= p.opts.MaxMemory {
+			return true
+		}
+	}
+	return false
+}
+
+// call runs a call of method on a worker, with the timeout and the context
+// of the pool.
+func call[Args, Result any](p *Pool, method string, in *Args, fn func(api.ServiceClient, *Args) (*Result, error)) (*Result, error) {
+	w, err := p.acquire()
+	if err != nil {
+		return nil, fmt.Errorf("pool: %s: %w", method, err)
+	}
+
+	type reply struct {
+		result *Result
+		err    error
+	}
+	replies := make(chan reply, 1)
+	go func() {
+		result, err := fn(w.client, in)
+		replies <- reply{result, err}
+	}()
+	var timeout <-chan time.Time
+	if p.opts.Timeout > 0 {
+		timer := time.NewTimer(p.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case r := <-replies:
+		// The errors of the service itself leave the worker usable, the
+		// other ones come from a broken connection.
+		var serverErr rpc.ServerError
+		if r.err == nil || errors.As(r.err, &serverErr) {
+			p.release(w, true)
+			return r.result, r.err
+		}
+		p.release(w, false)
+		return nil, fmt.Errorf("pool: %s: %w: %v", method, ErrWorkerExited, r.err)
+	case <-w.done:
+		p.release(w, false)
+		return nil, fmt.Errorf("pool: %s: %w: %v", method, ErrWorkerExited, w.err)
+	case <-timeout:
+		p.release(w, false)
+		return nil, fmt.Errorf("%w: %s after %v", ErrTimeout, method, p.opts.Timeout)
+	case <-p.context().Done():
+		p.release(w, false)
+		return nil, fmt.Errorf("pool: %s: %w", method, p.context().Err())
+	}
+}
+
+// pipeConn is the connection to a worker over a pair of pipes.
+type pipeConn struct {
+	r io.ReadCloser
+	w io.WriteCloser
+}
+
+func (c *pipeConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *pipeConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+func (c *pipeConn) Close() error {
+	return errors.Join(c.w.Close(), c.r.Close())
+}
+
+func (p *Pool) Ping(in *gpyrpc.PingArgs) (*gpyrpc.PingResult, error) {
+	return call(p, "Ping", in, api.ServiceClient.Ping)
+}
+
+func (p *Pool) GetVersion(in *gpyrpc.GetVersionArgs) (*gpyrpc.GetVersionResult, error) {
+	return call(p, "GetVersion", in, api.ServiceClient.GetVersion)
+}
+
+func (p *Pool) ParseFile(in *gpyrpc.ParseFileArgs) (*gpyrpc.ParseFileResult, error) {
+	return call(p, "ParseFile", in, api.ServiceClient.ParseFile)
+}
+
+func (p *Pool) ParseProgram(in *gpyrpc.ParseProgramArgs) (*gpyrpc.ParseProgramResult, error) {
+	return call(p, "ParseProgram", in, api.ServiceClient.ParseProgram)
+}
+
+func (p *Pool) ListOptions(in *gpyrpc.ParseProgramArgs) (*gpyrpc.ListOptionsResult, error) {
+	return call(p, "ListOptions", in, api.ServiceClient.ListOptions)
+}
+
+func (p *Pool) ListVariables(in *gpyrpc.ListVariablesArgs) (*gpyrpc.ListVariablesResult, error) {
+	return call(p, "ListVariables", in, api.ServiceClient.ListVariables)
+}
+
+func (p *Pool) LoadPackage(in *gpyrpc.LoadPackageArgs) (*gpyrpc.LoadPackageResult, error) {
+	return call(p, "LoadPackage", in, api.ServiceClient.LoadPackage)
+}
+
+func (p *Pool) ExecProgram(in *gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	return call(p, "ExecProgram", in, api.ServiceClient.ExecProgram)
+}
+
+func (p *Pool) BuildProgram(in *gpyrpc.BuildProgramArgs) (*gpyrpc.BuildProgramResult, error) {
+	return call(p, "BuildProgram", in, api.ServiceClient.BuildProgram)
+}
+
+func (p *Pool) ExecArtifact(in *gpyrpc.ExecArtifactArgs) (*gpyrpc.ExecProgramResult, error) {
+	return call(p, "ExecArtifact", in, api.ServiceClient.ExecArtifact)
+}
+
+func (p *Pool) OverrideFile(in *gpyrpc.OverrideFileArgs) (*gpyrpc.OverrideFileResult, error) {
+	return call(p, "OverrideFile", in, api.ServiceClient.OverrideFile)
+}
+
+func (p *Pool) GetSchemaTypeMapping(in *gpyrpc.GetSchemaTypeMappingArgs) (*gpyrpc.GetSchemaTypeMappingResult, error) {
+	return call(p, "GetSchemaTypeMapping", in, api.ServiceClient.GetSchemaTypeMapping)
+}
+
+func (p *Pool) FormatCode(in *gpyrpc.FormatCodeArgs) (*gpyrpc.FormatCodeResult, error) {
+	return call(p, "FormatCode", in, api.ServiceClient.FormatCode)
+}
+
+func (p *Pool) FormatPath(in *gpyrpc.FormatPathArgs) (*gpyrpc.FormatPathResult, error) {
+	return call(p, "FormatPath", in, api.ServiceClient.FormatPath)
+}
+
+func (p *Pool) LintPath(in *gpyrpc.LintPathArgs) (*gpyrpc.LintPathResult, error) {
+	return call(p, "LintPath", in, api.ServiceClient.LintPath)
+}
+
+func (p *Pool) ValidateCode(in *gpyrpc.ValidateCodeArgs) (*gpyrpc.ValidateCodeResult, error) {
+	return call(p, "ValidateCode", in, api.ServiceClient.ValidateCode)
+}
+
+func (p *Pool) ListDepFiles(in *gpyrpc.ListDepFilesArgs) (*gpyrpc.ListDepFilesResult, error) {
+	return call(p, "ListDepFiles", in, api.ServiceClient.ListDepFiles)
+}
+
+func (p *Pool) LoadSettingsFiles(in *gpyrpc.LoadSettingsFilesArgs) (*gpyrpc.LoadSettingsFilesResult, error) {
+	return call(p, "LoadSettingsFiles", in, api.ServiceClient.LoadSettingsFiles)
+}
+
+func (p *Pool) Rename(in *gpyrpc.RenameArgs) (*gpyrpc.RenameResult, error) {
+	return call(p, "Rename", in, api.ServiceClient.Rename)
+}
+
+func (p *Pool) RenameCode(in *gpyrpc.RenameCodeArgs) (*gpyrpc.RenameCodeResult, error) {
+	return call(p, "RenameCode", in, api.ServiceClient.RenameCode)
+}
+
+func (p *Pool) Test(in *gpyrpc.TestArgs) (*gpyrpc.TestResult, error) {
+	return call(p, "Test", in, api.ServiceClient.Test)
+}
+
+func (p *Pool) UpdateDependencies(in *gpyrpc.UpdateDependenciesArgs) (*gpyrpc.UpdateDependenciesResult, error) {
+	return call(p, "UpdateDependencies", in, api.ServiceClient.UpdateDependencies)
+}
@@ -0,0 +1,347 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// workerEnv makes the test binary a worker serving processService, see
+// TestPoolProcess.
+const workerEnv = "KCL_POOL_TEST_WORKER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(workerEnv) == "1" {
+		if err := Serve(processService{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// processService answers ExecProgram in a worker process with its pid, it
+// exits for the "crash" work dir and hangs for the "hang" one.
+type processService struct {
+	api.ServiceClient
+}
+
+func (processService) ExecProgram(args *gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	switch args.WorkDir {
+	case "crash":
+		os.Exit(2)
+	case "hang":
+		time.Sleep(time.Hour)
+	}
+	// The prints of the programs do not corrupt the protocol.
+	fmt.Println("hello")
+	return &gpyrpc.ExecProgramResult{JsonResult: strconv.Itoa(os.Getpid())}, nil
+}
+
+// fakeClient answers ExecProgram with the handler of its fake pool.
+type fakeClient struct {
+	api.ServiceClient
+	w      *worker
+	handle func(w *worker) (*gpyrpc.ExecProgramResult, error)
+}
+
+func (c *fakeClient) ExecProgram(*gpyrpc.ExecProgramArgs) (*gpyrpc.ExecProgramResult, error) {
+	return c.handle(c.w)
+}
+
+// newFakePool returns a pool of in-process workers numbered from 1, the
+// handler answers with the worker number by default.
+func newFakePool(opts Options, handle func(w *worker) (*gpyrpc.ExecProgramResult, error)) (*Pool, *atomic.Int32) {
+	if handle == nil {
+		handle = func(w *worker) (*gpyrpc.ExecProgramResult, error) {
+			return &gpyrpc.ExecProgramResult{JsonResult: strconv.Itoa(w.pid)}, nil
+		}
+	}
+	var started atomic.Int32
+	p := newPool(opts, func() (*worker, error) {
+		w := &worker{pid: int(started.Add(1)), done: make(chan struct{})}
+		var once sync.Once
+		w.kill = func() {
+			once.Do(func() {
+				w.err = errors.New("signal: killed")
+				close(w.done)
+			})
+		}
+		w.stop = w.kill
+		w.client = &fakeClient{w: w, handle: handle}
+		return w, nil
+	})
+	return p, &started
+}
+
+func execPid(t *testing.T, p *Pool) int {
+	t.Helper()
+	result, err := p.ExecProgram(&gpyrpc.ExecProgramArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, _ := strconv.Atoi(result.JsonResult)
+	return pid
+}
+
+func TestPoolDispatch(t *testing.T) {
+	var busy, maxBusy atomic.Int32
+	p, started := newFakePool(Options{Workers: 2}, func(w *worker) (*gpyrpc.ExecProgramResult, error) {
+		n := busy.Add(1)
+		defer busy.Add(-1)
+		for {
+			m := maxBusy.Load()
+			if n <= m || maxBusy.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return &gpyrpc.ExecProgramResult{}, nil
+	})
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.ExecProgram(&gpyrpc.ExecProgramArgs{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxBusy.Load() > 2 || started.Load() > 2 {
+		t.Fatalf("expect at most 2 workers, got %d busy and %d started", maxBusy.Load(), started.Load())
+	}
+}
+
+func TestPoolCrash(t *testing.T) {
+	p, started := newFakePool(Options{Workers: 1}, func(w *worker) (*gpyrpc.ExecProgramResult, error) {
+		if w.pid == 1 {
+			w.kill()
+			return nil, rpc.ErrShutdown
+		}
+		return &gpyrpc.ExecProgramResult{JsonResult: strconv.Itoa(w.pid)}, nil
+	})
+	defer p.Close()
+
+	_, err := p.ExecProgram(&gpyrpc.ExecProgramArgs{})
+	if !errors.Is(err, ErrWorkerExited) {
+		t.Fatalf("expect a worker exit error, got %v", err)
+	}
+	if pid := execPid(t, p); pid != 2 {
+		t.Fatalf("expect the restarted worker 2, got %d", pid)
+	}
+
+	// A worker which dies while idle is replaced too.
+	p.mu.Lock()
+	p.idle[0].kill()
+	p.mu.Unlock()
+	if pid := execPid(t, p); pid != 3 || started.Load() != 3 {
+		t.Fatalf("expect the restarted worker 3, got %d", pid)
+	}
+}
+
+func TestPoolServiceError(t *testing.T) {
+	p, started := newFakePool(Options{Workers: 1}, func(w *worker) (*gpyrpc.ExecProgramResult, error) {
+		return nil, rpc.ServerError("file not found")
+	})
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := p.ExecProgram(&gpyrpc.ExecProgramArgs{})
+		if err == nil || err.Error() != "file not found" {
+			t.Fatalf("expect the service error, got %v", err)
+		}
+	}
+	if started.Load() != 1 {
+		t.Fatalf("expect the worker to be reused, got %d workers", started.Load())
+	}
+}
+
+func TestPoolTimeout(t *testing.T) {
+	p, _ := newFakePool(Options{Workers: 1, Timeout: 20 * time.Millisecond}, func(w *worker) (*gpyrpc.ExecProgramResult, error) {
+		if w.pid == 1 {
+			<-w.done
+			return nil, rpc.ErrShutdown
+		}
+		return &gpyrpc.ExecProgramResult{JsonResult: strconv.Itoa(w.pid)}, nil
+	})
+	defer p.Close()
+
+	_, err := p.ExecProgram(&gpyrpc.ExecProgramArgs{})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expect a timeout error, got %v", err)
+	}
+	if pid := execPid(t, p); pid != 2 {
+		t.Fatalf("expect the killed worker to be replaced, got %d", pid)
+	}
+}
+
+func TestPoolContext(t *testing.T) {
+	p, _ := newFakePool(Options{Workers: 1}, func(w *worker) (*gpyrpc.ExecProgramResult, error) {
+		if w.pid == 1 {
+			<-w.done
+			return nil, rpc.ErrShutdown
+		}
+		return &gpyrpc.ExecProgramResult{JsonResult: strconv.Itoa(w.pid)}, nil
+	})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := p.WithContext(ctx).ExecProgram(&gpyrpc.ExecProgramArgs{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect a deadline error, got %v", err)
+	}
+	if pid := execPid(t, p); pid != 2 {
+		t.Fatalf("expect the killed worker to be replaced, got %d", pid)
+	}
+
+	// A call waiting for a worker returns when its context is done.
+	p.slots <- struct{}{}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = p.WithContext(ctx).ExecProgram(&gpyrpc.ExecProgramArgs{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect a cancelled call, got %v", err)
+	}
+	<-p.slots
+}
+
+func TestPoolRecycle(t *testing.T) {
+	p, _ := newFakePool(Options{Workers: 1, MaxRequests: 2}, nil)
+	defer p.Close()
+
+	var pids []int
+	for i := 0; i < 5; i++ {
+		pids = append(pids, execPid(t, p))
+	}
+	if want := []int{1, 1, 2, 2, 3}; !slices.Equal(pids, want) {
+		t.Fatalf("expect the workers %v, got %v", want, pids)
+	}
+
+	p, _ = newFakePool(Options{Workers: 1, MaxMemory: 1 << 20}, nil)
+	defer p.Close()
+	p.memory = func(pid int) (uint64, error) {
+		if pid == 1 {
+			return 2 << 20, nil
+		}
+		return 1 << 10, nil
+	}
+	pids = nil
+	for i := 0; i < 3; i++ {
+		pids = append(pids, execPid(t, p))
+	}
+	if want := []int{1, 2, 2}; !slices.Equal(pids, want) {
+		t.Fatalf("expect the workers %v, got %v", want, pids)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p, _ := newFakePool(Options{Workers: 1}, nil)
+	execPid(t, p)
+	p.mu.Lock()
+	w := p.idle[0]
+	p.mu.Unlock()
+
+	p.Close()
+	if !w.exited() {
+		t.Fatal("expect the idle worker to be stopped")
+	}
+	if _, err := p.ExecProgram(&gpyrpc.ExecProgramArgs{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expect a closed error, got %v", err)
+	}
+}
+
+func TestProcessMemory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only supported on Linux")
+	}
+	n, err := processMemory(os.Getpid())
+	if err != nil || n == 0 {
+		t.Fatal(n, err)
+	}
+}
+
+func TestPoolProcess(t *testing.T) {
+	if err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+		_, err = proto.Marshal(&gpyrpc.ExecProgramArgs{})
+		return err
+	}(); err != nil {
+		t.Skipf("the messages can not be marshaled: %v", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(Options{
+		Workers: 1,
+		Timeout: 2 * time.Second,
+		Command: func() *exec.Cmd {
+			cmd := exec.Command(exe, "-test.run=^$")
+			cmd.Env = append(os.Environ(), workerEnv+"=1")
+			cmd.Stderr = os.Stderr
+			return cmd
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	run := func(dir string) (int, error) {
+		result, err := p.ExecProgram(&gpyrpc.ExecProgramArgs{WorkDir: dir})
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(result.JsonResult)
+	}
+	pid, err := run("")
+	if err != nil || pid == os.Getpid() {
+		t.Fatalf("expect a worker pid, got %d %v", pid, err)
+	}
+	if again, err := run(""); err != nil || again != pid {
+		t.Fatalf("expect the worker %d to be reused, got %d %v", pid, again, err)
+	}
+
+	if _, err := run("crash"); !errors.Is(err, ErrWorkerExited) {
+		t.Fatalf("expect a worker exit error, got %v", err)
+	}
+	restarted, err := run("")
+	if err != nil || restarted == pid {
+		t.Fatalf("expect a new worker, got %d %v", restarted, err)
+	}
+
+	if _, err := run("hang"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expect a timeout error, got %v", err)
+	}
+	// The hanging worker is killed and replaced.
+	if pid, err := run(""); err != nil || pid == restarted {
+		t.Fatalf("expect a new worker, got %d %v", pid, err)
+	}
+}
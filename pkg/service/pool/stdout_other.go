@@ -0,0 +1,13 @@
+// Copyright The KCL Authors. All rights reserved.
+
+//go:build !unix
+
+package pool
+
+import "os"
+
+// redirectStdout returns the standard output, which is not redirected on
+// this platform.
+func redirectStdout() (*os.File, error) {
+	return os.Stdout, nil
+}
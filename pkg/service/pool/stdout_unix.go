@@ -0,0 +1,25 @@
+// Copyright The KCL Authors. All rights reserved.
+
+//go:build unix
+
+package pool
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// redirectStdout points the standard output to the standard error and
+// returns a file of the original standard output.
+func redirectStdout() (*os.File, error) {
+	fd, err := unix.Dup(int(os.Stdout.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Dup2(int(os.Stderr.Fd()), int(os.Stdout.Fd())); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "stdout"), nil
+}
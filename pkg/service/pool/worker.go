@@ -0,0 +1,142 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package pool
+
+import (
+	"net/rpc"
+	"os"
+
+	"github.com/chai2010/protorpc"
+	"google.golang.org/protobuf/proto"
+
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+	"kcl-lang.io/lib/go/api"
+)
+
+// Serve serves svc over the standard input and output for a Pool and
+// returns when the pool closes the standard input. It is the main function
+// of a worker binary, see kcl-lang.io/kcl-go/cmd/kcl-worker. The standard
+// output is redirected to the standard error, so the prints of the KCL
+// programs do not corrupt the protocol.
+func Serve(svc api.ServiceClient) error {
+	stdout, err := redirectStdout()
+	if err != nil {
+		return err
+	}
+	srv := rpc.NewServer()
+	if err := gpyrpc.PROTORPC_RegisterKclService(srv, &workerService{svc: svc}); err != nil {
+		return err
+	}
+	srv.ServeCodec(protorpc.NewServerCodec(&pipeConn{r: os.Stdin, w: stdout}))
+	return nil
+}
+
+// workerService adapts an api.ServiceClient to gpyrpc.PROTORPC_KclService.
+type workerService struct {
+	svc api.ServiceClient
+}
+
+var _ gpyrpc.PROTORPC_KclService = (*workerService)(nil)
+
+// serve calls fn with in and stores its result in out.
+func serve[Args, Result any, Out interface {
+	*Result
+	proto.Message
+}](fn func(*Args) (*Result, error), in *Args, out Out) error {
+	result, err := fn(in)
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		proto.Merge(out, Out(result))
+	}
+	return nil
+}
+
+func (s *workerService) Ping(in *gpyrpc.PingArgs, out *gpyrpc.PingResult) error {
+	return serve(s.svc.Ping, in, out)
+}
+
+func (s *workerService) GetVersion(in *gpyrpc.GetVersionArgs, out *gpyrpc.GetVersionResult) error {
+	return serve(s.svc.GetVersion, in, out)
+}
+
+func (s *workerService) ParseFile(in *gpyrpc.ParseFileArgs, out *gpyrpc.ParseFileResult) error {
+	return serve(s.svc.ParseFile, in, out)
+}
+
+func (s *workerService) ParseProgram(in *gpyrpc.ParseProgramArgs, out *gpyrpc.ParseProgramResult) error {
+	return serve(s.svc.ParseProgram, in, out)
+}
+
+func (s *workerService) ListOptions(in *gpyrpc.ParseProgramArgs, out *gpyrpc.ListOptionsResult) error {
+	return serve(s.svc.ListOptions, in, out)
+}
+
+func (s *workerService) ListVariables(in *gpyrpc.ListVariablesArgs, out *gpyrpc.ListVariablesResult) error {
+	return serve(s.svc.ListVariables, in, out)
+}
+
+func (s *workerService) LoadPackage(in *gpyrpc.LoadPackageArgs, out *gpyrpc.LoadPackageResult) error {
+	return serve(s.svc.LoadPackage, in, out)
+}
+
+func (s *workerService) ExecProgram(in *gpyrpc.ExecProgramArgs, out *gpyrpc.ExecProgramResult) error {
+	return serve(s.svc.ExecProgram, in, out)
+}
+
+func (s *workerService) BuildProgram(in *gpyrpc.BuildProgramArgs, out *gpyrpc.BuildProgramResult) error {
+	return serve(s.svc.BuildProgram, in, out)
+}
+
+func (s *workerService) ExecArtifact(in *gpyrpc.ExecArtifactArgs, out *gpyrpc.ExecProgramResult) error {
+	return serve(s.svc.ExecArtifact, in, out)
+}
+
+func (s *workerService) OverrideFile(in *gpyrpc.OverrideFileArgs, out *gpyrpc.OverrideFileResult) error {
+	return serve(s.svc.OverrideFile, in, out)
+}
+
+func (s *workerService) GetSchemaTypeMapping(in *gpyrpc.GetSchemaTypeMappingArgs, out *gpyrpc.GetSchemaTypeMappingResult) error {
+	return serve(s.svc.GetSchemaTypeMapping, in, out)
+}
+
+func (s *workerService) FormatCode(in *gpyrpc.FormatCodeArgs, out *gpyrpc.FormatCodeResult) error {
+	return serve(s.svc.FormatCode, in, out)
+}
+
+func (s *workerService) FormatPath(in *gpyrpc.FormatPathArgs, out *gpyrpc.FormatPathResult) error {
+	return serve(s.svc.FormatPath, in, out)
+}
+
+func (s *workerService) LintPath(in *gpyrpc.LintPathArgs, out *gpyrpc.LintPathResult) error {
+	return serve(s.svc.LintPath, in, out)
+}
+
+func (s *workerService) ValidateCode(in *gpyrpc.ValidateCodeArgs, out *gpyrpc.ValidateCodeResult) error {
+	return serve(s.svc.ValidateCode, in, out)
+}
+
+func (s *workerService) ListDepFiles(in *gpyrpc.ListDepFilesArgs, out *gpyrpc.ListDepFilesResult) error {
+	return serve(s.svc.ListDepFiles, in, out)
+}
+
+func (s *workerService) LoadSettingsFiles(in *gpyrpc.LoadSettingsFilesArgs, out *gpyrpc.LoadSettingsFilesResult) error {
+	return serve(s.svc.LoadSettingsFiles, in, out)
+}
+
+func (s *workerService) Rename(in *gpyrpc.RenameArgs, out *gpyrpc.RenameResult) error {
+	return serve(s.svc.Rename, in, out)
+}
+
+func (s *workerService) RenameCode(in *gpyrpc.RenameCodeArgs, out *gpyrpc.RenameCodeResult) error {
+	return serve(s.svc.RenameCode, in, out)
+}
+
+func (s *workerService) Test(in *gpyrpc.TestArgs, out *gpyrpc.TestResult) error {
+	return serve(s.svc.Test, in, out)
+}
+
+func (s *workerService) UpdateDependencies(in *gpyrpc.UpdateDependenciesArgs, out *gpyrpc.UpdateDependenciesResult) error {
+	return serve(s.svc.UpdateDependencies, in, out)
+}
@@ -3,6 +3,9 @@
 package settings
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -49,3 +52,115 @@ func tAssert(t *testing.T, ok bool, a ...any) {
 		t.Fatal(a...)
 	}
 }
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	prod := filepath.Join(dir, "prod.yaml")
+	if err := os.WriteFile(base, []byte(`
+kcl_cli_configs:
+  file: [main.k]
+  output: base.yaml
+  disable_none: true
+  package_maps:
+    k8s: ./k8s
+kcl_options:
+  - key: env
+    value: dev
+profiles:
+  ci:
+    kcl_cli_configs:
+      sort_keys: true
+    kcl_options:
+      - key: ci
+        value: true
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(prod, []byte(`
+kcl_cli_configs:
+  file: [prod.k]
+  output: prod.json
+  disable_none: false
+kcl_options:
+  - key: env
+    value: prod
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := LoadFiles([]string{base, prod}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, f.Filename == prod, f.Filename)
+	tAssert(t, reflect.DeepEqual(f.Config.InputFile, []string{"main.k", "prod.k"}), f.Config.InputFile)
+	tAssert(t, f.Config.Output == "prod.json", f.Config.Output)
+	tAssert(t, !f.Config.DisableNone && !f.Config.SortKeys)
+	tAssert(t, f.Config.PackageMaps["k8s"] == "./k8s", f.Config.PackageMaps)
+	args := f.To_ExecProgramArgs()
+	tAssert(t, len(args.Args) == 2 && args.Args[1].Value == `"prod"`, args.Args)
+
+	f, err = LoadFiles([]string{base, prod}, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, f.Config.SortKeys && f.Config.Output == "prod.json")
+	args = f.To_ExecProgramArgs()
+	tAssert(t, len(args.Args) == 3 && args.Args[2].Name == "ci" && args.Args[2].Value == "true", args.Args)
+
+	_, err = LoadFiles([]string{base}, "staging")
+	tAssert(t, err != nil && strings.Contains(err.Error(), `profile "staging" not found`), err)
+}
+
+func TestLoadFile_env(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "kcl.yaml")
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"b": 1, "a": [true]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KCL_TEST_OUT", "out.yaml")
+	t.Setenv("KCL_TEST_EMPTY", "")
+
+	f, err := LoadFile(filename, `
+kcl_cli_configs:
+  file:
+    - ${PWD}/main.k
+  output: ${KCL_TEST_OUT}
+  overrides:
+    - app.name=${KCL_TEST_NAME:-web}
+  package_maps:
+    k8s: ${KCL_TEST_EMPTY:-./k8s}
+kcl_options:
+  - key: replicas
+    value: ${KCL_TEST_REPLICAS:-3}
+  - key: tag
+    value: "${KCL_TEST_TAG:-3}"
+  - key: literal
+    value: $${HOME}
+  - key: config
+    value_file: config.json
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tAssert(t, reflect.DeepEqual(f.Config.InputFile, []string{"${PWD}/main.k"}), f.Config.InputFile)
+	tAssert(t, f.Config.Output == "out.yaml", f.Config.Output)
+	tAssert(t, reflect.DeepEqual(f.Config.Overrides, []string{"app.name=web"}), f.Config.Overrides)
+	tAssert(t, f.Config.PackageMaps["k8s"] == "./k8s", f.Config.PackageMaps)
+
+	args := f.To_ExecProgramArgs()
+	expect := []string{`3`, `"3"`, `"${HOME}"`, `{"b":1,"a":[true]}`}
+	for i, arg := range args.Args {
+		tAssert(t, arg.Value == expect[i], arg.Name, arg.Value)
+	}
+
+	_, err = LoadFile(filename, "kcl_options:\n  - key: a\n    value: [x, \"${KCL_TEST_UNSET}\"]\n")
+	tAssert(t, err != nil && err.Error() == filename+`: kcl_options[0].value[1]: environment variable "KCL_TEST_UNSET" is not set`, err)
+
+	_, err = LoadFile(filename, "kcl_options:\n  - key: a\n    value: 1\n    value_file: config.json\n")
+	tAssert(t, err != nil && strings.Contains(err.Error(), "value and value_file are exclusive"), err)
+
+	_, err = LoadFile(filename, "kcl_options:\n  - key: a\n    value_file: missing.json\n")
+	tAssert(t, err != nil && strings.Contains(err.Error(), "kcl_options[0] (a): value_file:"), err)
+}
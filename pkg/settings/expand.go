@@ -0,0 +1,118 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package settings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envVarRegexp matches `$${...}`, `${NAME}` and `${NAME:-default}`.
+var envVarRegexp = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// pathVars are expanded from the work dir and the kcl.mod of the program,
+// not from the environment, see To_ExecProgramArgs.
+var pathVars = map[string]bool{"PWD": true, "KCL_MOD": true}
+
+// expandEnv replaces `${NAME}` in s by the value of the environment variable
+// NAME and `${NAME:-default}` by default if NAME is unset or empty. `$${`
+// is replaced by a literal `${`. It returns an error naming the first
+// unset variable without a default.
+func expandEnv(s string) (string, error) {
+	var err error
+	result := envVarRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		m := envVarRegexp.FindStringSubmatch(match)
+		name, hasDefault, def := m[1], m[2] != "", m[3]
+		if pathVars[name] {
+			return match
+		}
+		value, ok := os.LookupEnv(name)
+		if (!ok || value == "") && hasDefault {
+			return def
+		}
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %q is not set", name)
+		}
+		return value
+	})
+	return result, err
+}
+
+// prepareSettingsNode expands the environment variables in the string
+// values of the settings document of filename and makes the `value_file`
+// paths of kcl_options absolute. An expanded unquoted value is resolved
+// again, so `replicas: ${REPLICAS:-3}` is an integer.
+func prepareSettingsNode(filename string, doc *yaml.Node) error {
+	dir := filepath.Dir(filename)
+	var walk func(node *yaml.Node, path string) error
+	walk = func(node *yaml.Node, path string) error {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, n := range node.Content {
+				if err := walk(n, path); err != nil {
+					return err
+				}
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				p := key
+				if path != "" {
+					p = path + "." + key
+				}
+				value := node.Content[i+1]
+				if err := walk(value, p); err != nil {
+					return err
+				}
+				if key == "value_file" && value.Kind == yaml.ScalarNode && value.Value != "" && !filepath.IsAbs(value.Value) {
+					value.Value = filepath.Join(dir, value.Value)
+				}
+			}
+		case yaml.SequenceNode:
+			for i, n := range node.Content {
+				if err := walk(n, path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+		case yaml.ScalarNode:
+			if !strings.Contains(node.Value, "${") || node.ShortTag() != "!!str" {
+				return nil
+			}
+			value, err := expandEnv(node.Value)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", filename, path, err)
+			}
+			node.Value = value
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+		return nil
+	}
+	return walk(doc, "")
+}
+
+// loadValueFile loads a JSON or YAML file as the value of a kcl option.
+func loadValueFile(filename string) (*yaml.Node, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	return doc.Content[0], nil
+}
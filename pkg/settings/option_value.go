@@ -0,0 +1,209 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"kcl-lang.io/kcl-go/pkg/3rdparty/toml"
+	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
+)
+
+// EncodeOptionValue encodes v as the value of a top level argument, i.e. the
+// value of `kcl -D name=value`. KCL decodes the value as JSON, so v is JSON
+// encoded: strings stay strings, even "123" or "true", nil becomes None, and
+// structs are encoded by their json tags. A json.RawMessage is used as is and
+// a *yaml.Node is encoded in the key order of the YAML document.
+func EncodeOptionValue(v any) (string, error) {
+	switch v := v.(type) {
+	case *yaml.Node:
+		var buf bytes.Buffer
+		if err := encodeYAMLNode(&buf, v); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	case json.RawMessage:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(normalizeOptionValue(v)); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// normalizeOptionValue converts the map[any]any values produced by some YAML
+// decoders into map[string]any, which encoding/json supports.
+func normalizeOptionValue(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, x := range v {
+			m[fmt.Sprint(k)] = normalizeOptionValue(x)
+		}
+		return m
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, x := range v {
+			m[k] = normalizeOptionValue(x)
+		}
+		return m
+	case []any:
+		list := make([]any, len(v))
+		for i, x := range v {
+			list[i] = normalizeOptionValue(x)
+		}
+		return list
+	}
+	return v
+}
+
+// encodeYAMLNode writes node as JSON, keeping the key order of mappings.
+func encodeYAMLNode(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return encodeYAMLNode(buf, node.Content[0])
+	case yaml.AliasNode:
+		return encodeYAMLNode(buf, node.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := encodeYAMLNode(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeYAMLNode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case yaml.ScalarNode:
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		s, err := EncodeOptionValue(value)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+		return nil
+	}
+	buf.WriteString("null")
+	return nil
+}
+
+// LoadOptionsFile loads the top level arguments from a JSON, YAML or TOML
+// file, chosen by the file extension. Each key of the top level mapping is
+// an argument name and its value is encoded by EncodeOptionValue. The
+// arguments keep the key order of the file.
+func LoadOptionsFile(filename string) ([]*gpyrpc.Argument, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadOptions(filename, data)
+}
+
+// LoadOptions is like LoadOptionsFile but reads the file content from data.
+// The filename is only used to choose the format and in errors.
+func LoadOptions(filename string, data []byte) ([]*gpyrpc.Argument, error) {
+	var names []string
+	var values []any
+
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return nil, fmt.Errorf("%s: expect a JSON object", filename)
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", filename, err)
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return nil, fmt.Errorf("%s: %w", filename, err)
+			}
+			names = append(names, tok.(string))
+			values = append(values, value)
+		}
+	case ".yaml", ".yml":
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if len(doc.Content) == 0 {
+			return nil, nil
+		}
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s: expect a YAML mapping", filename)
+		}
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			names = append(names, root.Content[i].Value)
+			values = append(values, root.Content[i+1])
+		}
+	case ".toml":
+		var m map[string]any
+		md, err := toml.Decode(string(data), &m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		for _, key := range md.Keys() {
+			if len(key) == 1 {
+				names = append(names, key[0])
+				values = append(values, m[key[0]])
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported options file format %q, expect .json, .yaml, .yml or .toml", filename, ext)
+	}
+
+	var args []*gpyrpc.Argument
+	for i, name := range names {
+		value, err := EncodeOptionValue(values[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: option %s: %w", filename, name, err)
+		}
+		args = append(args, &gpyrpc.Argument{Name: name, Value: value})
+	}
+	return args, nil
+}
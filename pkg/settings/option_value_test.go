@@ -0,0 +1,100 @@
+// Copyright The KCL Authors. All rights reserved.
+
+package settings
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEncodeOptionValue(t *testing.T) {
+	type config struct {
+		Name     string `json:"name"`
+		Replicas int    `json:"replicas,omitempty"`
+	}
+	for _, tt := range []struct {
+		value  any
+		expect string
+	}{
+		{"value", `"value"`},
+		{"123", `"123"`},
+		{"a=b", `"a=b"`},
+		{"<html>", `"<html>"`},
+		{123, `123`},
+		{4.5, `4.5`},
+		{true, `true`},
+		{nil, `null`},
+		{[]string{"a", "b"}, `["a","b"]`},
+		{map[string]any{"b": 1, "a": []any{1}}, `{"a":[1],"b":1}`},
+		{map[any]any{1: "a"}, `{"1":"a"}`},
+		{config{Name: "app"}, `{"name":"app"}`},
+		{json.RawMessage(`{ "b": 1, "a": 2 }`), `{"b":1,"a":2}`},
+	} {
+		got, err := EncodeOptionValue(tt.value)
+		tAssert(t, err == nil, err)
+		tAssert(t, got == tt.expect, got, tt.expect)
+	}
+
+	_, err := EncodeOptionValue(func() {})
+	tAssert(t, err != nil, "expect an error for a func value")
+}
+
+func TestEncodeOptionValueYAMLNode(t *testing.T) {
+	var node yaml.Node
+	err := yaml.Unmarshal([]byte("b: 1\na: [x, \"2\", null]\nc: {\"k\\\"\": true}\n"), &node)
+	tAssert(t, err == nil, err)
+	got, err := EncodeOptionValue(&node)
+	tAssert(t, err == nil, err)
+	tAssert(t, got == `{"b":1,"a":["x","2",null],"c":{"k\"":true}}`, got)
+}
+
+func TestLoadOptions(t *testing.T) {
+	for filename, data := range map[string]string{
+		"options.json": `{"name": "app", "replicas": 3, "labels": {"b": "1", "a": "2"}}`,
+		"options.yaml": "name: app\nreplicas: 3\nlabels:\n  b: \"1\"\n  a: \"2\"\n",
+		"options.toml": "name = \"app\"\nreplicas = 3\n\n[labels]\nb = \"1\"\na = \"2\"\n",
+	} {
+		args, err := LoadOptions(filename, []byte(data))
+		tAssert(t, err == nil, filename, err)
+		tAssert(t, len(args) == 3, filename, args)
+		tAssert(t, args[0].Name == "name" && args[0].Value == `"app"`, filename, args[0])
+		tAssert(t, args[1].Name == "replicas" && args[1].Value == `3`, filename, args[1])
+		tAssert(t, args[2].Name == "labels", filename, args[2])
+		if filename == "options.toml" {
+			tAssert(t, args[2].Value == `{"a":"2","b":"1"}`, filename, args[2].Value)
+		} else {
+			tAssert(t, args[2].Value == `{"b":"1","a":"2"}`, filename, args[2].Value)
+		}
+	}
+
+	_, err := LoadOptions("options.ini", []byte("a=1"))
+	tAssert(t, err != nil, "expect an error for an unsupported format")
+	_, err = LoadOptions("options.json", []byte("[1]"))
+	tAssert(t, err != nil, "expect an error for a JSON array")
+	_, err = LoadOptions("options.yaml", []byte("- 1"))
+	tAssert(t, err != nil, "expect an error for a YAML sequence")
+}
+
+func TestSettingsOptionsEncoding(t *testing.T) {
+	f, err := LoadFile("settings.yaml", []byte(`
+kcl_options:
+  - key: name
+    value: "123"
+  - key: config
+    value:
+      b: 1
+      a: 2
+`))
+	tAssert(t, err == nil, err)
+	args := f.To_ExecProgramArgs()
+	tAssert(t, args.Args[0].Value == `"123"`, args.Args[0].Value)
+	tAssert(t, args.Args[1].Value == `{"b":1,"a":2}`, args.Args[1].Value)
+
+	value, err := EncodeOptionValue(map[string]any{"b": 1, "a": 2})
+	tAssert(t, err == nil, err)
+	f.Config.SortKeys = true
+	args = f.To_ExecProgramArgs()
+	tAssert(t, args.Args[1].Value == value, args.Args[1].Value)
+}
@@ -3,7 +3,6 @@
 package settings
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -43,6 +42,9 @@ type ConfigStruct struct {
 type KeyValueStruct struct {
 	Key   string `yaml:"key"`
 	Value any    `yaml:"value"`
+	// ValueFile is a JSON or YAML file loaded as the value, relative to
+	// the settings file.
+	ValueFile string `yaml:"value_file"`
 	// Store the original YAML value node to preserve order (the node under key: "value")
 	originalValueNode *yaml.Node `yaml:"-"`
 }
@@ -108,52 +110,20 @@ func getMappingValueNode(node *yaml.Node, key string) *yaml.Node {
 	return nil
 }
 
-// nodeToOrderedJSON converts any YAML node to JSON while preserving order
-func nodeToOrderedJSON(node *yaml.Node) (string, error) {
-	switch node.Kind {
-	case yaml.MappingNode:
-		var pairs []string
-		for i := 0; i < len(node.Content); i += 2 {
-			keyNode := node.Content[i]
-			valueNode := node.Content[i+1]
-
-			key := keyNode.Value
-			valueJSON, err := nodeToOrderedJSON(valueNode)
-			if err != nil {
-				continue
-			}
-
-			pairs = append(pairs, fmt.Sprintf("%q:%s", key, valueJSON))
-		}
-		return "{" + strings.Join(pairs, ",") + "}", nil
-
-	case yaml.SequenceNode:
-		var items []string
-		for _, itemNode := range node.Content {
-			itemJSON, err := nodeToOrderedJSON(itemNode)
-			if err != nil {
-				continue
-			}
-			items = append(items, itemJSON)
-		}
-		return "[" + strings.Join(items, ",") + "]", nil
-
-	case yaml.ScalarNode:
-		var value any
-		if err := node.Decode(&value); err != nil {
-			return fmt.Sprintf("%q", node.Value), nil
-		}
-		jsonBytes, err := json.Marshal(value)
-		if err != nil {
-			return fmt.Sprintf("%q", node.Value), nil
-		}
-		return string(jsonBytes), nil
-
-	default:
-		return "null", nil
-	}
-}
-
+// LoadFile loads the settings file filename, or src if it is not nil.
+//
+// `${NAME}` in string values is replaced by the environment variable NAME
+// and `${NAME:-default}` by default if NAME is unset or empty; `$${` is a
+// literal `${`. `${PWD}` and `${KCL_MOD}` are left to To_ExecProgramArgs.
+// An unquoted value is typed after the expansion, e.g. `${REPLICAS:-3}`
+// is an integer. A kcl_options entry may have a `value_file` instead of a
+// `value`, a JSON or YAML file relative to the settings file, e.g.
+//
+//	kcl_options:
+//	  - key: image
+//	    value: ${IMAGE:-nginx:latest}
+//	  - key: config
+//	    value_file: config.json
 func LoadFile(filename string, src any) (f *SettingsFile, err error) {
 	if !filepath.IsAbs(filename) {
 		if s, _ := filepath.Abs(filename); s != "" {
@@ -191,23 +161,161 @@ func LoadFile(filename string, src any) (f *SettingsFile, err error) {
 	if err := yaml.Unmarshal([]byte(code), &rootNode); err != nil {
 		return nil, err
 	}
+	if err := prepareSettingsNode(filename, &rootNode); err != nil {
+		return nil, err
+	}
+	return decodeSettings(filename, &rootNode)
+}
 
-	// Also parse normally for basic structure
+// decodeSettings decodes the settings from the YAML document node.
+func decodeSettings(filename string, rootNode *yaml.Node) (*SettingsFile, error) {
 	var settings SettingsFile
-	if err := yaml.Unmarshal([]byte(code), &settings); err != nil {
-		return nil, err
+	if len(rootNode.Content) > 0 {
+		if err := rootNode.Decode(&settings); err != nil {
+			return nil, err
+		}
 	}
 
 	// Enhance the settings with order-preserving information
-	if err := enhanceWithOrderInfo(&settings, &rootNode); err != nil {
+	if err := enhanceWithOrderInfo(&settings, rootNode); err != nil {
 		// If enhancement fails, continue with regular settings
 		// The order preservation is a best-effort feature
 	}
 
+	for i := range settings.Options {
+		opt := &settings.Options[i]
+		if opt.ValueFile == "" {
+			continue
+		}
+		if opt.Value != nil || opt.originalValueNode != nil {
+			return nil, fmt.Errorf("%s: kcl_options[%d] (%s): value and value_file are exclusive", filename, i, opt.Key)
+		}
+		node, err := loadValueFile(opt.ValueFile)
+		if err == nil {
+			err = node.Decode(&opt.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: kcl_options[%d] (%s): value_file: %w", filename, i, opt.Key, err)
+		}
+		opt.originalValueNode = node
+	}
+
 	settings.Filename = filename
 	return &settings, nil
 }
 
+// LoadFiles loads the settings files and merges them in order. Later files
+// override the scalars of earlier ones, e.g. `output` or `disable_none`,
+// and append to their lists, e.g. `file`, `overrides` and `kcl_options`.
+// Mappings, e.g. `package_maps`, are merged key by key the same way.
+//
+// A settings file may have a `profiles` section, a mapping from a profile
+// name to settings with the same structure as a settings file. If profile
+// is not empty, the profile of the merged settings is merged last, e.g.
+//
+//	kcl_cli_configs:
+//	  file: [main.k]
+//	profiles:
+//	  prod:
+//	    kcl_options:
+//	      - key: env
+//	        value: prod
+//
+// See LoadFile for the expansion of environment variables and
+// `value_file` options in every file. The Filename of the result is the
+// last file name.
+func LoadFiles(filenames []string, profile string) (*SettingsFile, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("no settings file")
+	}
+	var merged *yaml.Node
+	for _, filename := range filenames {
+		// Errors name the file when there are several.
+		wrap := func(err error) error {
+			if len(filenames) > 1 {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+			return err
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, wrap(err)
+		}
+		if err := prepareSettingsNode(filename, &doc); err != nil {
+			return nil, err
+		}
+		// Decode every file on its own, so errors belong to a file.
+		if _, err := decodeSettings(filename, &doc); err != nil {
+			return nil, wrap(err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		if merged == nil {
+			merged = doc.Content[0]
+		} else {
+			merged = mergeSettingsNode(merged, doc.Content[0])
+		}
+	}
+
+	last := filenames[len(filenames)-1]
+	if abs, err := filepath.Abs(last); err == nil {
+		last = abs
+	}
+	if profile != "" {
+		profileNode := getMappingValueNode(getMappingValueNode(merged, "profiles"), profile)
+		if profileNode == nil {
+			return nil, fmt.Errorf("profile %q not found in %s", profile, strings.Join(filenames, ", "))
+		}
+		merged = mergeSettingsNode(merged, profileNode)
+	}
+	if merged == nil {
+		return &SettingsFile{Filename: last}, nil
+	}
+	settings, err := decodeSettings(last, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{merged}})
+	if err != nil {
+		if profile != "" {
+			return nil, fmt.Errorf("profile %q: %w", profile, err)
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+// mergeSettingsNode merges the YAML node src into dst: mappings are merged
+// key by key, sequences are appended and any other value replaces dst.
+func mergeSettingsNode(dst, src *yaml.Node) *yaml.Node {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		result := &yaml.Node{Kind: yaml.MappingNode, Tag: dst.Tag, Style: dst.Style}
+		result.Content = append(result.Content, dst.Content...)
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			found := false
+			for j := 0; j+1 < len(result.Content); j += 2 {
+				if result.Content[j].Value == key.Value {
+					result.Content[j+1] = mergeSettingsNode(result.Content[j+1], value)
+					found = true
+					break
+				}
+			}
+			if !found {
+				result.Content = append(result.Content, key, value)
+			}
+		}
+		return result
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		result := &yaml.Node{Kind: yaml.SequenceNode, Tag: dst.Tag, Style: dst.Style}
+		result.Content = append(append(result.Content, dst.Content...), src.Content...)
+		return result
+	}
+	return src
+}
+
 func (settings *SettingsFile) To_ExecProgramArgs() *gpyrpc.ExecProgramArgs {
 	args := &gpyrpc.ExecProgramArgs{
 		KFilenameList: []string{},
@@ -270,39 +378,14 @@ func (settings *SettingsFile) To_ExecProgramArgs() *gpyrpc.ExecProgramArgs {
 	// kcl -D aa=11 -D bb=22 main.k
 	for _, t := range settings.Options {
 		var key string = t.Key
-		var val string
-
-		switch v := t.Value.(type) {
-		case map[string]any:
-			// Check if we should preserve order or sort keys
-			if !settings.Config.SortKeys && t.originalValueNode != nil {
-				// Preserve original YAML order when sort_keys is false
-				if s, err := nodeToOrderedJSON(t.originalValueNode); err == nil {
-					val = s
-				} else {
-					// Fallback to regular JSON marshaling (which sorts)
-					if s, err := json.Marshal(v); err == nil {
-						val = string(s)
-					} else {
-						val = fmt.Sprint(v)
-					}
-				}
-			} else {
-				// Use regular JSON marshaling (which sorts) when sort_keys is true
-				if s, err := json.Marshal(v); err == nil {
-					val = string(s)
-				} else {
-					val = fmt.Sprint(v)
-				}
-			}
-		case []any:
-			if s, err := json.Marshal(v); err == nil {
-				val = string(s)
-			} else {
-				val = fmt.Sprint(v)
-			}
-		default:
-			val = fmt.Sprint(v)
+		var value any = t.Value
+		// Preserve the original YAML key order unless sort_keys is set.
+		if !settings.Config.SortKeys && t.originalValueNode != nil {
+			value = t.originalValueNode
+		}
+		val, err := EncodeOptionValue(value)
+		if err != nil {
+			val = fmt.Sprint(t.Value)
 		}
 
 		args.Args = append(args.Args, &gpyrpc.Argument{
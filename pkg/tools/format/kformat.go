@@ -8,7 +8,7 @@ import (
 	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
 )
 
-func FormatCode(code any) ([]byte, error) {
+func FormatCode(code any, opts ...kcl.Option) ([]byte, error) {
 	var codeStr string
 	switch code := code.(type) {
 	case []byte:
@@ -26,7 +26,7 @@ func FormatCode(code any) ([]byte, error) {
 		return nil, errors.New("unsupported source code format. valid formats: []byte, string, io.Reader")
 	}
 
-	svc := kcl.Service()
+	svc := kcl.ServiceOf(opts...)
 	resp, err := svc.FormatCode(&gpyrpc.FormatCodeArgs{
 		Source: codeStr,
 	})
@@ -43,8 +43,9 @@ type FormatPathOptions struct {
 func FormatPathWithOptions(
 	path string,
 	opts FormatPathOptions,
+	options ...kcl.Option,
 ) ([]string, error) {
-	svc := kcl.Service()
+	svc := kcl.ServiceOf(options...)
 	resp, err := svc.FormatPath(&gpyrpc.FormatPathArgs{
 		Path:   path,
 		DryRun: opts.DryRun,
@@ -55,6 +56,6 @@ func FormatPathWithOptions(
 	return resp.ChangedPaths, nil
 }
 
-func FormatPath(path string) ([]string, error) {
-	return FormatPathWithOptions(path, FormatPathOptions{})
+func FormatPath(path string, opts ...kcl.Option) ([]string, error) {
+	return FormatPathWithOptions(path, FormatPathOptions{}, opts...)
 }
@@ -7,8 +7,8 @@ import (
 	"kcl-lang.io/kcl-go/pkg/spec/gpyrpc"
 )
 
-func LintPath(paths []string) (results []string, err error) {
-	svc := kcl.Service()
+func LintPath(paths []string, opts ...kcl.Option) (results []string, err error) {
+	svc := kcl.ServiceOf(opts...)
 	resp, err := svc.LintPath(&gpyrpc.LintPathArgs{
 		Paths: paths,
 	})
@@ -546,10 +546,10 @@ func loadKFileList(vfs fs.FS, path string, opt Option) ([]string, error) {
 
 		data, err := fs.ReadFile(vfs, kclYamlPath)
 		if err != nil {
-			panic(fmt.Errorf("%s: %v", kclYamlPath, err))
+			return nil, fmt.Errorf("%s: %v", kclYamlPath, err)
 		}
 		if err := yaml.Unmarshal([]byte(data), &settings); err != nil {
-			panic(fmt.Errorf("%s: %v", kclYamlPath, err))
+			return nil, fmt.Errorf("%s: %v", kclYamlPath, err)
 		}
 		for i, s := range settings.Config.Files {
 			switch {
@@ -559,7 +559,7 @@ func loadKFileList(vfs fs.FS, path string, opt Option) ([]string, error) {
 				goldenPath = pathpkg.Clean(goldenPath)
 
 				if _, err := fs.Stat(vfs, goldenPath); err != nil {
-					panic(fmt.Errorf("%s: %v", kclYamlPath, err))
+					return nil, fmt.Errorf("%s: %v", kclYamlPath, err)
 				}
 
 				settings.Config.Files[i] = goldenPath
@@ -570,7 +570,7 @@ func loadKFileList(vfs fs.FS, path string, opt Option) ([]string, error) {
 				goldenPath = pathpkg.Clean(goldenPath)
 
 				if _, err := fs.Stat(vfs, goldenPath); err != nil {
-					panic(fmt.Errorf("%s: %v", kclYamlPath, err))
+					return nil, fmt.Errorf("%s: %v", kclYamlPath, err)
 				}
 
 				settings.Config.Files[i] = goldenPath
@@ -3,6 +3,8 @@
 package list
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -56,3 +58,16 @@ func TestNewDepParser(t *testing.T) {
 	assert.Equal(t, dp.pkgFilesMap["entry"], []string{"entry/main.k"})
 	assert.Equal(t, dp.pkgFilesMap["sub"], []string{"sub/main.k"})
 }
+
+func TestListDepFiles_kclYamlFailed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kcl.mod"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kcl.yaml"), []byte("kcl_cli_configs: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListDepFiles(dir, nil); err == nil || !strings.Contains(err.Error(), "kcl.yaml") {
+		t.Fatalf("expect a kcl.yaml error, got %v", err)
+	}
+}
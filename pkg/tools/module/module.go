@@ -9,7 +9,7 @@ type UpdateDependenciesArgs = gpyrpc.UpdateDependenciesArgs
 type UpdateDependenciesResult = gpyrpc.UpdateDependenciesResult
 
 // Download and update dependencies defined in the kcl.mod file and return the external package name and location list.
-func UpdateDependencies(args *UpdateDependenciesArgs) (*UpdateDependenciesResult, error) {
-	svc := kcl.Service()
+func UpdateDependencies(args *UpdateDependenciesArgs, opts ...kcl.Option) (*UpdateDependenciesResult, error) {
+	svc := kcl.ServiceOf(opts...)
 	return svc.UpdateDependencies(args)
 }
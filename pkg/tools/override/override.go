@@ -12,8 +12,8 @@ const (
 	CreateOrUpdateAction = "CreateOrUpdate"
 )
 
-func OverrideFile(file string, specs, importPaths []string) (result bool, err error) {
-	svc := kcl.Service()
+func OverrideFile(file string, specs, importPaths []string, opts ...kcl.Option) (result bool, err error) {
+	svc := kcl.ServiceOf(opts...)
 	resp, err := svc.OverrideFile(&gpyrpc.OverrideFileArgs{
 		File:        file,
 		Specs:       specs,
@@ -1,6 +1,7 @@
 package testing
 
 import (
+	"context"
 	"fmt"
 
 	"kcl-lang.io/kcl-go/pkg/kcl"
@@ -61,6 +62,12 @@ func (t *TestCaseInfo) StatusString() string {
 }
 
 func Test(testOpts *TestOptions, opts ...kcl.Option) (TestResult, error) {
+	return TestContext(context.Background(), testOpts, opts...)
+}
+
+// TestContext is like Test but returns a *kcl.CancelError when ctx is
+// cancelled or its deadline passes before the tests finish.
+func TestContext(ctx context.Context, testOpts *TestOptions, opts ...kcl.Option) (TestResult, error) {
 	if testOpts == nil {
 		testOpts = &TestOptions{}
 	}
@@ -68,13 +75,20 @@ func Test(testOpts *TestOptions, opts ...kcl.Option) (TestResult, error) {
 	if err := args.Err; err != nil {
 		return TestResult{}, err
 	}
+	if timeout := args.GetTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-	svc := kcl.Service()
-	resp, err := svc.Test(&gpyrpc.TestArgs{
-		ExecArgs:  args.ExecProgramArgs,
-		PkgList:   testOpts.PkgList,
-		RunRegexp: testOpts.RunRegRxp,
-		FailFast:  testOpts.FailFast,
+	svc := kcl.ContextService(ctx, args.GetService())
+	resp, err := kcl.CallContext(ctx, "Test", func() (*gpyrpc.TestResult, error) {
+		return svc.Test(&gpyrpc.TestArgs{
+			ExecArgs:  args.ExecProgramArgs,
+			PkgList:   testOpts.PkgList,
+			RunRegexp: testOpts.RunRegRxp,
+			FailFast:  testOpts.FailFast,
+		})
 	})
 	if err != nil {
 		return TestResult{}, err
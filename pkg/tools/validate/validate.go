@@ -3,7 +3,7 @@
 package validate
 
 import (
-	"errors"
+	"context"
 	"os"
 
 	"kcl-lang.io/kcl-go/pkg/kcl"
@@ -19,7 +19,7 @@ type ValidateOptions struct {
 
 // Validate validates the given data file against the specified
 // schema file with the provided options.
-func Validate(dataFile, schemaFile string, opts *ValidateOptions) (ok bool, err error) {
+func Validate(dataFile, schemaFile string, opts *ValidateOptions, options ...kcl.Option) (ok bool, err error) {
 	data, err := os.ReadFile(dataFile)
 	if err != nil {
 		return false, err
@@ -27,7 +27,7 @@ func Validate(dataFile, schemaFile string, opts *ValidateOptions) (ok bool, err
 	if opts == nil {
 		opts = &ValidateOptions{}
 	}
-	svc := kcl.Service()
+	svc := kcl.ServiceOf(options...)
 	resp, err := svc.ValidateCode(&gpyrpc.ValidateCodeArgs{
 		File:          schemaFile,
 		Data:          string(data),
@@ -40,38 +40,46 @@ func Validate(dataFile, schemaFile string, opts *ValidateOptions) (ok bool, err
 	}
 	var e error = nil
 	if resp.ErrMessage != "" {
-		e = errors.New(resp.ErrMessage)
+		e = kcl.NewError(resp.ErrMessage)
 	}
 	return resp.Success, e
 }
 
-func ValidateCode(data, code string, opts *ValidateOptions) (ok bool, err error) {
+func ValidateCode(data, code string, opts *ValidateOptions, options ...kcl.Option) (ok bool, err error) {
+	return ValidateCodeContext(context.Background(), data, code, opts, options...)
+}
+
+// ValidateCodeContext is like ValidateCode but returns a *kcl.CancelError
+// when ctx is cancelled or its deadline passes before validation finishes.
+func ValidateCodeContext(ctx context.Context, data, code string, opts *ValidateOptions, options ...kcl.Option) (ok bool, err error) {
 	if opts == nil {
 		opts = &ValidateOptions{}
 	}
-	svc := kcl.Service()
-	resp, err := svc.ValidateCode(&gpyrpc.ValidateCodeArgs{
-		Data:          data,
-		Code:          code,
-		Schema:        opts.Schema,
-		AttributeName: opts.AttributeName,
-		Format:        opts.Format,
+	svc := kcl.ContextService(ctx, kcl.ServiceOf(options...))
+	resp, err := kcl.CallContext(ctx, "ValidateCode", func() (*gpyrpc.ValidateCodeResult, error) {
+		return svc.ValidateCode(&gpyrpc.ValidateCodeArgs{
+			Data:          data,
+			Code:          code,
+			Schema:        opts.Schema,
+			AttributeName: opts.AttributeName,
+			Format:        opts.Format,
+		})
 	})
 	if err != nil {
 		return false, err
 	}
 	var e error = nil
 	if resp.ErrMessage != "" {
-		e = errors.New(resp.ErrMessage)
+		e = kcl.NewError(resp.ErrMessage)
 	}
 	return resp.Success, e
 }
 
-func ValidateCodeFile(dataFile, data, code string, opts *ValidateOptions) (ok bool, err error) {
+func ValidateCodeFile(dataFile, data, code string, opts *ValidateOptions, options ...kcl.Option) (ok bool, err error) {
 	if opts == nil {
 		opts = &ValidateOptions{}
 	}
-	svc := kcl.Service()
+	svc := kcl.ServiceOf(options...)
 	resp, err := svc.ValidateCode(&gpyrpc.ValidateCodeArgs{
 		Datafile:      dataFile,
 		Data:          data,
@@ -85,7 +93,7 @@ func ValidateCodeFile(dataFile, data, code string, opts *ValidateOptions) (ok bo
 	}
 	var e error = nil
 	if resp.ErrMessage != "" {
-		e = errors.New(resp.ErrMessage)
+		e = kcl.NewError(resp.ErrMessage)
 	}
 	return resp.Success, e
 }